@@ -27,13 +27,124 @@ struct packet_t {
     uint64_t ts;
     bool outbound;
     __u32 len;
+    __u32 ifindex;
+    __u64 netns;
 };
+
+// Set by the agent when bpf_get_netns_cookie is available to tc programs, from Linux 5.15
+volatile const __u8 record_netns = 0;
+
+#define SAMPLE_COUNT 0  // every sample_rate-th packet of each CPU
+#define SAMPLE_RANDOM 1 // each packet with probability 1/sample_rate
+#define SAMPLE_FLOW 2   // all the packets of 1 in sample_rate flows, chosen by hash
+
+// Sampling settings, rewritten by the agent before loading. A rate of 1 sends every packet
+volatile const __u32 sample_rate = 1;
+volatile const __u8 sample_mode = SAMPLE_COUNT;
+
+#define PIPE_SHARED 0  // every CPU writes its events to pipe
+#define PIPE_PER_CPU 1 // each CPU writes to its own ringbuf in pipes, read in parallel
+
+// Event pipe layout, rewritten by the agent before loading
+volatile const __u8 pipe_layout = PIPE_SHARED;
+
+#define MAX_CPUS 1024
+
+#define PIPE_RINGBUF 0    // events are sent through the ringbufs, from Linux 5.8
+#define PIPE_PERF_EVENT 1 // events are sent through perf_pipe, for older kernels
+
+// Event transport, rewritten by the agent before loading. The verifier skips the code of the
+// other transport, so the ringbuf helpers are never checked on kernels without them
+volatile const __u8 pipe_transport = PIPE_RINGBUF;
+
+// pipe_stats counts the events of each CPU, avail_data is the ringbuf fill level seen last
+struct pipe_stats {
+    __u64 events;
+    __u64 drops;
+    __u64 avail_data;
+};
+
+// pipe_control is set by the agent when the event consumer falls behind. The sampling rate
+// is multiplied by sample_factor, and with aggregate set the flows are counted in
+// flowstracker instead of sending events
+struct pipe_control {
+    __u32 sample_factor;
+    __u8 aggregate;
+};
+
+#define CAPTURE_MAX_SNAPLEN 2048
+
+// capture_filter selects the packets copied to capture_pipe. Prefixes are matched on the
+// IPv4-mapped addresses, and a zero port or prefix length matches anything
+struct capture_filter {
+    __u8 enabled;
+    __u8 protocol;
+    __be16 port_a;
+    __be16 port_b;
+    __u8 prefixlen_a;
+    __u8 prefixlen_b;
+    struct in6_addr addr_a;
+    struct in6_addr addr_b;
+    __u32 snaplen;
+};
+
+struct capture_event {
+    uint64_t ts;
+    __u32 len;
+    __u32 caplen;
+    bool outbound;
+    __u8 data[CAPTURE_MAX_SNAPLEN];
+};
+
+#define FILTER_MAX_RULES 1024
+#define FILTER_WORDS (FILTER_MAX_RULES / 64)
+
+// filter_rules is a set of filter rules, bit i standing for the rule of id i
+struct filter_rules {
+    __u64 bits[FILTER_WORDS];
+};
+
+// filter_config selects the filter set in use. The agent fills the other set, then switches
+// to it, so the packets never see half of an update
+struct filter_config {
+    __u32 set;
+};
+
+// filter_set holds the actions of the rules of a set. Once it has an allow rule, only the
+// packets matching one are monitored
+struct filter_set {
+    __u32 rules;
+    __u32 allow_rules;
+    struct filter_rules allow;
+    struct filter_rules deny;
+};
+
+// filter_net_key is the key of filter_nets. The prefix length counts the set and local
+// bytes, and the entry of a prefix holds the rules it matches on that side
+struct filter_net_key {
+    __u32 prefixlen;
+    __u8 set;
+    __u8 local;
+    __u8 addr[16];
+};
+
+// filter_port_key is the key of filter_ports, whose entries are aligned blocks of ports
+// sharing the same rules
+struct filter_port_key {
+    __u32 prefixlen;
+    __u8 set;
+    __u8 local;
+    __be16 port;
+};
+
 struct flow_id {
     struct in6_addr l_ip;
     struct in6_addr r_ip;
     __be16 l_port;
     __be16 r_port;
     __u8 protocol;
+    __u32 ifindex; // the same connection seen on two interfaces, or namespaces, makes two flows
+    __u64 netns;
 };
 struct flow_metrics {
     __u32 packets_in;
@@ -60,6 +171,138 @@ struct {
     __uint(max_entries, 1 << 24);    
 } pipe SEC(".maps");
 
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+    __uint(key_size, sizeof(__u32));
+    __uint(value_size, sizeof(__u32));
+} perf_pipe SEC(".maps");
+
+struct pipe_ring {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 1 << 20);
+};
+
+// pipes holds a ringbuf per CPU, created by the agent when using the PIPE_PER_CPU layout
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY_OF_MAPS);
+    __uint(max_entries, MAX_CPUS);
+    __type(key, __u32);
+    __array(values, struct pipe_ring);
+} pipes SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, __u32);
+} sample_counter SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct pipe_stats);
+} pipe_stats SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct pipe_control);
+} pipe_control SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_RINGBUF);
+    __uint(max_entries, 1 << 22);
+} capture_pipe SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct capture_filter);
+} capture_filter SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct filter_config);
+} filter_config SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 2);
+    __type(key, __u32);
+    __type(value, struct filter_set);
+} filter_sets SEC(".maps");
+
+// Each set has the prefixes of the rules of both sides, and a default prefix
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 4 * (FILTER_MAX_RULES + 1));
+    __type(key, struct filter_net_key);
+    __type(value, struct filter_rules);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} filter_nets SEC(".maps");
+
+// The ports of both sides split in blocks, half of the entries for each set
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1 << 15);
+    __type(key, struct filter_port_key);
+    __type(value, struct filter_rules);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+} filter_ports SEC(".maps");
+
+// The rules of each protocol, at set * 256 + protocol
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, 2 * 256);
+    __type(key, __u32);
+    __type(value, struct filter_rules);
+} filter_protocols SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, FILTER_MAX_RULES);
+    __type(key, __u32);
+    __type(value, __u64);
+} filter_hits SEC(".maps");
+
+// l3_device identifies an interface without an L2 header. Indexes are only unique in a
+// namespace, so the interface is keyed by the cookie of its namespace too, 0 when the
+// packets are not tagged with it
+struct l3_device {
+    __u64 netns;
+    __u32 ifindex;
+    __u32 pad;
+};
+
+// l3_devices holds the interfaces without an L2 header, such as tun, WireGuard or ipip
+// devices, whose packets start with the IP header
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __type(key, struct l3_device);
+    __type(value, __u8);
+    __uint(max_entries, 1024);
+} l3_devices SEC(".maps");
+
+// l2_header_len returns the length of the L2 header of the packets of the interface
+static inline __u32 l2_header_len(struct __sk_buff* skb) {
+    struct l3_device device = { 0 };
+
+    device.ifindex = skb->ifindex;
+    if (record_netns) {
+        device.netns = bpf_get_netns_cookie(skb);
+    }
+
+    if (bpf_map_lookup_elem(&l3_devices, &device) != NULL) {
+        return 0;
+    }
+    return sizeof(struct ethhdr);
+}
+
 struct {
     __uint(type, BPF_MAP_TYPE_HASH);
     __uint(max_entries, 1 << 24);
@@ -68,20 +311,26 @@ struct {
     __uint(map_flags, BPF_F_NO_PREALLOC);
 } flowstracker SEC(".maps");
 
-static inline int handle_ip_packet(uint8_t* head, uint8_t* tail, uint32_t* offset, struct packet_t* pkt) {
-    struct ethhdr* eth = (void*)head;
+// handle_ip_packet parses the IP header following the l2 bytes of L2 header. The protocol,
+// from skb->protocol, is in network byte order
+static inline int handle_ip_packet(uint8_t* head, uint8_t* tail, uint32_t* offset, struct packet_t* pkt,
+                                   __be16 protocol, __u32 l2) {
     struct iphdr* ip;
     struct ipv6hdr* ipv6;
 
-    switch (bpf_ntohs(eth->h_proto)) {
+    if (l2 > sizeof(struct ethhdr)) {
+        return TC_ACT_OK;
+    }
+
+    switch (bpf_ntohs(protocol)) {
     case ETH_P_IP:
-        *offset = sizeof(struct ethhdr) + sizeof(struct iphdr);
+        *offset = l2 + sizeof(struct iphdr);
 
         if (head + (*offset) > tail) { // If the next layer is not IP, let the packet pass
             return TC_ACT_OK;
         }
 
-        ip = (void*)head + sizeof(struct ethhdr);
+        ip = (void*)head + l2;
 
         if (ip->protocol != IPPROTO_TCP && ip->protocol != IPPROTO_UDP) {
             return TC_ACT_OK;
@@ -100,13 +349,13 @@ static inline int handle_ip_packet(uint8_t* head, uint8_t* tail, uint32_t* offse
         return 1; // We have a TCP or UDP packet!
 
     case ETH_P_IPV6:
-        *offset = sizeof(struct ethhdr) + sizeof(struct ipv6hdr);
+        *offset = l2 + sizeof(struct ipv6hdr);
 
         if (head + (*offset) > tail) {
             return TC_ACT_OK;
         }
 
-        ipv6 = (void*)head + sizeof(struct ethhdr);
+        ipv6 = (void*)head + l2;
 
         if (ipv6->nexthdr != IPPROTO_TCP && ipv6->nexthdr != IPPROTO_UDP) {
             return TC_ACT_OK;
@@ -155,12 +404,248 @@ static inline int handle_ip_segment(uint8_t* head, uint8_t* tail, uint32_t* offs
     }
 }
 
+// flow_hash returns the same value for both directions of a flow
+static inline __u32 flow_hash(const struct packet_t* pkt) {
+    __u32 hash = pkt->protocol ^ pkt->src_port ^ pkt->dst_port;
+
+    #pragma unroll
+    for (int i = 0; i < 4; i++) {
+        hash ^= pkt->src_ip.in6_u.u6_addr32[i] ^ pkt->dst_ip.in6_u.u6_addr32[i];
+    }
+
+    hash ^= hash >> 16;
+    hash *= 0x7feb352d;
+    hash ^= hash >> 15;
+    hash *= 0x846ca68b;
+    hash ^= hash >> 16;
+
+    return hash;
+}
+
+// sample_packet tells whether the packet is sent to user space
+static inline bool sample_packet(const struct packet_t* pkt, __u32 rate) {
+    if (rate <= 1) {
+        return true;
+    }
+
+    switch (sample_mode) {
+    case SAMPLE_RANDOM:
+        return bpf_get_prandom_u32() % rate == 0;
+
+    case SAMPLE_FLOW:
+        return flow_hash(pkt) % rate == 0;
+
+    default: {
+        __u32 key = 0;
+        __u32* counter = bpf_map_lookup_elem(&sample_counter, &key);
+        if (counter == NULL) {
+            return true;
+        }
+        *counter += 1;
+        if (*counter < rate) {
+            return false;
+        }
+        *counter = 0;
+        return true;
+    }
+    }
+}
+
+// popcount64 counts the bits set, without a helper or a loop
+static inline __u32 popcount64(__u64 x) {
+    x = x - ((x >> 1) & 0x5555555555555555ULL);
+    x = (x & 0x3333333333333333ULL) + ((x >> 2) & 0x3333333333333333ULL);
+    x = (x + (x >> 4)) & 0x0f0f0f0f0f0f0f0fULL;
+    return (x * 0x0101010101010101ULL) >> 56;
+}
+
+// filter_hits_add counts a hit of each rule of a word of a set of rules. Global, so the
+// verifier checks it once instead of in every iteration of its caller
+__attribute__((noinline)) int filter_hits_add(__u64 match, __u32 word) {
+    for (int i = 0; i < 64 && match != 0; i++) {
+        __u64 low = match & -match;
+        __u32 id = (word % FILTER_WORDS) * 64 + popcount64(low - 1);
+
+        __u64* hits = bpf_map_lookup_elem(&filter_hits, &id);
+        if (hits != NULL) {
+            *hits += 1;
+        }
+        match &= match - 1;
+    }
+    return 0;
+}
+
+// filter_packet tells whether the packet is monitored, counting the hits of every rule it
+// matches. A rule matches when the packet is in the rules of its local and remote net, of
+// its local and remote port and of its protocol
+static inline bool filter_packet(const struct packet_t* pkt) {
+    __u32 key = 0;
+    struct filter_config* config = bpf_map_lookup_elem(&filter_config, &key);
+
+    if (config == NULL) {
+        return true;
+    }
+
+    __u32 set = config->set & 1;
+    struct filter_set* rules = bpf_map_lookup_elem(&filter_sets, &set);
+
+    if (rules == NULL || rules->rules == 0) {
+        return true;
+    }
+
+    struct filter_net_key local_net = { .prefixlen = 16 + 128, .set = set, .local = 1 };
+    struct filter_net_key remote_net = { .prefixlen = 16 + 128, .set = set, .local = 0 };
+    struct filter_port_key local_port = { .prefixlen = 16 + 16, .set = set, .local = 1 };
+    struct filter_port_key remote_port = { .prefixlen = 16 + 16, .set = set, .local = 0 };
+
+    if (pkt->outbound) {
+        __builtin_memcpy(local_net.addr, &pkt->src_ip, sizeof(local_net.addr));
+        __builtin_memcpy(remote_net.addr, &pkt->dst_ip, sizeof(remote_net.addr));
+        local_port.port = pkt->src_port;
+        remote_port.port = pkt->dst_port;
+    } else {
+        __builtin_memcpy(local_net.addr, &pkt->dst_ip, sizeof(local_net.addr));
+        __builtin_memcpy(remote_net.addr, &pkt->src_ip, sizeof(remote_net.addr));
+        local_port.port = pkt->dst_port;
+        remote_port.port = pkt->src_port;
+    }
+
+    __u32 protocol = set * 256 + pkt->protocol;
+
+    struct filter_rules* local_nets = bpf_map_lookup_elem(&filter_nets, &local_net);
+    struct filter_rules* remote_nets = bpf_map_lookup_elem(&filter_nets, &remote_net);
+    struct filter_rules* local_ports = bpf_map_lookup_elem(&filter_ports, &local_port);
+    struct filter_rules* remote_ports = bpf_map_lookup_elem(&filter_ports, &remote_port);
+    struct filter_rules* protocols = bpf_map_lookup_elem(&filter_protocols, &protocol);
+
+    // Every address and port has an entry, a missing one is a set being written
+    if (local_nets == NULL || remote_nets == NULL || local_ports == NULL || remote_ports == NULL || protocols == NULL) {
+        return rules->allow_rules == 0;
+    }
+
+    __u64 allow = 0;
+    __u64 deny = 0;
+
+    for (__u32 w = 0; w < FILTER_WORDS; w++) {
+        __u64 match = local_nets->bits[w] & remote_nets->bits[w] & local_ports->bits[w] &
+            remote_ports->bits[w] & protocols->bits[w];
+
+        allow |= match & rules->allow.bits[w];
+        deny |= match & rules->deny.bits[w];
+        if (match != 0) {
+            filter_hits_add(match, w);
+        }
+    }
+
+    if (deny != 0) {
+        return false;
+    }
+
+    return rules->allow_rules == 0 || allow != 0;
+}
+
+static inline bool prefix_match(const struct in6_addr* addr, const struct in6_addr* prefix, __u8 prefixlen) {
+    #pragma unroll
+    for (int i = 0; i < 4; i++) {
+        if (prefixlen == 0) {
+            return true;
+        }
+
+        __u32 bits = prefixlen > 32 ? 32 : prefixlen;
+        __u32 mask = bpf_htonl(bits == 32 ? 0xffffffff : ~(0xffffffff >> bits));
+
+        if ((addr->in6_u.u6_addr32[i] & mask) != (prefix->in6_u.u6_addr32[i] & mask)) {
+            return false;
+        }
+
+        prefixlen -= bits;
+    }
+
+    return true;
+}
+
+static inline bool endpoint_match(const struct in6_addr* addr, __be16 port, const struct in6_addr* prefix, __u8 prefixlen, __be16 filter_port) {
+    if (filter_port != 0 && filter_port != port) {
+        return false;
+    }
+
+    return prefix_match(addr, prefix, prefixlen);
+}
+
+// capture_match checks the packet against the filter in both directions
+static inline bool capture_match(const struct capture_filter* filter, const struct packet_t* pkt) {
+    if (filter->protocol != 0 && filter->protocol != pkt->protocol) {
+        return false;
+    }
+
+    if (endpoint_match(&pkt->src_ip, pkt->src_port, &filter->addr_a, filter->prefixlen_a, filter->port_a) &&
+        endpoint_match(&pkt->dst_ip, pkt->dst_port, &filter->addr_b, filter->prefixlen_b, filter->port_b)) {
+        return true;
+    }
+
+    return endpoint_match(&pkt->dst_ip, pkt->dst_port, &filter->addr_a, filter->prefixlen_a, filter->port_a) &&
+        endpoint_match(&pkt->src_ip, pkt->src_port, &filter->addr_b, filter->prefixlen_b, filter->port_b);
+}
+
+// capture_packet copies the first snaplen bytes of a matching packet to capture_pipe. The
+// packets of L3 devices get a zeroed Ethernet header, so every capture is Ethernet
+static inline void capture_packet(struct __sk_buff* skb, const struct packet_t* pkt, __u32 l2) {
+    if (pipe_transport != PIPE_RINGBUF) {
+        return;
+    }
+
+    __u32 key = 0;
+    struct capture_filter* filter = bpf_map_lookup_elem(&capture_filter, &key);
+
+    if (filter == NULL || !filter->enabled || !capture_match(filter, pkt)) {
+        return;
+    }
+
+    struct capture_event* event = bpf_ringbuf_reserve(&capture_pipe, sizeof(struct capture_event), 0);
+    if (event == NULL) {
+        return;
+    }
+
+    // 64 bits, so the length checked is the register passed to the helper
+    __u64 caplen = skb->len;
+    if (caplen > filter->snaplen) {
+        caplen = filter->snaplen;
+    }
+    if (caplen > CAPTURE_MAX_SNAPLEN - sizeof(struct ethhdr)) {
+        caplen = CAPTURE_MAX_SNAPLEN - sizeof(struct ethhdr);
+    }
+
+    __u32 fake = 0;
+    if (l2 == 0) {
+        struct ethhdr* eth = (void*)event->data;
+        __builtin_memset(eth, 0, sizeof(*eth));
+        eth->h_proto = skb->protocol;
+        fake = sizeof(struct ethhdr);
+    }
+
+    event->ts = pkt->ts;
+    event->len = skb->len + fake;
+    event->caplen = caplen + fake;
+    event->outbound = pkt->outbound;
+
+    if (caplen == 0 || bpf_skb_load_bytes(skb, 0, event->data + fake, caplen) < 0) {
+        bpf_ringbuf_discard(event, 0);
+        return;
+    }
+
+    bpf_ringbuf_submit(event, 0);
+}
+
 //aun no manejo el fin
 static inline int update_metrics(struct packet_t* pkt) {
     //empezando a conformar el flow id
-    struct flow_id flowid = {0};
+    struct flow_id flowid;
+    // The padding is part of the key
+    __builtin_memset(&flowid, 0, sizeof(flowid));
 
     flowid.protocol = pkt->protocol;
+    flowid.ifindex = pkt->ifindex;
+    flowid.netns = pkt->netns;
 
     if (pkt->outbound == true) { // outbound egress flow
         flowid.l_ip = pkt->src_ip;
@@ -192,29 +677,84 @@ static inline int update_metrics(struct packet_t* pkt) {
             return TC_ACT_OK;
         }
     } else {
-        if ((pkt->syn == true) || (pkt->protocol == IPPROTO_UDP)) {
-            struct flow_metrics new_flow = {0};
-            new_flow.ts_start = pkt->ts;
-            new_flow.ts_current = pkt->ts;
-            if (pkt->outbound == true) { //update outbound egress metrics
-                new_flow.packets_out = 1;
-                new_flow.bytes_out = pkt->len;
-            } 
-            else { //update inbound ingress metrics
-                new_flow.packets_in = 1;
-                new_flow.bytes_in = pkt->len;
-            }
-            long ret = bpf_map_update_elem(&flowstracker, &flowid, &new_flow, BPF_NOEXIST);
-            if (ret != 0) {
-                bpf_printk("error updating flow %d\n", ret);
-                return TC_ACT_OK;
-            }
+        // Flows are aggregated here while user space is overloaded, which may be mid-stream
+        struct flow_metrics new_flow = {0};
+        new_flow.ts_start = pkt->ts;
+        new_flow.ts_current = pkt->ts;
+        if (pkt->outbound == true) { //update outbound egress metrics
+            new_flow.packets_out = 1;
+            new_flow.bytes_out = pkt->len;
+        } 
+        else { //update inbound ingress metrics
+            new_flow.packets_in = 1;
+            new_flow.bytes_in = pkt->len;
+        }
+        long ret = bpf_map_update_elem(&flowstracker, &flowid, &new_flow, BPF_NOEXIST);
+        if (ret != 0) {
+            bpf_printk("error updating flow %d\n", ret);
+            return TC_ACT_OK;
         }
         return TC_ACT_OK;
     }
     return TC_ACT_OK;
 }
 
+// send_packet hands the packet to user space, or counts it in flowstracker when the agent
+// asked for in-kernel aggregation. The context is the skb or xdp_md of the program
+static inline void send_packet(void* ctx, struct packet_t* pkt) {
+    __u32 key = 0;
+    __u32 rate = sample_rate;
+    struct pipe_control* control = bpf_map_lookup_elem(&pipe_control, &key);
+
+    if (control != NULL && control->sample_factor > 1) {
+        rate *= control->sample_factor;
+    }
+
+    if (!sample_packet(pkt, rate)) {
+        return;
+    }
+
+    if (control != NULL && control->aggregate) {
+        update_metrics(pkt);
+        return;
+    }
+
+    struct pipe_stats* stats = bpf_map_lookup_elem(&pipe_stats, &key);
+
+    if (pipe_transport == PIPE_PERF_EVENT) {
+        if (bpf_perf_event_output(ctx, &perf_pipe, BPF_F_CURRENT_CPU, pkt, sizeof(*pkt)) < 0) {
+            if (stats != NULL) {
+                stats->drops += 1;
+            }
+        } else if (stats != NULL) {
+            stats->events += 1;
+        }
+        return;
+    }
+
+    void* ring = &pipe;
+
+    if (pipe_layout == PIPE_PER_CPU) {
+        __u32 cpu = bpf_get_smp_processor_id();
+        void* own = bpf_map_lookup_elem(&pipes, &cpu);
+        if (own != NULL) {
+            ring = own;
+        }
+    }
+
+    if (bpf_ringbuf_output(ring, pkt, sizeof(*pkt), 0) < 0) {
+        if (stats != NULL) {
+            stats->drops += 1;
+        }
+        return;
+    }
+
+    if (stats != NULL) {
+        stats->events += 1;
+        stats->avail_data = bpf_ringbuf_query(ring, BPF_RB_AVAIL_DATA);
+    }
+}
+
 SEC("classifier/ingress")
 int connstatsin(struct __sk_buff* skb) {
 
@@ -230,7 +770,9 @@ int connstatsin(struct __sk_buff* skb) {
     uint8_t* head = (uint8_t*)(long)skb->data;     // Start of the packet data
     uint8_t* tail = (uint8_t*)(long)skb->data_end; // End of the packet data
 
-    if (head + sizeof(struct ethhdr) > tail) { // Not an Ethernet frame
+    __u32 l2 = l2_header_len(skb);
+
+    if (head + l2 > tail) { // Shorter than its L2 header
         return TC_ACT_OK;
     }
 
@@ -239,9 +781,13 @@ int connstatsin(struct __sk_buff* skb) {
     uint32_t offset = 0;
 
     pkt.len = skb->len;
+    pkt.ifindex = skb->ifindex;
+    if (record_netns) {
+        pkt.netns = bpf_get_netns_cookie(skb);
+    }
     pkt.outbound = false;
 
-    if (handle_ip_packet(head, tail, &offset, &pkt) == TC_ACT_OK) {
+    if (handle_ip_packet(head, tail, &offset, &pkt, skb->protocol, l2) == TC_ACT_OK) {
         return TC_ACT_OK;
     }
 
@@ -254,13 +800,17 @@ int connstatsin(struct __sk_buff* skb) {
         return TC_ACT_OK;
     }
 
+    if (!filter_packet(&pkt)) {
+        return TC_ACT_OK;
+    }
+
     // if (update_metrics(&pkt) == TC_ACT_OK) {
     //     return TC_ACT_OK;
     // }
 
-    if (bpf_ringbuf_output(&pipe, &pkt, sizeof(pkt), 0) < 0) {
-        return TC_ACT_OK;
-    }
+    capture_packet(skb, &pkt, l2);
+
+    send_packet(skb, &pkt);
 
     return TC_ACT_OK;
 }
@@ -280,7 +830,9 @@ int connstatsout(struct __sk_buff* skb) {
     uint8_t* head = (uint8_t*)(long)skb->data;     // Start of the packet data
     uint8_t* tail = (uint8_t*)(long)skb->data_end; // End of the packet data
 
-    if (head + sizeof(struct ethhdr) > tail) { // Not an Ethernet frame
+    __u32 l2 = l2_header_len(skb);
+
+    if (head + l2 > tail) { // Shorter than its L2 header
         return TC_ACT_OK;
     }
 
@@ -289,9 +841,13 @@ int connstatsout(struct __sk_buff* skb) {
     uint32_t offset = 0;
 
     pkt.len = skb->len;
+    pkt.ifindex = skb->ifindex;
+    if (record_netns) {
+        pkt.netns = bpf_get_netns_cookie(skb);
+    }
     pkt.outbound = true;
 
-    if (handle_ip_packet(head, tail, &offset, &pkt) == TC_ACT_OK) {
+    if (handle_ip_packet(head, tail, &offset, &pkt, skb->protocol, l2) == TC_ACT_OK) {
         return TC_ACT_OK;
     }
 
@@ -304,15 +860,67 @@ int connstatsout(struct __sk_buff* skb) {
         return TC_ACT_OK;
     }
 
+    if (!filter_packet(&pkt)) {
+        return TC_ACT_OK;
+    }
+
     // if (update_metrics(&pkt) == TC_ACT_OK) {
     //    return TC_ACT_OK;
     // }
 
-    if (bpf_ringbuf_output(&pipe, &pkt, sizeof(pkt), 0) < 0) {
-        return TC_ACT_OK;
-    }
+    capture_packet(skb, &pkt, l2);
+
+    send_packet(skb, &pkt);
 
     return TC_ACT_OK;
 }
 
+// connstats_xdp counts the ingress packets like connstatsin, before the stack allocates
+// their skb. Packets are not captured, and have no netns cookie
+SEC("xdp")
+int connstats_xdp(struct xdp_md* ctx) {
+    uint8_t* head = (uint8_t*)(long)ctx->data;     // Start of the packet data
+    uint8_t* tail = (uint8_t*)(long)ctx->data_end; // End of the packet data
+
+    if (head + sizeof(struct ethhdr) > tail) { // Not an Ethernet frame
+        return XDP_PASS;
+    }
+
+    struct ethhdr* eth = (void*)head;
+
+    // We only want unicast packets
+    if (eth->h_dest[0] & 1) {
+        return XDP_PASS;
+    }
+
+    struct packet_t pkt = { 0 };
+
+    uint32_t offset = 0;
+
+    pkt.len = tail - head;
+    pkt.ifindex = ctx->ingress_ifindex;
+    pkt.outbound = false;
+
+    if (handle_ip_packet(head, tail, &offset, &pkt, eth->h_proto, sizeof(struct ethhdr)) == TC_ACT_OK) {
+        return XDP_PASS;
+    }
+
+    // Check if TCP/UDP header is fitting this packet
+    if (head + offset + sizeof(struct tcphdr) > tail || head + offset + sizeof(struct udphdr) > tail) {
+        return XDP_PASS;
+    }
+
+    if (handle_ip_segment(head, tail, &offset, &pkt) == TC_ACT_OK) {
+        return XDP_PASS;
+    }
+
+    if (!filter_packet(&pkt)) {
+        return XDP_PASS;
+    }
+
+    send_packet(ctx, &pkt);
+
+    return XDP_PASS;
+}
+
 char _license[] SEC("license") = "Dual MIT/GPL";
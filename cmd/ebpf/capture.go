@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	pb "github.com/gabspt/ConnectionStats/connstatsprotobuf"
+	"github.com/gabspt/ConnectionStats/internal/capture"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	captureDir = flag.String("capture-dir", os.TempDir(), "directory of the pcapng files written by StartCapture")
+	captures   = capture.NewManager()
+)
+
+// captureRequest converts the gRPC request to a capture request
+func captureRequest(req *pb.CaptureRequest) (capture.Request, error) {
+	aNet, err := capture.ParseNet(req.ANet)
+	if err != nil {
+		return capture.Request{}, status.Errorf(codes.InvalidArgument, "invalid a_net: %v", err)
+	}
+	bNet, err := capture.ParseNet(req.BNet)
+	if err != nil {
+		return capture.Request{}, status.Errorf(codes.InvalidArgument, "invalid b_net: %v", err)
+	}
+	if req.Proto > 255 || req.APort > 65535 || req.BPort > 65535 {
+		return capture.Request{}, status.Error(codes.InvalidArgument, "protocol or port out of range")
+	}
+
+	return capture.Request{
+		Filter: capture.Filter{
+			Proto: uint8(req.Proto),
+			ANet:  aNet,
+			APort: uint16(req.APort),
+			BNet:  bNet,
+			BPort: uint16(req.BPort),
+		},
+		Duration: time.Duration(req.DurationSec) * time.Second,
+		MaxBytes: req.MaxBytes,
+		SnapLen:  req.Snaplen,
+	}, nil
+}
+
+// startCapture starts a capture, mapping the manager errors to gRPC status codes
+func startCapture(req *pb.CaptureRequest) (*capture.Session, error) {
+	captureReq, err := captureRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := captures.Start(captureReq)
+	switch err {
+	case nil:
+		return session, nil
+	case capture.ErrBusy:
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case capture.ErrUnavailable:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// capturePath returns where the capture named by the client is written. Only base names
+// are accepted, so the files stay in the capture directory
+func capturePath(name string) (string, error) {
+	if name == "" {
+		name = fmt.Sprintf("capture-%s.pcapng", time.Now().Format("20060102T150405"))
+	}
+	if name == "." || name == ".." || filepath.Base(name) != name {
+		return "", status.Errorf(codes.InvalidArgument, "invalid capture name %q, expected a file name", name)
+	}
+	return filepath.Join(*captureDir, name), nil
+}
+
+func (s *server) StartCapture(ctx context.Context, req *pb.CaptureRequest) (*pb.CaptureReply, error) {
+	log.Printf("Received capture request")
+
+	path, err := capturePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never through an existing file, which could be a link planted in the directory
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return nil, status.Errorf(codes.AlreadyExists, "capture %v already exists", req.Path)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	session, err := startCapture(req)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	go func() {
+		if err := capture.WritePcapng(f, session, *ifaceFlag); err != nil {
+			log.Printf("Failed writing capture %v: %v", path, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Printf("Failed closing capture %v: %v", path, err)
+		}
+	}()
+
+	return &pb.CaptureReply{Path: path}, nil
+}
+
+func (s *server) StreamCapture(req *pb.CaptureRequest, stream pb.StatsService_StreamCaptureServer) error {
+	log.Printf("Received capture stream request")
+
+	session, err := startCapture(req)
+	if err != nil {
+		return err
+	}
+	defer session.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+
+		case frame, ok := <-session.Frames:
+			if !ok {
+				return nil
+			}
+			msg := &pb.CapturedPacket{
+				Ts:       uint64(timer.ToWallClock(frame.Timestamp).UnixNano()),
+				Length:   frame.Length,
+				Outbound: frame.Outbound,
+				Data:     frame.Data,
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -5,24 +5,62 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	//"sync"
 
 	pb "github.com/gabspt/ConnectionStats/connstatsprotobuf"
+	"github.com/gabspt/ConnectionStats/internal/capture"
+	"github.com/gabspt/ConnectionStats/internal/conntrack"
 	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
 	"github.com/gabspt/ConnectionStats/internal/probe"
-	"github.com/vishvananda/netlink"
+	"github.com/gabspt/ConnectionStats/internal/replay"
+	"github.com/gabspt/ConnectionStats/internal/timer"
 	"google.golang.org/grpc"
 )
 
 var (
-	ifaceFlag = flag.String("interface", "enp0s3", "interface to attach the probe to") // TODO: change default value to eth0
-	port      = flag.Int("port", 50051, "The server port")
-	ft        = flowtable.NewFlowTable()
+	ifaceFlag   = flag.String("interface", "enp0s3", "comma separated interfaces to attach the probe to, globs such as veth* follow the interfaces as they appear, and a netns/ or pid:1234/ prefix selects them in another network namespace") // TODO: change default value to eth0
+	port        = flag.Int("port", 50051, "The server port")
+	activeTO    = flag.Duration("active-timeout", 0, "emit interim records of active flows this often, 0 disables them")
+	cidSeed     = flag.Uint("community-id-seed", 0, "seed of the Community ID flow hashes, from 0 to 65535")
+	readFlag    = flag.String("read", "", "replay this pcap or pcapng file instead of attaching to the interface")
+	speedFlag   = flag.Float64("replay-speed", 0, "replay speed factor, 1 is real time and 0 as fast as possible")
+	localNets   = flag.String("local-nets", "", "comma separated prefixes of the local hosts when replaying, private addresses by default")
+	sampleRate  = flag.Uint("sample-rate", 1, "send 1 in this many packets to user space, 1 disables sampling")
+	sampleMode  = flag.String("sample-mode", "count", "how the sampled packets are chosen: count, random or flow")
+	extrapolate = flag.Bool("extrapolate", false, "report packet and byte counters scaled by the sampling rate")
+	bpInterval  = flag.Duration("backpressure-interval", probe.DefaultBackpressure.Interval, "how often the event pipe is checked for backpressure, 0 disables the controller")
+	bpHighFill  = flag.Float64("backpressure-high", probe.DefaultBackpressure.HighFill, "ringbuf fill fraction raising the sampling rate")
+	bpLowFill   = flag.Float64("backpressure-low", probe.DefaultBackpressure.LowFill, "ringbuf fill fraction below which the sampling rate recovers")
+	bpMaxFactor = flag.Uint("backpressure-max-factor", uint(probe.DefaultBackpressure.MaxFactor), "largest sampling rate multiplier before aggregating flows in the kernel")
+	workers     = flag.Int("workers", 0, "goroutines updating the flow table, the number of CPUs when 0")
+	batchSize   = flag.Int("batch-size", pipeline.DefaultBatchSize, "events handed to a worker at once")
+	maxFlows    = flag.Int("max-flows", 1<<20, "connections kept in the flow table, 0 is unlimited")
+	flowMemory  = flag.Int64("flow-memory", 0, "estimated bytes the flow table may take, 0 is unlimited")
+	evictFlag   = flag.String("eviction", "lru", "connections evicted first from a full flow table: lru or oldest")
+	midStream   = flag.Bool("mid-stream", false, "also count TCP connections whose SYN was not seen, such as those open before the agent started, guessing their initiator")
+	pipeLayout  = flag.String("pipe-layout", "shared", "how the probe sends events: shared, a single ringbuf, or percpu, a ringbuf per CPU read in parallel")
+	transport   = flag.String("pipe-transport", "auto", "map the probe sends events through: ringbuf, perf, or auto to use ringbuf when the kernel supports it")
+	attachFlag  = flag.String("attach", "auto", "how the probe attaches to the interfaces: tcx links, netlink with a clsact qdisc, or auto to use tcx when the kernel supports it")
+	pinPath     = flag.String("pin-path", "", "bpffs directory to pin the tcx links and maps in, so they outlive a restart")
+	ingressFlag = flag.String("ingress", "tc", "hook of the ingress program: tc, xdp-native, xdp-generic, or xdp to use native XDP when the driver supports it. XDP only watches the interfaces of the agent's namespace and disables the packet captures")
+	sourceFlag  = flag.String("source", "ebpf", "where the flows are read from: ebpf programs on the interfaces, afpacket rings, or the conntrack entries, for the hosts that forbid loading eBPF programs")
+	natFlag     = flag.Bool("conntrack", false, "join the flows to the conntrack entries, reporting their addresses before and after NAT")
+	monitor     = &probe.Monitor{}
+	ft          = flowtable.NewFlowTable()
+	locals      flowtable.Locals    // addresses orienting the connections, by their direction when nil
+	nats        *conntrack.NATTable // translations of the connections, nil without -conntrack
+	hostNetns   uint64              // netns cookie of the agent's namespace, 0 when unknown
 	//ftMutex   sync.RWMutex
 	//ctx       context.Context
 	//cancel    context.CancelFunc
@@ -62,6 +100,96 @@ func displayInterfaces() {
 //	return ctx, cancel
 //}
 
+// replayOptions builds the replay options from the flags
+func replayOptions(clock *timer.Manual) replay.Options {
+	opts := replay.Options{Speed: *speedFlag, Clock: clock}
+
+	for _, cidr := range strings.Split(*localNets, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			log.Fatalf("Invalid local network %v: %v", cidr, err)
+		}
+		opts.LocalNets = append(opts.LocalNets, prefix)
+	}
+
+	return opts
+}
+
+// probeConfig builds the probe configuration from the flags
+func probeConfig() probe.Config {
+	mode, err := probe.ParseSampleMode(*sampleMode)
+	if err != nil {
+		log.Fatalf("Invalid sampling mode: %v", err)
+	}
+
+	layout, err := probe.ParsePipeLayout(*pipeLayout)
+	if err != nil {
+		log.Fatalf("Invalid pipe layout: %v", err)
+	}
+
+	pipeTransport, err := probe.ParseTransport(*transport)
+	if err != nil {
+		log.Fatalf("Invalid pipe transport: %v", err)
+	}
+
+	attachMode, err := probe.ParseAttachMode(*attachFlag)
+	if err != nil {
+		log.Fatalf("Invalid attach mode: %v", err)
+	}
+
+	ingress, err := probe.ParseIngressMode(*ingressFlag)
+	if err != nil {
+		log.Fatalf("Invalid ingress mode: %v", err)
+	}
+
+	// The XDP ingress program copies no packets, so the captures are unavailable with it
+	var captureManager *capture.Manager
+	if ingress == probe.IngressTC {
+		captureManager = captures
+	}
+
+	return probe.Config{
+		Capture:    captureManager,
+		Filter:     filters,
+		Attach:     attachMode,
+		Ingress:    ingress,
+		PinPath:    *pinPath,
+		MaxFlows:   *maxFlows,
+		PipeLayout: layout,
+		Transport:  pipeTransport,
+		SampleRate: uint32(*sampleRate),
+		SampleMode: mode,
+		Backpressure: probe.Backpressure{
+			Interval:  *bpInterval,
+			HighFill:  *bpHighFill,
+			LowFill:   *bpLowFill,
+			MaxFactor: uint32(*bpMaxFactor),
+			Recover:   probe.DefaultBackpressure.Recover,
+		},
+		Monitor: monitor,
+	}
+}
+
+// flowSource returns the source of the packets selected by the flags
+func flowSource(ifaces probe.Interfaces) probe.FlowSource {
+	switch *sourceFlag {
+	case "ebpf":
+		return probe.NewEBPFSource(ifaces, probeConfig())
+	case "afpacket":
+		if *sampleRate > 1 {
+			log.Printf("The afpacket source does not sample, sending every packet")
+		}
+		return probe.NewPacketSource(ifaces)
+	case "conntrack":
+		return conntrack.NewSource(10 * time.Second)
+	}
+	log.Fatalf("Invalid source %q, expected ebpf, afpacket or conntrack", *sourceFlag)
+	return nil
+}
+
 // server is used to implement ConnStatServer.
 type server struct {
 	pb.UnimplementedStatsServiceServer
@@ -80,19 +208,38 @@ func (s *server) CollectStats(ctx context.Context, req *pb.StatsRequest) (*pb.St
 	connlist := ft.GetConnList()
 	//fmt.Printf("connlist %v\n", connlist)
 	for _, conn := range connlist {
+		if *extrapolate {
+			conn = conn.Extrapolated()
+		}
 		connMsg := &pb.ConnectionStat{
-			Hash:       conn.Hash,
-			Proto:      conn.Proto,
-			AIp:        conn.AIp.String(),
-			BIp:        conn.BIp.String(),
-			APort:      uint32(conn.APort),
-			BPort:      uint32(conn.BPort),
-			PacketsIn:  conn.Packets_in,
-			PacketsOut: conn.Packets_out,
-			TsIni:      conn.Ts_ini,
-			TsFin:      conn.Ts_fin,
-			BytesIn:    conn.Bytes_in,
-			BytesOut:   conn.Bytes_out,
+			Hash:        conn.Hash,
+			Proto:       conn.Proto,
+			AIp:         conn.AIp.String(),
+			BIp:         conn.BIp.String(),
+			APort:       uint32(conn.APort),
+			BPort:       uint32(conn.BPort),
+			PacketsIn:   conn.Packets_in,
+			PacketsOut:  conn.Packets_out,
+			TsIni:       conn.Ts_ini,
+			TsFin:       conn.Ts_fin,
+			BytesIn:     conn.Bytes_in,
+			BytesOut:    conn.Bytes_out,
+			CommunityId: conn.CommunityID,
+			SampleRate:  conn.SampleRate,
+			Estimated:   conn.Estimated,
+			Ifindex:     conn.Ifindex,
+			Netns:       conn.Netns,
+			MidStream:   conn.MidStream,
+		}
+		local, remote := conn.Endpoints(locals)
+		connMsg.LocalIp, connMsg.LocalPort = local.IP.String(), uint32(local.Port)
+		connMsg.RemoteIp, connMsg.RemotePort = remote.IP.String(), uint32(remote.Port)
+		connMsg.LocalInitiator = conn.ALocal(locals)
+		connMsg.ServerPort = uint32(conn.ServerPort())
+		connMsg.PacketsToServer, connMsg.BytesToServer = conn.ToServer()
+		connMsg.PacketsToClient, connMsg.BytesToClient = conn.ToClient()
+		if nats != nil {
+			setTranslation(connMsg, conn)
 		}
 		//fmt.Printf("connMsg %v\n", connMsg)
 		response.Connstat = append(response.Connstat, connMsg)
@@ -101,17 +248,106 @@ func (s *server) CollectStats(ctx context.Context, req *pb.StatsRequest) (*pb.St
 	return response, nil
 }
 
+// setTranslation reports the NAT of the connection, if any
+func setTranslation(connMsg *pb.ConnectionStat, conn flowtable.Connection) {
+	// nats follows the conntrack of the host, whose tuples may collide with other namespaces
+	if conn.Netns != 0 && conn.Netns != hostNetns {
+		return
+	}
+	proto := uint8(syscall.IPPROTO_TCP)
+	if conn.Proto == "UDP" {
+		proto = syscall.IPPROTO_UDP
+	}
+	nat, ok := nats.Lookup(proto, netip.AddrPortFrom(conn.AIp, conn.APort), netip.AddrPortFrom(conn.BIp, conn.BPort))
+	if !ok {
+		return
+	}
+
+	connMsg.Nat = true
+	connMsg.PreNatSrcIp, connMsg.PreNatSrcPort = nat.PreNAT.Src.Unmap().String(), uint32(nat.PreNAT.SrcPort)
+	connMsg.PreNatDstIp, connMsg.PreNatDstPort = nat.PreNAT.Dst.Unmap().String(), uint32(nat.PreNAT.DstPort)
+	connMsg.PostNatSrcIp, connMsg.PostNatSrcPort = nat.PostNAT.Src.Unmap().String(), uint32(nat.PostNAT.SrcPort)
+	connMsg.PostNatDstIp, connMsg.PostNatDstPort = nat.PostNAT.Dst.Unmap().String(), uint32(nat.PostNAT.DstPort)
+}
+
+func (s *server) PipeStats(ctx context.Context, req *pb.PipeStatsRequest) (*pb.PipeStatsReply, error) {
+	stats := monitor.Stats()
+
+	return &pb.PipeStatsReply{
+		Events:       stats.Events,
+		Drops:        stats.Drops,
+		DropsPerCpu:  stats.DropsPerCPU,
+		Fill:         stats.Fill,
+		SampleFactor: stats.SampleFactor,
+		Aggregating:  stats.Aggregating,
+	}, nil
+}
+
+func (s *server) TableStats(ctx context.Context, req *pb.TableStatsRequest) (*pb.TableStatsReply, error) {
+	stats := ft.Stats()
+
+	return &pb.TableStatsReply{
+		Flows:        uint64(stats.Flows),
+		MaxFlows:     uint64(stats.MaxFlows),
+		MemoryBytes:  uint64(stats.MemoryBytes),
+		MemoryBudget: uint64(stats.MemoryBudget),
+		Evictions:    stats.Evictions,
+	}, nil
+}
+
+func (s *server) Attachments(ctx context.Context, req *pb.AttachmentsRequest) (*pb.AttachmentsReply, error) {
+	reply := &pb.AttachmentsReply{}
+
+	for _, a := range monitor.Attachments() {
+		reply.Attachments = append(reply.Attachments, &pb.Attachment{
+			Namespace: a.Namespace,
+			Interface: a.Interface,
+			Ifindex:   uint32(a.Index),
+			Ingress:   a.Ingress,
+			Egress:    a.Egress,
+		})
+	}
+
+	return reply, nil
+}
+
 func main() {
 	flag.Parse()
 
+	// "ebpf [flags] doctor" checks what the probe needs instead of running it
+	if flag.Arg(0) == "doctor" {
+		runDoctor()
+	}
+
+	if *cidSeed > math.MaxUint16 {
+		log.Fatalf("Invalid community ID seed %v, expected at most %v", *cidSeed, math.MaxUint16)
+	}
+
 	//ctx, cancel = createContextAndCancel()
 
-	//Configure probe's network interface
-	iface, errint := netlink.LinkByName(*ifaceFlag)
-	if errint != nil {
-		log.Printf("Could not find interface %v: %v", *ifaceFlag, errint)
-		displayInterfaces()
+	//Configure probe's network interfaces, not needed when replaying a capture
+	ifaces, err := probe.ParseInterfaces(*ifaceFlag)
+	if err != nil {
+		log.Fatalf("Invalid interfaces: %v", err)
+	}
+	if *readFlag == "" && *sourceFlag != "conntrack" && !strings.ContainsAny(*ifaceFlag, "*?[/") {
+		if links, err := ifaces.Links(); err != nil || len(links) < len(ifaces) {
+			log.Printf("Could not find interfaces %v", *ifaceFlag)
+			displayInterfaces()
+		}
+	}
+
+	ft.ActiveTimeout = *activeTO
+	ft.CommunityIDSeed = uint16(*cidSeed)
+	ft.MaxFlows = *maxFlows
+	ft.MemoryBudget = *flowMemory
+
+	eviction, err := flowtable.ParseEvictionPolicy(*evictFlag)
+	if err != nil {
+		log.Fatalf("Invalid eviction policy: %v", err)
 	}
+	ft.Eviction = eviction
+	ft.PickupMidStream = *midStream
 
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
@@ -119,6 +355,26 @@ func main() {
 	signalHandler(cancel)
 	//signalHandler()
 
+	// Replayed captures are oriented by their direction, from the local nets
+	if *readFlag == "" {
+		if hostNetns, err = probe.HostNetns(); err != nil {
+			log.Printf("Failed getting the cookie of the host network namespace: %v", err)
+		}
+		addrs := packet.NewInterfaceAddrs(hostNetns)
+		locals = addrs
+		go addrs.Run(ctx, 30*time.Second)
+	}
+
+	createFilters()
+
+	sinks := createSinks()
+	ft.OnEvent(func(ev flowtable.Event) {
+		if *extrapolate {
+			ev.Conn = ev.Conn.Extrapolated()
+		}
+		sinks.Emit(ev)
+	})
+
 	//Configure gRPC server
 	go func() {
 		lis, errlis := net.Listen("tcp", fmt.Sprintf(":%d", *port))
@@ -135,9 +391,52 @@ func main() {
 
 	}()
 
-	//Run the probe. Pass the context and the network interface
-	if err := probe.Run(ctx, iface, ft); err != nil {
-		log.Fatalf("Failed running the probe: %v", err)
+	cfg := pipeline.Config{Workers: *workers, BatchSize: *batchSize}
+
+	if *readFlag != "" {
+		// The flow tracking follows the capture time
+		clock := &timer.Manual{}
+		timer.SetClock(clock)
+		if err := probe.Run(ctx, replay.NewSource(*readFlag, replayOptions(clock)), ft, cfg); err != nil {
+			log.Fatalf("Failed replaying %v: %v", *readFlag, err)
+		}
+		log.Println("Serving the replayed flows until interrupted")
+		<-ctx.Done()
+	} else {
+		if *midStream {
+			listeners := packet.NewListeners()
+			ft.Roles = listeners
+			go listeners.Run(ctx, 30*time.Second)
+		}
+		if *natFlag {
+			nats = conntrack.NewNATTable()
+			go func() {
+				if err := nats.Run(ctx); err != nil {
+					log.Printf("Stopped following conntrack: %v", err)
+				}
+			}()
+		}
+		restoreSnapshot()
+		saving := saveSnapshots(ctx)
+
+		//Run the probe. Pass the context and the network interface
+		if err := probe.Run(ctx, flowSource(ifaces), ft, cfg); err != nil {
+			log.Fatalf("Failed running the probe: %v, run with the doctor command for diagnostics", err)
+		}
+
+		// The periodic saves stop before the final one, which they would race or overwrite
+		cancel()
+		<-saving
+	}
+
+	if *readFlag != "" {
+		ft.Flush(flowtable.EndShutdown)
+	} else {
+		shutdownFlows()
+	}
+
+	if err := sinks.Close(); err != nil {
+		log.Printf("Failed closing sinks: %v", err)
 	}
 
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/gabspt/ConnectionStats/internal/doctor"
+	"github.com/gabspt/ConnectionStats/internal/probe"
+)
+
+// runDoctor prints the diagnostics of the kernel, the process and the interface, then exits
+// with status 1 when the probe would fail to load
+func runDoctor() {
+	var names []string
+
+	ifaces, err := probe.ParseInterfaces(*ifaceFlag)
+	if err != nil {
+		log.Fatalf("Invalid interfaces: %v", err)
+	}
+	// Patterns matching nothing are checked as names, to report them missing
+	links, _ := ifaces.Links()
+	for _, link := range links {
+		names = append(names, link.Attrs().Name)
+	}
+	if len(names) == 0 {
+		names = ifaces
+	}
+
+	report := doctor.Run(names...)
+
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("Failed writing the diagnostics: %v", err)
+	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	pb "github.com/gabspt/ConnectionStats/connstatsprotobuf"
+	"github.com/gabspt/ConnectionStats/internal/filter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	filterFlag = flag.String("filter", "", "semicolon separated filter rules, e.g. \"deny remote 10.0.0.0/8; allow local port 80-443/tcp; deny remote 10.1.0.0/16 remote port 53/udp\"")
+	filterSelf = flag.Bool("filter-self", true, "do not monitor the traffic of the gRPC server")
+	filters    = filter.NewManager()
+)
+
+// createFilters installs the filter rules given by the flags
+func createFilters() {
+	rules := []filter.Rule{}
+
+	if *filterSelf {
+		rules = append(rules, filter.Rule{Action: filter.Deny, LocalPorts: filter.PortRange{Min: uint16(*port), Max: uint16(*port)}, Proto: 6})
+	}
+
+	for _, s := range strings.Split(*filterFlag, ";") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		rule, err := filter.ParseRule(s)
+		if err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := filters.Update(rules, nil); err != nil {
+		log.Fatalf("Failed adding filter rules: %v", err)
+	}
+}
+
+func filterReply() (*pb.FilterReply, error) {
+	stats, err := filters.Rules()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	response := &pb.FilterReply{}
+	for _, rule := range stats {
+		response.Rules = append(response.Rules, &pb.FilterRule{
+			Id:   rule.ID,
+			Rule: rule.String(),
+			Hits: rule.Hits,
+		})
+	}
+	return response, nil
+}
+
+func (s *server) UpdateFilters(ctx context.Context, req *pb.FilterRequest) (*pb.FilterReply, error) {
+	log.Printf("Received filter update")
+
+	add := []filter.Rule{}
+	for _, s := range req.Add {
+		rule, err := filter.ParseRule(s)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		add = append(add, rule)
+	}
+
+	if err := filters.Update(add, req.Remove); err != nil {
+		switch {
+		case errors.Is(err, filter.ErrNotFound):
+			return nil, status.Error(codes.NotFound, err.Error())
+		case errors.Is(err, filter.ErrTooManyRules):
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, fmt.Sprintf("installing rules: %v", err))
+		}
+	}
+
+	return filterReply()
+}
+
+func (s *server) ListFilters(ctx context.Context, req *pb.FilterListRequest) (*pb.FilterReply, error) {
+	return filterReply()
+}
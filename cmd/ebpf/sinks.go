@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/dataset"
+	"github.com/gabspt/ConnectionStats/internal/flowlog"
+	"github.com/gabspt/ConnectionStats/internal/sink"
+)
+
+var (
+	logFlows   = flag.Bool("log-flows", true, "log the start and end of every flow")
+	sinkQueue  = flag.Int("sink-queue", sink.DefaultConfig.QueueSize, "events buffered per sink before new ones are dropped")
+	sinkRetry  = flag.Int("sink-retries", sink.DefaultConfig.MaxRetries, "times a failed sink write is retried")
+	pqDir      = flag.String("parquet-dir", "", "write flow records as parquet files to this directory")
+	pqMaxSize  = flag.Int64("parquet-max-size", 64*1024*1024, "rotate the parquet file once it reaches this many bytes")
+	pqMaxAge   = flag.Duration("parquet-max-age", time.Hour, "rotate the parquet file once it has been open this long")
+	logPath    = flag.String("flowlog", "", "append flow records to this file")
+	logFormat  = flag.String("flowlog-format", flowlog.JSON, "flow log format: json (one record per line), csv, zeek (conn.log TSV) or zeek-json")
+	logMaxSize = flag.Int64("flowlog-max-size", 100*1024*1024, "rotate the flow log once it reaches this many bytes")
+	logMaxAge  = flag.Duration("flowlog-max-age", 24*time.Hour, "rotate the flow log once it has been open this long")
+	logGzip    = flag.Bool("flowlog-compress", true, "gzip the rotated flow logs")
+	logKeep    = flag.Int("flowlog-max-backups", 7, "number of rotated flow logs to keep, 0 keeps all of them")
+)
+
+// createSinks builds the registry with every sink enabled by the flags
+func createSinks() *sink.Registry {
+	registry := sink.NewRegistry()
+
+	cfg := sink.DefaultConfig
+	cfg.QueueSize = *sinkQueue
+	cfg.MaxRetries = *sinkRetry
+
+	if *logFlows {
+		registry.Add(sink.Log{}, cfg)
+	}
+
+	if *pqDir != "" {
+		writer, err := dataset.NewWriter(dataset.Config{
+			Dir:     *pqDir,
+			MaxSize: *pqMaxSize,
+			MaxAge:  *pqMaxAge,
+		})
+		if err != nil {
+			log.Fatalf("Failed creating parquet writer: %v", err)
+		}
+		registry.Add(writer, cfg)
+	}
+
+	if *logPath != "" {
+		writer, err := flowlog.NewWriter(flowlog.Config{
+			Path:       *logPath,
+			Format:     *logFormat,
+			MaxSize:    *logMaxSize,
+			MaxAge:     *logMaxAge,
+			Compress:   *logGzip,
+			MaxBackups: *logKeep,
+			Locals:     locals,
+		})
+		if err != nil {
+			log.Fatalf("Failed creating flow log: %v", err)
+		}
+		registry.Add(writer, cfg)
+	}
+
+	return registry
+}
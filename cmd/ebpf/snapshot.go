@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+)
+
+var (
+	snapshotPath     = flag.String("snapshot", "", "save the flow table to this file at shutdown and periodically, and restore it at startup, so flows continue across restarts; use with -pin-path to keep the kernel maps too")
+	snapshotInterval = flag.Duration("snapshot-interval", time.Minute, "how often the flow table snapshot is saved, 0 saves it only at shutdown")
+)
+
+// restoreSnapshot loads the flows saved by the previous run
+func restoreSnapshot() {
+	if *snapshotPath == "" {
+		return
+	}
+
+	n, err := ft.LoadSnapshot(*snapshotPath)
+	switch {
+	case errors.Is(err, flowtable.ErrStaleSnapshot):
+		log.Printf("Not restoring the flows of %v: %v", *snapshotPath, err)
+	case err != nil:
+		log.Printf("Failed restoring the flows of %v, %d restored: %v", *snapshotPath, n, err)
+	default:
+		log.Printf("Restored %d flows from %v", n, *snapshotPath)
+	}
+}
+
+// saveSnapshots saves the flow table every snapshot interval until ctx is done. The returned
+// channel is closed once the last save is over
+func saveSnapshots(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if *snapshotPath == "" || *snapshotInterval <= 0 {
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(*snapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ft.SaveSnapshot(*snapshotPath); err != nil {
+					log.Printf("Failed saving the flow table snapshot: %v", err)
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// shutdownFlows ends the flows at shutdown. With a snapshot they are saved instead, and
+// continue in the next run without emitting their end
+func shutdownFlows() {
+	if *snapshotPath != "" {
+		err := ft.SaveSnapshot(*snapshotPath)
+		if err == nil {
+			log.Printf("Saved %d flows to %v", ft.Stats().Flows, *snapshotPath)
+			return
+		}
+		log.Printf("Failed saving the flow table snapshot, ending its flows: %v", err)
+	}
+
+	ft.Flush(flowtable.EndShutdown)
+}
@@ -25,18 +25,47 @@ type ConnectionStat struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Hash       uint64 `protobuf:"varint,1,opt,name=hash,proto3" json:"hash,omitempty"`
-	Proto      string `protobuf:"bytes,2,opt,name=proto,proto3" json:"proto,omitempty"`
-	AIp        string `protobuf:"bytes,3,opt,name=a_ip,json=aIp,proto3" json:"a_ip,omitempty"` //netip.Addr
-	BIp        string `protobuf:"bytes,4,opt,name=b_ip,json=bIp,proto3" json:"b_ip,omitempty"` //netip.Addr
-	APort      uint32 `protobuf:"varint,5,opt,name=a_port,json=aPort,proto3" json:"a_port,omitempty"`
-	BPort      uint32 `protobuf:"varint,6,opt,name=b_port,json=bPort,proto3" json:"b_port,omitempty"`
-	PacketsIn  uint64 `protobuf:"varint,7,opt,name=packets_in,json=packetsIn,proto3" json:"packets_in,omitempty"`
-	PacketsOut uint64 `protobuf:"varint,8,opt,name=packets_out,json=packetsOut,proto3" json:"packets_out,omitempty"`
-	TsIni      uint64 `protobuf:"varint,9,opt,name=ts_ini,json=tsIni,proto3" json:"ts_ini,omitempty"`
-	TsFin      uint64 `protobuf:"varint,10,opt,name=ts_fin,json=tsFin,proto3" json:"ts_fin,omitempty"`
-	BytesIn    uint64 `protobuf:"varint,11,opt,name=bytes_in,json=bytesIn,proto3" json:"bytes_in,omitempty"`
-	BytesOut   uint64 `protobuf:"varint,12,opt,name=bytes_out,json=bytesOut,proto3" json:"bytes_out,omitempty"`
+	Hash        uint64 `protobuf:"varint,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Proto       string `protobuf:"bytes,2,opt,name=proto,proto3" json:"proto,omitempty"`
+	AIp         string `protobuf:"bytes,3,opt,name=a_ip,json=aIp,proto3" json:"a_ip,omitempty"` //netip.Addr
+	BIp         string `protobuf:"bytes,4,opt,name=b_ip,json=bIp,proto3" json:"b_ip,omitempty"` //netip.Addr
+	APort       uint32 `protobuf:"varint,5,opt,name=a_port,json=aPort,proto3" json:"a_port,omitempty"`
+	BPort       uint32 `protobuf:"varint,6,opt,name=b_port,json=bPort,proto3" json:"b_port,omitempty"`
+	PacketsIn   uint64 `protobuf:"varint,7,opt,name=packets_in,json=packetsIn,proto3" json:"packets_in,omitempty"`
+	PacketsOut  uint64 `protobuf:"varint,8,opt,name=packets_out,json=packetsOut,proto3" json:"packets_out,omitempty"`
+	TsIni       uint64 `protobuf:"varint,9,opt,name=ts_ini,json=tsIni,proto3" json:"ts_ini,omitempty"`
+	TsFin       uint64 `protobuf:"varint,10,opt,name=ts_fin,json=tsFin,proto3" json:"ts_fin,omitempty"`
+	BytesIn     uint64 `protobuf:"varint,11,opt,name=bytes_in,json=bytesIn,proto3" json:"bytes_in,omitempty"`
+	BytesOut    uint64 `protobuf:"varint,12,opt,name=bytes_out,json=bytesOut,proto3" json:"bytes_out,omitempty"`
+	CommunityId string `protobuf:"bytes,13,opt,name=community_id,json=communityId,proto3" json:"community_id,omitempty"` // Community ID v1 flow hash
+	SampleRate  uint32 `protobuf:"varint,14,opt,name=sample_rate,json=sampleRate,proto3" json:"sample_rate,omitempty"`   // counters were taken from 1 in sample_rate packets
+	Estimated   bool   `protobuf:"varint,15,opt,name=estimated,proto3" json:"estimated,omitempty"`                       // counters were scaled by sample_rate
+	Ifindex     uint32 `protobuf:"varint,16,opt,name=ifindex,proto3" json:"ifindex,omitempty"`                           // interface the connection was seen on, 0 when unknown
+	Netns       uint64 `protobuf:"varint,17,opt,name=netns,proto3" json:"netns,omitempty"`                               // cookie of the network namespace of the interface, 0 when unknown
+	MidStream   bool   `protobuf:"varint,18,opt,name=mid_stream,json=midStream,proto3" json:"mid_stream,omitempty"`      // picked up after the handshake: counters are partial and the initiator, a, is a guess
+	// a is the initiator, the client, and b the server. The local end has an address of the
+	// interface, or sends the outbound packets when the addresses do not tell
+	LocalIp         string `protobuf:"bytes,19,opt,name=local_ip,json=localIp,proto3" json:"local_ip,omitempty"`
+	LocalPort       uint32 `protobuf:"varint,20,opt,name=local_port,json=localPort,proto3" json:"local_port,omitempty"`
+	RemoteIp        string `protobuf:"bytes,21,opt,name=remote_ip,json=remoteIp,proto3" json:"remote_ip,omitempty"`
+	RemotePort      uint32 `protobuf:"varint,22,opt,name=remote_port,json=remotePort,proto3" json:"remote_port,omitempty"`
+	LocalInitiator  bool   `protobuf:"varint,23,opt,name=local_initiator,json=localInitiator,proto3" json:"local_initiator,omitempty"` // the local end opened the connection
+	ServerPort      uint32 `protobuf:"varint,24,opt,name=server_port,json=serverPort,proto3" json:"server_port,omitempty"`
+	PacketsToServer uint64 `protobuf:"varint,25,opt,name=packets_to_server,json=packetsToServer,proto3" json:"packets_to_server,omitempty"` // sent by the client
+	BytesToServer   uint64 `protobuf:"varint,26,opt,name=bytes_to_server,json=bytesToServer,proto3" json:"bytes_to_server,omitempty"`
+	PacketsToClient uint64 `protobuf:"varint,27,opt,name=packets_to_client,json=packetsToClient,proto3" json:"packets_to_client,omitempty"` // sent by the server
+	BytesToClient   uint64 `protobuf:"varint,28,opt,name=bytes_to_client,json=bytesToClient,proto3" json:"bytes_to_client,omitempty"`
+	// translation of the connection by the conntrack of the agent, when -conntrack is set:
+	// pre_nat as sent by the client, post_nat as it leaves the NAT for the server
+	Nat            bool   `protobuf:"varint,29,opt,name=nat,proto3" json:"nat,omitempty"`
+	PreNatSrcIp    string `protobuf:"bytes,30,opt,name=pre_nat_src_ip,json=preNatSrcIp,proto3" json:"pre_nat_src_ip,omitempty"`
+	PreNatSrcPort  uint32 `protobuf:"varint,31,opt,name=pre_nat_src_port,json=preNatSrcPort,proto3" json:"pre_nat_src_port,omitempty"`
+	PreNatDstIp    string `protobuf:"bytes,32,opt,name=pre_nat_dst_ip,json=preNatDstIp,proto3" json:"pre_nat_dst_ip,omitempty"`
+	PreNatDstPort  uint32 `protobuf:"varint,33,opt,name=pre_nat_dst_port,json=preNatDstPort,proto3" json:"pre_nat_dst_port,omitempty"`
+	PostNatSrcIp   string `protobuf:"bytes,34,opt,name=post_nat_src_ip,json=postNatSrcIp,proto3" json:"post_nat_src_ip,omitempty"`
+	PostNatSrcPort uint32 `protobuf:"varint,35,opt,name=post_nat_src_port,json=postNatSrcPort,proto3" json:"post_nat_src_port,omitempty"`
+	PostNatDstIp   string `protobuf:"bytes,36,opt,name=post_nat_dst_ip,json=postNatDstIp,proto3" json:"post_nat_dst_ip,omitempty"`
+	PostNatDstPort uint32 `protobuf:"varint,37,opt,name=post_nat_dst_port,json=postNatDstPort,proto3" json:"post_nat_dst_port,omitempty"`
 }
 
 func (x *ConnectionStat) Reset() {
@@ -155,6 +184,181 @@ func (x *ConnectionStat) GetBytesOut() uint64 {
 	return 0
 }
 
+func (x *ConnectionStat) GetCommunityId() string {
+	if x != nil {
+		return x.CommunityId
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetSampleRate() uint32 {
+	if x != nil {
+		return x.SampleRate
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetEstimated() bool {
+	if x != nil {
+		return x.Estimated
+	}
+	return false
+}
+
+func (x *ConnectionStat) GetIfindex() uint32 {
+	if x != nil {
+		return x.Ifindex
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetNetns() uint64 {
+	if x != nil {
+		return x.Netns
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetMidStream() bool {
+	if x != nil {
+		return x.MidStream
+	}
+	return false
+}
+
+func (x *ConnectionStat) GetLocalIp() string {
+	if x != nil {
+		return x.LocalIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetLocalPort() uint32 {
+	if x != nil {
+		return x.LocalPort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetRemoteIp() string {
+	if x != nil {
+		return x.RemoteIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetRemotePort() uint32 {
+	if x != nil {
+		return x.RemotePort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetLocalInitiator() bool {
+	if x != nil {
+		return x.LocalInitiator
+	}
+	return false
+}
+
+func (x *ConnectionStat) GetServerPort() uint32 {
+	if x != nil {
+		return x.ServerPort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetPacketsToServer() uint64 {
+	if x != nil {
+		return x.PacketsToServer
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetBytesToServer() uint64 {
+	if x != nil {
+		return x.BytesToServer
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetPacketsToClient() uint64 {
+	if x != nil {
+		return x.PacketsToClient
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetBytesToClient() uint64 {
+	if x != nil {
+		return x.BytesToClient
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetNat() bool {
+	if x != nil {
+		return x.Nat
+	}
+	return false
+}
+
+func (x *ConnectionStat) GetPreNatSrcIp() string {
+	if x != nil {
+		return x.PreNatSrcIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetPreNatSrcPort() uint32 {
+	if x != nil {
+		return x.PreNatSrcPort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetPreNatDstIp() string {
+	if x != nil {
+		return x.PreNatDstIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetPreNatDstPort() uint32 {
+	if x != nil {
+		return x.PreNatDstPort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetPostNatSrcIp() string {
+	if x != nil {
+		return x.PostNatSrcIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetPostNatSrcPort() uint32 {
+	if x != nil {
+		return x.PostNatSrcPort
+	}
+	return 0
+}
+
+func (x *ConnectionStat) GetPostNatDstIp() string {
+	if x != nil {
+		return x.PostNatDstIp
+	}
+	return ""
+}
+
+func (x *ConnectionStat) GetPostNatDstPort() uint32 {
+	if x != nil {
+		return x.PostNatDstPort
+	}
+	return 0
+}
+
 // The request message.
 type StatsRequest struct {
 	state         protoimpl.MessageState
@@ -162,23 +366,790 @@ type StatsRequest struct {
 	unknownFields protoimpl.UnknownFields
 }
 
-func (x *StatsRequest) Reset() {
-	*x = StatsRequest{}
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{1}
+}
+
+// The response message containing the stats table
+type StatsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Connstat []*ConnectionStat `protobuf:"bytes,1,rep,name=connstat,proto3" json:"connstat,omitempty"`
+}
+
+func (x *StatsReply) Reset() {
+	*x = StatsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StatsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsReply) ProtoMessage() {}
+
+func (x *StatsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsReply.ProtoReflect.Descriptor instead.
+func (*StatsReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StatsReply) GetConnstat() []*ConnectionStat {
+	if x != nil {
+		return x.Connstat
+	}
+	return nil
+}
+
+// The capture request. Packets match in either direction, empty fields match anything
+type CaptureRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Proto       uint32 `protobuf:"varint,1,opt,name=proto,proto3" json:"proto,omitempty"`          // IP protocol number
+	ANet        string `protobuf:"bytes,2,opt,name=a_net,json=aNet,proto3" json:"a_net,omitempty"` // address or CIDR prefix
+	APort       uint32 `protobuf:"varint,3,opt,name=a_port,json=aPort,proto3" json:"a_port,omitempty"`
+	BNet        string `protobuf:"bytes,4,opt,name=b_net,json=bNet,proto3" json:"b_net,omitempty"` // address or CIDR prefix
+	BPort       uint32 `protobuf:"varint,5,opt,name=b_port,json=bPort,proto3" json:"b_port,omitempty"`
+	DurationSec uint32 `protobuf:"varint,6,opt,name=duration_sec,json=durationSec,proto3" json:"duration_sec,omitempty"` // capture length, 60 seconds when unset
+	MaxBytes    uint64 `protobuf:"varint,7,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`          // stop after capturing this many bytes, unlimited when unset
+	Snaplen     uint32 `protobuf:"varint,8,opt,name=snaplen,proto3" json:"snaplen,omitempty"`                            // bytes kept from each packet, at most 2048
+	Path        string `protobuf:"bytes,9,opt,name=path,proto3" json:"path,omitempty"`                                   // name of the pcapng file StartCapture writes in its capture directory
+}
+
+func (x *CaptureRequest) Reset() {
+	*x = CaptureRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CaptureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaptureRequest) ProtoMessage() {}
+
+func (x *CaptureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaptureRequest.ProtoReflect.Descriptor instead.
+func (*CaptureRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CaptureRequest) GetProto() uint32 {
+	if x != nil {
+		return x.Proto
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetANet() string {
+	if x != nil {
+		return x.ANet
+	}
+	return ""
+}
+
+func (x *CaptureRequest) GetAPort() uint32 {
+	if x != nil {
+		return x.APort
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetBNet() string {
+	if x != nil {
+		return x.BNet
+	}
+	return ""
+}
+
+func (x *CaptureRequest) GetBPort() uint32 {
+	if x != nil {
+		return x.BPort
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetDurationSec() uint32 {
+	if x != nil {
+		return x.DurationSec
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetMaxBytes() uint64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetSnaplen() uint32 {
+	if x != nil {
+		return x.Snaplen
+	}
+	return 0
+}
+
+func (x *CaptureRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// The response message containing the pcapng file being written
+type CaptureReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *CaptureReply) Reset() {
+	*x = CaptureReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CaptureReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CaptureReply) ProtoMessage() {}
+
+func (x *CaptureReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CaptureReply.ProtoReflect.Descriptor instead.
+func (*CaptureReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CaptureReply) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// A captured packet, data holds the first snaplen bytes of the Ethernet frame
+type CapturedPacket struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ts       uint64 `protobuf:"varint,1,opt,name=ts,proto3" json:"ts,omitempty"`         // nanoseconds since the epoch
+	Length   uint32 `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"` // length of the packet on the wire
+	Outbound bool   `protobuf:"varint,3,opt,name=outbound,proto3" json:"outbound,omitempty"`
+	Data     []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *CapturedPacket) Reset() {
+	*x = CapturedPacket{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CapturedPacket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CapturedPacket) ProtoMessage() {}
+
+func (x *CapturedPacket) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CapturedPacket.ProtoReflect.Descriptor instead.
+func (*CapturedPacket) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CapturedPacket) GetTs() uint64 {
+	if x != nil {
+		return x.Ts
+	}
+	return 0
+}
+
+func (x *CapturedPacket) GetLength() uint32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+func (x *CapturedPacket) GetOutbound() bool {
+	if x != nil {
+		return x.Outbound
+	}
+	return false
+}
+
+func (x *CapturedPacket) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// The filter update. Rules are written like "deny local port 50051/tcp" or "allow remote 10.0.0.0/8 remote port 443/tcp"
+type FilterRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Add    []string `protobuf:"bytes,1,rep,name=add,proto3" json:"add,omitempty"`
+	Remove []uint32 `protobuf:"varint,2,rep,packed,name=remove,proto3" json:"remove,omitempty"` // ids of the rules to remove
+}
+
+func (x *FilterRequest) Reset() {
+	*x = FilterRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FilterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterRequest) ProtoMessage() {}
+
+func (x *FilterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterRequest.ProtoReflect.Descriptor instead.
+func (*FilterRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FilterRequest) GetAdd() []string {
+	if x != nil {
+		return x.Add
+	}
+	return nil
+}
+
+func (x *FilterRequest) GetRemove() []uint32 {
+	if x != nil {
+		return x.Remove
+	}
+	return nil
+}
+
+type FilterListRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *FilterListRequest) Reset() {
+	*x = FilterListRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FilterListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterListRequest) ProtoMessage() {}
+
+func (x *FilterListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterListRequest.ProtoReflect.Descriptor instead.
+func (*FilterListRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{7}
+}
+
+type FilterRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id   uint32 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Rule string `protobuf:"bytes,2,opt,name=rule,proto3" json:"rule,omitempty"`
+	Hits uint64 `protobuf:"varint,3,opt,name=hits,proto3" json:"hits,omitempty"` // packets matched since the rule was added
+}
+
+func (x *FilterRule) Reset() {
+	*x = FilterRule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FilterRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterRule) ProtoMessage() {}
+
+func (x *FilterRule) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterRule.ProtoReflect.Descriptor instead.
+func (*FilterRule) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *FilterRule) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *FilterRule) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *FilterRule) GetHits() uint64 {
+	if x != nil {
+		return x.Hits
+	}
+	return 0
+}
+
+// The response message containing the filter rules
+type FilterReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*FilterRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *FilterReply) Reset() {
+	*x = FilterReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FilterReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterReply) ProtoMessage() {}
+
+func (x *FilterReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterReply.ProtoReflect.Descriptor instead.
+func (*FilterReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FilterReply) GetRules() []*FilterRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type PipeStatsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *PipeStatsRequest) Reset() {
+	*x = PipeStatsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PipeStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipeStatsRequest) ProtoMessage() {}
+
+func (x *PipeStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipeStatsRequest.ProtoReflect.Descriptor instead.
+func (*PipeStatsRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{10}
+}
+
+// The response message containing the event pipe counters
+type PipeStatsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Events       uint64   `protobuf:"varint,1,opt,name=events,proto3" json:"events,omitempty"`
+	Drops        uint64   `protobuf:"varint,2,opt,name=drops,proto3" json:"drops,omitempty"`
+	DropsPerCpu  []uint64 `protobuf:"varint,3,rep,packed,name=drops_per_cpu,json=dropsPerCpu,proto3" json:"drops_per_cpu,omitempty"`
+	Fill         float64  `protobuf:"fixed64,4,opt,name=fill,proto3" json:"fill,omitempty"`                                    // ringbuf fill fraction
+	SampleFactor uint32   `protobuf:"varint,5,opt,name=sample_factor,json=sampleFactor,proto3" json:"sample_factor,omitempty"` // multiplier of the sampling rate set by the backpressure controller
+	Aggregating  bool     `protobuf:"varint,6,opt,name=aggregating,proto3" json:"aggregating,omitempty"`                       // flows are counted in the kernel instead of sending events
+}
+
+func (x *PipeStatsReply) Reset() {
+	*x = PipeStatsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PipeStatsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PipeStatsReply) ProtoMessage() {}
+
+func (x *PipeStatsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PipeStatsReply.ProtoReflect.Descriptor instead.
+func (*PipeStatsReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PipeStatsReply) GetEvents() uint64 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *PipeStatsReply) GetDrops() uint64 {
+	if x != nil {
+		return x.Drops
+	}
+	return 0
+}
+
+func (x *PipeStatsReply) GetDropsPerCpu() []uint64 {
+	if x != nil {
+		return x.DropsPerCpu
+	}
+	return nil
+}
+
+func (x *PipeStatsReply) GetFill() float64 {
+	if x != nil {
+		return x.Fill
+	}
+	return 0
+}
+
+func (x *PipeStatsReply) GetSampleFactor() uint32 {
+	if x != nil {
+		return x.SampleFactor
+	}
+	return 0
+}
+
+func (x *PipeStatsReply) GetAggregating() bool {
+	if x != nil {
+		return x.Aggregating
+	}
+	return false
+}
+
+type TableStatsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *TableStatsRequest) Reset() {
+	*x = TableStatsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TableStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TableStatsRequest) ProtoMessage() {}
+
+func (x *TableStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TableStatsRequest.ProtoReflect.Descriptor instead.
+func (*TableStatsRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{12}
+}
+
+// The response message containing the flow table occupancy
+type TableStatsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Flows        uint64 `protobuf:"varint,1,opt,name=flows,proto3" json:"flows,omitempty"`
+	MaxFlows     uint64 `protobuf:"varint,2,opt,name=max_flows,json=maxFlows,proto3" json:"max_flows,omitempty"`             // 0 when unlimited
+	MemoryBytes  uint64 `protobuf:"varint,3,opt,name=memory_bytes,json=memoryBytes,proto3" json:"memory_bytes,omitempty"`    // estimated
+	MemoryBudget uint64 `protobuf:"varint,4,opt,name=memory_budget,json=memoryBudget,proto3" json:"memory_budget,omitempty"` // 0 when unlimited
+	Evictions    uint64 `protobuf:"varint,5,opt,name=evictions,proto3" json:"evictions,omitempty"`
+}
+
+func (x *TableStatsReply) Reset() {
+	*x = TableStatsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TableStatsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TableStatsReply) ProtoMessage() {}
+
+func (x *TableStatsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TableStatsReply.ProtoReflect.Descriptor instead.
+func (*TableStatsReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *TableStatsReply) GetFlows() uint64 {
+	if x != nil {
+		return x.Flows
+	}
+	return 0
+}
+
+func (x *TableStatsReply) GetMaxFlows() uint64 {
+	if x != nil {
+		return x.MaxFlows
+	}
+	return 0
+}
+
+func (x *TableStatsReply) GetMemoryBytes() uint64 {
+	if x != nil {
+		return x.MemoryBytes
+	}
+	return 0
+}
+
+func (x *TableStatsReply) GetMemoryBudget() uint64 {
+	if x != nil {
+		return x.MemoryBudget
+	}
+	return 0
+}
+
+func (x *TableStatsReply) GetEvictions() uint64 {
+	if x != nil {
+		return x.Evictions
+	}
+	return 0
+}
+
+type AttachmentsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AttachmentsRequest) Reset() {
+	*x = AttachmentsRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_connstats_proto_msgTypes[1]
+		mi := &file_connstats_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *StatsRequest) String() string {
+func (x *AttachmentsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatsRequest) ProtoMessage() {}
+func (*AttachmentsRequest) ProtoMessage() {}
 
-func (x *StatsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_connstats_proto_msgTypes[1]
+func (x *AttachmentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -189,37 +1160,40 @@ func (x *StatsRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
-func (*StatsRequest) Descriptor() ([]byte, []int) {
-	return file_connstats_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use AttachmentsRequest.ProtoReflect.Descriptor instead.
+func (*AttachmentsRequest) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{14}
 }
 
-// The response message containing the stats table
-type StatsReply struct {
+type Attachment struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Connstat []*ConnectionStat `protobuf:"bytes,1,rep,name=connstat,proto3" json:"connstat,omitempty"`
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"` // network namespace, host for the agent's one
+	Interface string `protobuf:"bytes,2,opt,name=interface,proto3" json:"interface,omitempty"`
+	Ifindex   uint32 `protobuf:"varint,3,opt,name=ifindex,proto3" json:"ifindex,omitempty"`
+	Ingress   string `protobuf:"bytes,4,opt,name=ingress,proto3" json:"ingress,omitempty"` // xdp-native, xdp-generic, tcx or tc
+	Egress    string `protobuf:"bytes,5,opt,name=egress,proto3" json:"egress,omitempty"`   // tcx or tc
 }
 
-func (x *StatsReply) Reset() {
-	*x = StatsReply{}
+func (x *Attachment) Reset() {
+	*x = Attachment{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_connstats_proto_msgTypes[2]
+		mi := &file_connstats_proto_msgTypes[15]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *StatsReply) String() string {
+func (x *Attachment) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*StatsReply) ProtoMessage() {}
+func (*Attachment) ProtoMessage() {}
 
-func (x *StatsReply) ProtoReflect() protoreflect.Message {
-	mi := &file_connstats_proto_msgTypes[2]
+func (x *Attachment) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[15]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -230,14 +1204,89 @@ func (x *StatsReply) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use StatsReply.ProtoReflect.Descriptor instead.
-func (*StatsReply) Descriptor() ([]byte, []int) {
-	return file_connstats_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use Attachment.ProtoReflect.Descriptor instead.
+func (*Attachment) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *StatsReply) GetConnstat() []*ConnectionStat {
+func (x *Attachment) GetNamespace() string {
 	if x != nil {
-		return x.Connstat
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *Attachment) GetInterface() string {
+	if x != nil {
+		return x.Interface
+	}
+	return ""
+}
+
+func (x *Attachment) GetIfindex() uint32 {
+	if x != nil {
+		return x.Ifindex
+	}
+	return 0
+}
+
+func (x *Attachment) GetIngress() string {
+	if x != nil {
+		return x.Ingress
+	}
+	return ""
+}
+
+func (x *Attachment) GetEgress() string {
+	if x != nil {
+		return x.Egress
+	}
+	return ""
+}
+
+type AttachmentsReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Attachments []*Attachment `protobuf:"bytes,1,rep,name=attachments,proto3" json:"attachments,omitempty"`
+}
+
+func (x *AttachmentsReply) Reset() {
+	*x = AttachmentsReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connstats_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttachmentsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttachmentsReply) ProtoMessage() {}
+
+func (x *AttachmentsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_connstats_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttachmentsReply.ProtoReflect.Descriptor instead.
+func (*AttachmentsReply) Descriptor() ([]byte, []int) {
+	return file_connstats_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *AttachmentsReply) GetAttachments() []*Attachment {
+	if x != nil {
+		return x.Attachments
 	}
 	return nil
 }
@@ -247,7 +1296,7 @@ var File_connstats_proto protoreflect.FileDescriptor
 var file_connstats_proto_rawDesc = []byte{
 	0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x12, 0x11, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x62, 0x75, 0x66, 0x22, 0xb4, 0x02, 0x0a, 0x0e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x22, 0xa1, 0x09, 0x0a, 0x0e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
 	0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x70,
 	0x72, 0x6f, 0x74, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x74,
@@ -266,22 +1315,194 @@ var file_connstats_proto_rawDesc = []byte{
 	0x46, 0x69, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x69, 0x6e, 0x18,
 	0x0b, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x62, 0x79, 0x74, 0x65, 0x73, 0x49, 0x6e, 0x12, 0x1b,
 	0x0a, 0x09, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x6f, 0x75, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x08, 0x62, 0x79, 0x74, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x22, 0x0e, 0x0a, 0x0c, 0x53,
-	0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x4b, 0x0a, 0x0a, 0x53,
-	0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x3d, 0x0a, 0x08, 0x63, 0x6f, 0x6e,
-	0x6e, 0x73, 0x74, 0x61, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63, 0x6f,
+	0x04, 0x52, 0x08, 0x62, 0x79, 0x74, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x63,
+	0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x49, 0x64, 0x12, 0x1f,
+	0x0a, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x18, 0x0e, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0a, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x61, 0x74, 0x65, 0x12,
+	0x1c, 0x0a, 0x09, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0f, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x09, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a,
+	0x07, 0x69, 0x66, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07,
+	0x69, 0x66, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x65, 0x74, 0x6e, 0x73,
+	0x18, 0x11, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x6e, 0x65, 0x74, 0x6e, 0x73, 0x12, 0x1d, 0x0a,
+	0x0a, 0x6d, 0x69, 0x64, 0x5f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x12, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x09, 0x6d, 0x69, 0x64, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x19, 0x0a, 0x08,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x5f, 0x69, 0x70, 0x18, 0x13, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x49, 0x70, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x6f, 0x63, 0x61, 0x6c,
+	0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65,
+	0x5f, 0x69, 0x70, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x72, 0x65, 0x6d, 0x6f, 0x74,
+	0x65, 0x49, 0x70, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x5f, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x16, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x72, 0x65, 0x6d, 0x6f, 0x74, 0x65,
+	0x50, 0x6f, 0x72, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x5f, 0x69, 0x6e,
+	0x69, 0x74, 0x69, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x17, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x6c,
+	0x6f, 0x63, 0x61, 0x6c, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x1f, 0x0a,
+	0x0b, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x18, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x0a, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x2a,
+	0x0a, 0x11, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x5f, 0x74, 0x6f, 0x5f, 0x73, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x18, 0x19, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x70, 0x61, 0x63, 0x6b, 0x65,
+	0x74, 0x73, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x26, 0x0a, 0x0f, 0x62, 0x79,
+	0x74, 0x65, 0x73, 0x5f, 0x74, 0x6f, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x18, 0x1a, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x0d, 0x62, 0x79, 0x74, 0x65, 0x73, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x76,
+	0x65, 0x72, 0x12, 0x2a, 0x0a, 0x11, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x5f, 0x74, 0x6f,
+	0x5f, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0f, 0x70,
+	0x61, 0x63, 0x6b, 0x65, 0x74, 0x73, 0x54, 0x6f, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x26,
+	0x0a, 0x0f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x5f, 0x74, 0x6f, 0x5f, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x18, 0x1c, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x62, 0x79, 0x74, 0x65, 0x73, 0x54, 0x6f,
+	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6e, 0x61, 0x74, 0x18, 0x1d, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x03, 0x6e, 0x61, 0x74, 0x12, 0x23, 0x0a, 0x0e, 0x70, 0x72, 0x65, 0x5f,
+	0x6e, 0x61, 0x74, 0x5f, 0x73, 0x72, 0x63, 0x5f, 0x69, 0x70, 0x18, 0x1e, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x70, 0x72, 0x65, 0x4e, 0x61, 0x74, 0x53, 0x72, 0x63, 0x49, 0x70, 0x12, 0x27, 0x0a,
+	0x10, 0x70, 0x72, 0x65, 0x5f, 0x6e, 0x61, 0x74, 0x5f, 0x73, 0x72, 0x63, 0x5f, 0x70, 0x6f, 0x72,
+	0x74, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x70, 0x72, 0x65, 0x4e, 0x61, 0x74, 0x53,
+	0x72, 0x63, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x23, 0x0a, 0x0e, 0x70, 0x72, 0x65, 0x5f, 0x6e, 0x61,
+	0x74, 0x5f, 0x64, 0x73, 0x74, 0x5f, 0x69, 0x70, 0x18, 0x20, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x70, 0x72, 0x65, 0x4e, 0x61, 0x74, 0x44, 0x73, 0x74, 0x49, 0x70, 0x12, 0x27, 0x0a, 0x10, 0x70,
+	0x72, 0x65, 0x5f, 0x6e, 0x61, 0x74, 0x5f, 0x64, 0x73, 0x74, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18,
+	0x21, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x70, 0x72, 0x65, 0x4e, 0x61, 0x74, 0x44, 0x73, 0x74,
+	0x50, 0x6f, 0x72, 0x74, 0x12, 0x25, 0x0a, 0x0f, 0x70, 0x6f, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x74,
+	0x5f, 0x73, 0x72, 0x63, 0x5f, 0x69, 0x70, 0x18, 0x22, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x70,
+	0x6f, 0x73, 0x74, 0x4e, 0x61, 0x74, 0x53, 0x72, 0x63, 0x49, 0x70, 0x12, 0x29, 0x0a, 0x11, 0x70,
+	0x6f, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x74, 0x5f, 0x73, 0x72, 0x63, 0x5f, 0x70, 0x6f, 0x72, 0x74,
+	0x18, 0x23, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x70, 0x6f, 0x73, 0x74, 0x4e, 0x61, 0x74, 0x53,
+	0x72, 0x63, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x25, 0x0a, 0x0f, 0x70, 0x6f, 0x73, 0x74, 0x5f, 0x6e,
+	0x61, 0x74, 0x5f, 0x64, 0x73, 0x74, 0x5f, 0x69, 0x70, 0x18, 0x24, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x70, 0x6f, 0x73, 0x74, 0x4e, 0x61, 0x74, 0x44, 0x73, 0x74, 0x49, 0x70, 0x12, 0x29, 0x0a,
+	0x11, 0x70, 0x6f, 0x73, 0x74, 0x5f, 0x6e, 0x61, 0x74, 0x5f, 0x64, 0x73, 0x74, 0x5f, 0x70, 0x6f,
+	0x72, 0x74, 0x18, 0x25, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x70, 0x6f, 0x73, 0x74, 0x4e, 0x61,
+	0x74, 0x44, 0x73, 0x74, 0x50, 0x6f, 0x72, 0x74, 0x22, 0x0e, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x4b, 0x0a, 0x0a, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x3d, 0x0a, 0x08, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74,
+	0x61, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73,
+	0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x52, 0x08, 0x63, 0x6f, 0x6e,
+	0x6e, 0x73, 0x74, 0x61, 0x74, 0x22, 0xec, 0x01, 0x0a, 0x0e, 0x43, 0x61, 0x70, 0x74, 0x75, 0x72,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13,
+	0x0a, 0x05, 0x61, 0x5f, 0x6e, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61,
+	0x4e, 0x65, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x05, 0x61, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x13, 0x0a, 0x05, 0x62, 0x5f,
+	0x6e, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x62, 0x4e, 0x65, 0x74, 0x12,
+	0x15, 0x0a, 0x06, 0x62, 0x5f, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x05, 0x62, 0x50, 0x6f, 0x72, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x63, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x64, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x63, 0x12, 0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78,
+	0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x6d, 0x61,
+	0x78, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x6e, 0x61, 0x70, 0x6c, 0x65,
+	0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x73, 0x6e, 0x61, 0x70, 0x6c, 0x65, 0x6e,
+	0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x70, 0x61, 0x74, 0x68, 0x22, 0x22, 0x0a, 0x0c, 0x43, 0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x52,
+	0x65, 0x70, 0x6c, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x22, 0x68, 0x0a, 0x0e, 0x43, 0x61, 0x70, 0x74,
+	0x75, 0x72, 0x65, 0x64, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x74, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65,
+	0x6e, 0x67, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67,
+	0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x6f, 0x75, 0x74, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x6f, 0x75, 0x74, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x12,
+	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61,
+	0x74, 0x61, 0x22, 0x39, 0x0a, 0x0d, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x64, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x03, 0x61, 0x64, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x22, 0x13, 0x0a,
+	0x11, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x22, 0x44, 0x0a, 0x0a, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x75, 0x6c, 0x65,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x12, 0x0a, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x72, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x69, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x04, 0x68, 0x69, 0x74, 0x73, 0x22, 0x42, 0x0a, 0x0b, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x33, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61,
+	0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65,
+	0x72, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x22, 0x12, 0x0a, 0x10,
+	0x50, 0x69, 0x70, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x22, 0xbd, 0x01, 0x0a, 0x0e, 0x50, 0x69, 0x70, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65,
+	0x70, 0x6c, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x64,
+	0x72, 0x6f, 0x70, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x64, 0x72, 0x6f, 0x70,
+	0x73, 0x12, 0x22, 0x0a, 0x0d, 0x64, 0x72, 0x6f, 0x70, 0x73, 0x5f, 0x70, 0x65, 0x72, 0x5f, 0x63,
+	0x70, 0x75, 0x18, 0x03, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0b, 0x64, 0x72, 0x6f, 0x70, 0x73, 0x50,
+	0x65, 0x72, 0x43, 0x70, 0x75, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c, 0x6c, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x6c, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x5f, 0x66, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0c, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x20,
+	0x0a, 0x0b, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0b, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x69, 0x6e, 0x67,
+	0x22, 0x13, 0x0a, 0x11, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xaa, 0x01, 0x0a, 0x0f, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x6c, 0x6f,
+	0x77, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x66, 0x6c, 0x6f, 0x77, 0x73, 0x12,
+	0x1b, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x5f, 0x66, 0x6c, 0x6f, 0x77, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x46, 0x6c, 0x6f, 0x77, 0x73, 0x12, 0x21, 0x0a, 0x0c,
+	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x62, 0x79, 0x74, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12,
+	0x23, 0x0a, 0x0d, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x62, 0x75, 0x64, 0x67, 0x65, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x42, 0x75,
+	0x64, 0x67, 0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x65, 0x76, 0x69, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x22, 0x14, 0x0a, 0x12, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x94, 0x01, 0x0a, 0x0a, 0x41, 0x74, 0x74,
+	0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x6e, 0x61, 0x6d, 0x65, 0x73,
+	0x70, 0x61, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x61, 0x6d, 0x65,
+	0x73, 0x70, 0x61, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61,
+	0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66,
+	0x61, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x69, 0x66, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x69, 0x66, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x18, 0x0a,
+	0x07, 0x69, 0x6e, 0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x69, 0x6e, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x67, 0x72, 0x65, 0x73,
+	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x67, 0x72, 0x65, 0x73, 0x73, 0x22,
+	0x53, 0x0a, 0x10, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65,
+	0x70, 0x6c, 0x79, 0x12, 0x3f, 0x0a, 0x0b, 0x61, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73,
+	0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x74, 0x74,
+	0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x0b, 0x61, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d,
+	0x65, 0x6e, 0x74, 0x73, 0x32, 0xcb, 0x05, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x74, 0x73, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x50, 0x0a, 0x0c, 0x43, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74,
+	0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x1f, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74,
+	0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61,
+	0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x73,
+	0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x54, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x72, 0x74,
+	0x43, 0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74,
+	0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x43, 0x61, 0x70, 0x74,
+	0x75, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x6f, 0x6e,
+	0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x43,
+	0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x59, 0x0a,
+	0x0d, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x43, 0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x12, 0x21,
+	0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x43, 0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x43, 0x61, 0x70, 0x74, 0x75, 0x72, 0x65, 0x64, 0x50, 0x61,
+	0x63, 0x6b, 0x65, 0x74, 0x22, 0x00, 0x30, 0x01, 0x12, 0x53, 0x0a, 0x0d, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x12, 0x20, 0x2e, 0x63, 0x6f, 0x6e, 0x6e,
+	0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x6f,
+	0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x55, 0x0a,
+	0x0b, 0x4c, 0x69, 0x73, 0x74, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x12, 0x24, 0x2e, 0x63,
+	0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x65, 0x70,
+	0x6c, 0x79, 0x22, 0x00, 0x12, 0x55, 0x0a, 0x09, 0x50, 0x69, 0x70, 0x65, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x12, 0x23, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x50, 0x69, 0x70, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61,
+	0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x50, 0x69, 0x70, 0x65, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x58, 0x0a, 0x0a, 0x54,
+	0x61, 0x62, 0x6c, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x24, 0x2e, 0x63, 0x6f, 0x6e, 0x6e,
+	0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x61,
+	0x62, 0x6c, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x22, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x65,
+	0x70, 0x6c, 0x79, 0x22, 0x00, 0x12, 0x5b, 0x0a, 0x0b, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d,
+	0x65, 0x6e, 0x74, 0x73, 0x12, 0x25, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d,
+	0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x63, 0x6f,
 	0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
-	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x52, 0x08,
-	0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x32, 0x60, 0x0a, 0x0c, 0x53, 0x74, 0x61, 0x74,
-	0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x50, 0x0a, 0x0c, 0x43, 0x6f, 0x6c, 0x6c,
-	0x65, 0x63, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x1f, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x73,
-	0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x61,
-	0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x63, 0x6f, 0x6e, 0x6e,
-	0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74,
-	0x61, 0x74, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x22, 0x00, 0x42, 0x23, 0x5a, 0x21, 0x43, 0x6f,
-	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x73, 0x2f, 0x63, 0x6f,
-	0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x41, 0x74, 0x74, 0x61, 0x63, 0x68, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x70, 0x6c, 0x79,
+	0x22, 0x00, 0x42, 0x23, 0x5a, 0x21, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x53, 0x74, 0x61, 0x74, 0x73, 0x2f, 0x63, 0x6f, 0x6e, 0x6e, 0x73, 0x74, 0x61, 0x74, 0x73, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -296,21 +1517,51 @@ func file_connstats_proto_rawDescGZIP() []byte {
 	return file_connstats_proto_rawDescData
 }
 
-var file_connstats_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_connstats_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_connstats_proto_goTypes = []interface{}{
-	(*ConnectionStat)(nil), // 0: connstatsprotobuf.ConnectionStat
-	(*StatsRequest)(nil),   // 1: connstatsprotobuf.StatsRequest
-	(*StatsReply)(nil),     // 2: connstatsprotobuf.StatsReply
+	(*ConnectionStat)(nil),     // 0: connstatsprotobuf.ConnectionStat
+	(*StatsRequest)(nil),       // 1: connstatsprotobuf.StatsRequest
+	(*StatsReply)(nil),         // 2: connstatsprotobuf.StatsReply
+	(*CaptureRequest)(nil),     // 3: connstatsprotobuf.CaptureRequest
+	(*CaptureReply)(nil),       // 4: connstatsprotobuf.CaptureReply
+	(*CapturedPacket)(nil),     // 5: connstatsprotobuf.CapturedPacket
+	(*FilterRequest)(nil),      // 6: connstatsprotobuf.FilterRequest
+	(*FilterListRequest)(nil),  // 7: connstatsprotobuf.FilterListRequest
+	(*FilterRule)(nil),         // 8: connstatsprotobuf.FilterRule
+	(*FilterReply)(nil),        // 9: connstatsprotobuf.FilterReply
+	(*PipeStatsRequest)(nil),   // 10: connstatsprotobuf.PipeStatsRequest
+	(*PipeStatsReply)(nil),     // 11: connstatsprotobuf.PipeStatsReply
+	(*TableStatsRequest)(nil),  // 12: connstatsprotobuf.TableStatsRequest
+	(*TableStatsReply)(nil),    // 13: connstatsprotobuf.TableStatsReply
+	(*AttachmentsRequest)(nil), // 14: connstatsprotobuf.AttachmentsRequest
+	(*Attachment)(nil),         // 15: connstatsprotobuf.Attachment
+	(*AttachmentsReply)(nil),   // 16: connstatsprotobuf.AttachmentsReply
 }
 var file_connstats_proto_depIdxs = []int32{
-	0, // 0: connstatsprotobuf.StatsReply.connstat:type_name -> connstatsprotobuf.ConnectionStat
-	1, // 1: connstatsprotobuf.StatsService.CollectStats:input_type -> connstatsprotobuf.StatsRequest
-	2, // 2: connstatsprotobuf.StatsService.CollectStats:output_type -> connstatsprotobuf.StatsReply
-	2, // [2:3] is the sub-list for method output_type
-	1, // [1:2] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: connstatsprotobuf.StatsReply.connstat:type_name -> connstatsprotobuf.ConnectionStat
+	8,  // 1: connstatsprotobuf.FilterReply.rules:type_name -> connstatsprotobuf.FilterRule
+	15, // 2: connstatsprotobuf.AttachmentsReply.attachments:type_name -> connstatsprotobuf.Attachment
+	1,  // 3: connstatsprotobuf.StatsService.CollectStats:input_type -> connstatsprotobuf.StatsRequest
+	3,  // 4: connstatsprotobuf.StatsService.StartCapture:input_type -> connstatsprotobuf.CaptureRequest
+	3,  // 5: connstatsprotobuf.StatsService.StreamCapture:input_type -> connstatsprotobuf.CaptureRequest
+	6,  // 6: connstatsprotobuf.StatsService.UpdateFilters:input_type -> connstatsprotobuf.FilterRequest
+	7,  // 7: connstatsprotobuf.StatsService.ListFilters:input_type -> connstatsprotobuf.FilterListRequest
+	10, // 8: connstatsprotobuf.StatsService.PipeStats:input_type -> connstatsprotobuf.PipeStatsRequest
+	12, // 9: connstatsprotobuf.StatsService.TableStats:input_type -> connstatsprotobuf.TableStatsRequest
+	14, // 10: connstatsprotobuf.StatsService.Attachments:input_type -> connstatsprotobuf.AttachmentsRequest
+	2,  // 11: connstatsprotobuf.StatsService.CollectStats:output_type -> connstatsprotobuf.StatsReply
+	4,  // 12: connstatsprotobuf.StatsService.StartCapture:output_type -> connstatsprotobuf.CaptureReply
+	5,  // 13: connstatsprotobuf.StatsService.StreamCapture:output_type -> connstatsprotobuf.CapturedPacket
+	9,  // 14: connstatsprotobuf.StatsService.UpdateFilters:output_type -> connstatsprotobuf.FilterReply
+	9,  // 15: connstatsprotobuf.StatsService.ListFilters:output_type -> connstatsprotobuf.FilterReply
+	11, // 16: connstatsprotobuf.StatsService.PipeStats:output_type -> connstatsprotobuf.PipeStatsReply
+	13, // 17: connstatsprotobuf.StatsService.TableStats:output_type -> connstatsprotobuf.TableStatsReply
+	16, // 18: connstatsprotobuf.StatsService.Attachments:output_type -> connstatsprotobuf.AttachmentsReply
+	11, // [11:19] is the sub-list for method output_type
+	3,  // [3:11] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_connstats_proto_init() }
@@ -355,6 +1606,174 @@ func file_connstats_proto_init() {
 				return nil
 			}
 		}
+		file_connstats_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CaptureRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CaptureReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CapturedPacket); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterListRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PipeStatsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PipeStatsReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TableStatsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TableStatsReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AttachmentsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Attachment); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connstats_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AttachmentsReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -362,7 +1781,7 @@ func file_connstats_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_connstats_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   17,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -24,6 +24,20 @@ const _ = grpc.SupportPackageIsVersion7
 type StatsServiceClient interface {
 	// Sends a connection stats
 	CollectStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	// Captures the matching packets to a pcapng file on the agent host
+	StartCapture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (*CaptureReply, error)
+	// Captures the matching packets and streams them back
+	StreamCapture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (StatsService_StreamCaptureClient, error)
+	// Adds and removes filter rules, returns the resulting rules
+	UpdateFilters(ctx context.Context, in *FilterRequest, opts ...grpc.CallOption) (*FilterReply, error)
+	// Lists the filter rules with their hit counters
+	ListFilters(ctx context.Context, in *FilterListRequest, opts ...grpc.CallOption) (*FilterReply, error)
+	// Reports the events sent and dropped by the probe and the backpressure state
+	PipeStats(ctx context.Context, in *PipeStatsRequest, opts ...grpc.CallOption) (*PipeStatsReply, error)
+	// Reports the occupancy of the flow table
+	TableStats(ctx context.Context, in *TableStatsRequest, opts ...grpc.CallOption) (*TableStatsReply, error)
+	// Lists the interfaces the probe runs on, and the hook of each direction
+	Attachments(ctx context.Context, in *AttachmentsRequest, opts ...grpc.CallOption) (*AttachmentsReply, error)
 }
 
 type statsServiceClient struct {
@@ -43,12 +57,112 @@ func (c *statsServiceClient) CollectStats(ctx context.Context, in *StatsRequest,
 	return out, nil
 }
 
+func (c *statsServiceClient) StartCapture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (*CaptureReply, error) {
+	out := new(CaptureReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/StartCapture", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statsServiceClient) StreamCapture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (StatsService_StreamCaptureClient, error) {
+	stream, err := c.cc.NewStream(ctx, &StatsService_ServiceDesc.Streams[0], "/connstatsprotobuf.StatsService/StreamCapture", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &statsServiceStreamCaptureClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StatsService_StreamCaptureClient interface {
+	Recv() (*CapturedPacket, error)
+	grpc.ClientStream
+}
+
+type statsServiceStreamCaptureClient struct {
+	grpc.ClientStream
+}
+
+func (x *statsServiceStreamCaptureClient) Recv() (*CapturedPacket, error) {
+	m := new(CapturedPacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *statsServiceClient) UpdateFilters(ctx context.Context, in *FilterRequest, opts ...grpc.CallOption) (*FilterReply, error) {
+	out := new(FilterReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/UpdateFilters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statsServiceClient) ListFilters(ctx context.Context, in *FilterListRequest, opts ...grpc.CallOption) (*FilterReply, error) {
+	out := new(FilterReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/ListFilters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statsServiceClient) PipeStats(ctx context.Context, in *PipeStatsRequest, opts ...grpc.CallOption) (*PipeStatsReply, error) {
+	out := new(PipeStatsReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/PipeStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statsServiceClient) TableStats(ctx context.Context, in *TableStatsRequest, opts ...grpc.CallOption) (*TableStatsReply, error) {
+	out := new(TableStatsReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/TableStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *statsServiceClient) Attachments(ctx context.Context, in *AttachmentsRequest, opts ...grpc.CallOption) (*AttachmentsReply, error) {
+	out := new(AttachmentsReply)
+	err := c.cc.Invoke(ctx, "/connstatsprotobuf.StatsService/Attachments", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StatsServiceServer is the server API for StatsService service.
 // All implementations must embed UnimplementedStatsServiceServer
 // for forward compatibility
 type StatsServiceServer interface {
 	// Sends a connection stats
 	CollectStats(context.Context, *StatsRequest) (*StatsReply, error)
+	// Captures the matching packets to a pcapng file on the agent host
+	StartCapture(context.Context, *CaptureRequest) (*CaptureReply, error)
+	// Captures the matching packets and streams them back
+	StreamCapture(*CaptureRequest, StatsService_StreamCaptureServer) error
+	// Adds and removes filter rules, returns the resulting rules
+	UpdateFilters(context.Context, *FilterRequest) (*FilterReply, error)
+	// Lists the filter rules with their hit counters
+	ListFilters(context.Context, *FilterListRequest) (*FilterReply, error)
+	// Reports the events sent and dropped by the probe and the backpressure state
+	PipeStats(context.Context, *PipeStatsRequest) (*PipeStatsReply, error)
+	// Reports the occupancy of the flow table
+	TableStats(context.Context, *TableStatsRequest) (*TableStatsReply, error)
+	// Lists the interfaces the probe runs on, and the hook of each direction
+	Attachments(context.Context, *AttachmentsRequest) (*AttachmentsReply, error)
 	mustEmbedUnimplementedStatsServiceServer()
 }
 
@@ -59,6 +173,27 @@ type UnimplementedStatsServiceServer struct {
 func (UnimplementedStatsServiceServer) CollectStats(context.Context, *StatsRequest) (*StatsReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CollectStats not implemented")
 }
+func (UnimplementedStatsServiceServer) StartCapture(context.Context, *CaptureRequest) (*CaptureReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartCapture not implemented")
+}
+func (UnimplementedStatsServiceServer) StreamCapture(*CaptureRequest, StatsService_StreamCaptureServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamCapture not implemented")
+}
+func (UnimplementedStatsServiceServer) UpdateFilters(context.Context, *FilterRequest) (*FilterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateFilters not implemented")
+}
+func (UnimplementedStatsServiceServer) ListFilters(context.Context, *FilterListRequest) (*FilterReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFilters not implemented")
+}
+func (UnimplementedStatsServiceServer) PipeStats(context.Context, *PipeStatsRequest) (*PipeStatsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PipeStats not implemented")
+}
+func (UnimplementedStatsServiceServer) TableStats(context.Context, *TableStatsRequest) (*TableStatsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TableStats not implemented")
+}
+func (UnimplementedStatsServiceServer) Attachments(context.Context, *AttachmentsRequest) (*AttachmentsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Attachments not implemented")
+}
 func (UnimplementedStatsServiceServer) mustEmbedUnimplementedStatsServiceServer() {}
 
 // UnsafeStatsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -90,6 +225,135 @@ func _StatsService_CollectStats_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StatsService_StartCapture_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CaptureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).StartCapture(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/StartCapture",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).StartCapture(ctx, req.(*CaptureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatsService_StreamCapture_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CaptureRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StatsServiceServer).StreamCapture(m, &statsServiceStreamCaptureServer{stream})
+}
+
+type StatsService_StreamCaptureServer interface {
+	Send(*CapturedPacket) error
+	grpc.ServerStream
+}
+
+type statsServiceStreamCaptureServer struct {
+	grpc.ServerStream
+}
+
+func (x *statsServiceStreamCaptureServer) Send(m *CapturedPacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _StatsService_UpdateFilters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).UpdateFilters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/UpdateFilters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).UpdateFilters(ctx, req.(*FilterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatsService_ListFilters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilterListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).ListFilters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/ListFilters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).ListFilters(ctx, req.(*FilterListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatsService_PipeStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PipeStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).PipeStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/PipeStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).PipeStats(ctx, req.(*PipeStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatsService_TableStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TableStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).TableStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/TableStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).TableStats(ctx, req.(*TableStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StatsService_Attachments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AttachmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StatsServiceServer).Attachments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/connstatsprotobuf.StatsService/Attachments",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StatsServiceServer).Attachments(ctx, req.(*AttachmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // StatsService_ServiceDesc is the grpc.ServiceDesc for StatsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -101,7 +365,37 @@ var StatsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CollectStats",
 			Handler:    _StatsService_CollectStats_Handler,
 		},
+		{
+			MethodName: "StartCapture",
+			Handler:    _StatsService_StartCapture_Handler,
+		},
+		{
+			MethodName: "UpdateFilters",
+			Handler:    _StatsService_UpdateFilters_Handler,
+		},
+		{
+			MethodName: "ListFilters",
+			Handler:    _StatsService_ListFilters_Handler,
+		},
+		{
+			MethodName: "PipeStats",
+			Handler:    _StatsService_PipeStats_Handler,
+		},
+		{
+			MethodName: "TableStats",
+			Handler:    _StatsService_TableStats_Handler,
+		},
+		{
+			MethodName: "Attachments",
+			Handler:    _StatsService_Attachments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamCapture",
+			Handler:       _StatsService_StreamCapture_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "connstats.proto",
 }
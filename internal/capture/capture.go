@@ -0,0 +1,210 @@
+package capture
+
+import (
+	"errors"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSnapLen is the largest number of bytes the probe copies from a packet
+	MaxSnapLen = 2048
+
+	DefaultDuration = time.Minute
+	frameQueue      = 1024
+)
+
+var (
+	ErrBusy        = errors.New("a capture is already running")
+	ErrUnavailable = errors.New("capture is not supported by the probe")
+)
+
+// Filter selects the captured packets. Packets match in either direction, and zero valued
+// fields match anything
+type Filter struct {
+	Proto uint8
+	ANet  netip.Prefix
+	APort uint16
+	BNet  netip.Prefix
+	BPort uint16
+}
+
+// ParseNet parses an address or a CIDR prefix. An empty string returns the zero prefix,
+// which matches any address
+func ParseNet(s string) (netip.Prefix, error) {
+	if s == "" {
+		return netip.Prefix{}, nil
+	}
+
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// Request bounds a capture. It ends after Duration or once MaxBytes bytes were captured
+type Request struct {
+	Filter   Filter
+	Duration time.Duration
+	MaxBytes uint64
+	SnapLen  uint32
+}
+
+// Frame is a packet copied by the probe. Length is the length of the packet on the wire
+// and Timestamp the nanoseconds since boot
+type Frame struct {
+	Timestamp uint64
+	Length    uint32
+	Outbound  bool
+	Data      []byte
+}
+
+// Backend installs the capture filter in the probe. A nil filter disables the capture
+type Backend interface {
+	SetFilter(filter *Filter, snapLen uint32) error
+}
+
+// Manager runs the captures, one at a time
+type Manager struct {
+	mu      sync.Mutex
+	backend Backend
+	session *Session
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// SetBackend sets the probe the captures are run on, a running capture is stopped when
+// the backend goes away
+func (m *Manager) SetBackend(backend Backend) {
+	m.mu.Lock()
+	session := m.session
+	m.backend = backend
+	m.mu.Unlock()
+
+	if backend == nil && session != nil {
+		session.Stop()
+	}
+}
+
+// Start installs the filter of req and returns the session the frames are delivered to
+func (m *Manager) Start(req Request) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.backend == nil {
+		return nil, ErrUnavailable
+	}
+	if m.session != nil {
+		return nil, ErrBusy
+	}
+
+	if req.Duration <= 0 {
+		req.Duration = DefaultDuration
+	}
+	if req.SnapLen == 0 || req.SnapLen > MaxSnapLen {
+		req.SnapLen = MaxSnapLen
+	}
+
+	if err := m.backend.SetFilter(&req.Filter, req.SnapLen); err != nil {
+		log.Printf("Failed setting capture filter: %v", err)
+		return nil, err
+	}
+
+	frames := make(chan Frame, frameQueue)
+	s := &Session{
+		Frames:  frames,
+		Request: req,
+		manager: m,
+		frames:  frames,
+	}
+	s.timer = time.AfterFunc(req.Duration, s.Stop)
+	m.session = s
+
+	log.Printf("Capture started: %+v", req)
+
+	return s, nil
+}
+
+// Deliver hands a frame read from the probe to the running capture
+func (m *Manager) Deliver(frame Frame) {
+	m.mu.Lock()
+	s := m.session
+	if s == nil {
+		m.mu.Unlock()
+		return
+	}
+
+	full := s.deliver(frame)
+	m.mu.Unlock()
+
+	if full {
+		s.Stop()
+	}
+}
+
+// Session is a running capture. Frames is closed when the capture ends
+type Session struct {
+	Frames  <-chan Frame
+	Request Request
+
+	manager *Manager
+	frames  chan Frame
+	timer   *time.Timer
+	bytes   uint64
+	packets uint64
+	dropped uint64
+	stopped bool
+}
+
+// deliver queues the frame and reports whether MaxBytes was reached. Called with the
+// manager lock held
+func (s *Session) deliver(frame Frame) bool {
+	if s.stopped {
+		return false
+	}
+
+	select {
+	case s.frames <- frame:
+		s.packets++
+		s.bytes += uint64(len(frame.Data))
+	default:
+		s.dropped++
+	}
+
+	return s.Request.MaxBytes > 0 && s.bytes >= s.Request.MaxBytes
+}
+
+// Stop ends the capture, it can be called more than once
+func (s *Session) Stop() {
+	m := s.manager
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	s.timer.Stop()
+
+	if m.backend != nil {
+		if err := m.backend.SetFilter(nil, 0); err != nil {
+			log.Printf("Failed clearing capture filter: %v", err)
+		}
+	}
+	if m.session == s {
+		m.session = nil
+	}
+	close(s.frames)
+
+	log.Printf("Capture stopped: %d packets, %d bytes, %d dropped", s.packets, s.bytes, s.dropped)
+}
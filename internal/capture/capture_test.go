@@ -0,0 +1,96 @@
+package capture
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	filters []*Filter
+}
+
+func (b *fakeBackend) SetFilter(filter *Filter, snapLen uint32) error {
+	b.filters = append(b.filters, filter)
+	return nil
+}
+
+func TestParseNet(t *testing.T) {
+	prefix, err := ParseNet("10.1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, netip.MustParsePrefix("10.1.2.3/32"), prefix)
+
+	prefix, err = ParseNet("10.1.2.3/8")
+	require.NoError(t, err)
+	require.Equal(t, netip.MustParsePrefix("10.0.0.0/8"), prefix)
+
+	prefix, err = ParseNet("")
+	require.NoError(t, err)
+	require.False(t, prefix.IsValid())
+
+	_, err = ParseNet("10.1.2")
+	require.Error(t, err)
+}
+
+func TestManagerMaxBytes(t *testing.T) {
+	backend := &fakeBackend{}
+	m := NewManager()
+
+	_, err := m.Start(Request{})
+	require.ErrorIs(t, err, ErrUnavailable)
+
+	m.SetBackend(backend)
+	s, err := m.Start(Request{MaxBytes: 100})
+	require.NoError(t, err)
+	require.Equal(t, uint32(MaxSnapLen), s.Request.SnapLen)
+
+	_, err = m.Start(Request{})
+	require.ErrorIs(t, err, ErrBusy)
+
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() { done <- WritePcapng(&buf, s, "eth0") }()
+
+	for i := 0; i < 5; i++ {
+		m.Deliver(Frame{Timestamp: uint64(i), Length: 1500, Data: make([]byte, 60)})
+	}
+	require.NoError(t, <-done)
+
+	// The filter is installed then cleared once the second frame crosses MaxBytes
+	require.Len(t, backend.filters, 2)
+	require.Nil(t, backend.filters[1])
+
+	r, err := pcapgo.NewNgReader(&buf, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+
+	var packets int
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		require.Len(t, data, 60)
+		require.Equal(t, 1500, ci.Length)
+		packets++
+	}
+	require.Equal(t, 2, packets)
+}
+
+func TestSessionDuration(t *testing.T) {
+	m := NewManager()
+	m.SetBackend(&fakeBackend{})
+
+	s, err := m.Start(Request{Duration: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	for range s.Frames {
+	}
+
+	_, err = m.Start(Request{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,48 @@
+package capture
+
+import (
+	"io"
+	"runtime"
+
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// WritePcapng writes the frames of the session to w until the capture ends
+func WritePcapng(w io.Writer, s *Session, ifaceName string) error {
+	intf := pcapgo.DefaultNgInterface
+	intf.Name = ifaceName
+	intf.LinkType = layers.LinkTypeEthernet
+	intf.SnapLength = s.Request.SnapLen
+
+	options := pcapgo.NgWriterOptions{
+		SectionInfo: pcapgo.NgSectionInfo{
+			Hardware:    runtime.GOARCH,
+			OS:          runtime.GOOS,
+			Application: "ConnectionStats",
+		},
+	}
+
+	writer, err := pcapgo.NewNgWriterInterface(w, intf, options)
+	if err != nil {
+		return err
+	}
+
+	for frame := range s.Frames {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     timer.ToWallClock(frame.Timestamp),
+			CaptureLength: len(frame.Data),
+			Length:        int(frame.Length),
+		}
+		if err := writer.WritePacket(ci, frame.Data); err != nil {
+			s.Stop()
+			for range s.Frames {
+			}
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
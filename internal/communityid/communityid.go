@@ -0,0 +1,34 @@
+package communityid
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"net/netip"
+)
+
+// Version prefix of the flow hashes computed by this package
+const prefix = "1:"
+
+// Compute returns the Community ID v1 of a flow (https://github.com/corelight/community-id-spec).
+// The endpoints are ordered before hashing, so both directions of a flow get the same ID
+func Compute(seed uint16, srcIP, dstIP netip.Addr, srcPort, dstPort uint16, proto uint8) string {
+	srcIP, dstIP = srcIP.Unmap(), dstIP.Unmap()
+
+	if cmp := srcIP.Compare(dstIP); cmp > 0 || (cmp == 0 && srcPort > dstPort) {
+		srcIP, dstIP = dstIP, srcIP
+		srcPort, dstPort = dstPort, srcPort
+	}
+
+	buf := make([]byte, 0, 2+16+16+2+4)
+	buf = binary.BigEndian.AppendUint16(buf, seed)
+	buf = append(buf, srcIP.AsSlice()...)
+	buf = append(buf, dstIP.AsSlice()...)
+	buf = append(buf, proto, 0)
+	buf = binary.BigEndian.AppendUint16(buf, srcPort)
+	buf = binary.BigEndian.AppendUint16(buf, dstPort)
+
+	sum := sha1.Sum(buf)
+
+	return prefix + base64.StdEncoding.EncodeToString(sum[:])
+}
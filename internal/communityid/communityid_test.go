@@ -0,0 +1,25 @@
+package communityid
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vector from the Community ID specification baseline
+func TestCompute(t *testing.T) {
+	src := netip.MustParseAddr("128.232.110.120")
+	dst := netip.MustParseAddr("66.35.250.204")
+
+	require.Equal(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", Compute(0, src, dst, 34855, 80, 6))
+	require.Equal(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", Compute(0, dst, src, 80, 34855, 6))
+	require.NotEqual(t, Compute(0, src, dst, 34855, 80, 6), Compute(1, src, dst, 34855, 80, 6))
+}
+
+func TestComputeIPv4Mapped(t *testing.T) {
+	src := netip.MustParseAddr("::ffff:128.232.110.120")
+	dst := netip.MustParseAddr("::ffff:66.35.250.204")
+
+	require.Equal(t, "1:LQU9qZlK+B5F3KDmev6m5PMibrg=", Compute(0, src, dst, 34855, 80, 6))
+}
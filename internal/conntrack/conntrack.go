@@ -0,0 +1,282 @@
+package conntrack
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"syscall"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// ctnetlink message types and attributes, from linux/netfilter/nfnetlink_conntrack.h
+const (
+	msgNew    = 0
+	msgGet    = 1
+	msgDelete = 2
+
+	attrTupleOrig     = 1
+	attrTupleReply    = 2
+	attrCountersOrig  = 9
+	attrCountersReply = 10
+	attrID            = 12
+
+	attrTupleIP    = 1
+	attrTupleProto = 2
+
+	attrIPv4Src = 1
+	attrIPv4Dst = 2
+	attrIPv6Src = 3
+	attrIPv6Dst = 4
+
+	attrProtoNum     = 1
+	attrProtoSrcPort = 2
+	attrProtoDstPort = 3
+
+	attrCountersPackets = 1
+	attrCountersBytes   = 2
+
+	attrTypeMask = 0x3fff // strips the nested and byte order flags
+)
+
+// Tuple is a direction of a connection, as seen by conntrack. IPv4 addresses are mapped to
+// IPv6, like those of the probe packets
+type Tuple struct {
+	Src     netip.Addr
+	Dst     netip.Addr
+	SrcPort uint16
+	DstPort uint16
+}
+
+// Reversed returns the tuple of the opposite direction
+func (t Tuple) Reversed() Tuple {
+	return Tuple{Src: t.Dst, Dst: t.Src, SrcPort: t.DstPort, DstPort: t.SrcPort}
+}
+
+func (t Tuple) String() string {
+	return fmt.Sprintf("%v -> %v", netip.AddrPortFrom(t.Src.Unmap(), t.SrcPort), netip.AddrPortFrom(t.Dst.Unmap(), t.DstPort))
+}
+
+// Flow is a conntrack entry. The counters are only filled in when the nf_conntrack_acct
+// sysctl is enabled
+type Flow struct {
+	ID           uint32
+	Proto        uint8
+	Orig         Tuple // sent by the initiator, before any translation
+	Reply        Tuple // sent back by the responder, as translated
+	OrigPackets  uint64
+	OrigBytes    uint64
+	ReplyPackets uint64
+	ReplyBytes   uint64
+}
+
+// NATed reports whether the addresses or ports of the connection are translated
+func (f Flow) NATed() bool {
+	return f.Reply != f.Orig.Reversed()
+}
+
+// Translated returns the orig tuple as it leaves the NAT, for the responder
+func (f Flow) Translated() Tuple {
+	return f.Reply.Reversed()
+}
+
+type EventType uint8
+
+const (
+	New     EventType = iota // conntrack started tracking the connection
+	Destroy                  // the connection ended or timed out
+)
+
+type Event struct {
+	Type EventType
+	Flow Flow
+}
+
+// Dump returns the connections tracked in the agent's namespace
+func Dump() ([]Flow, error) {
+	req := nl.NewNetlinkRequest(unix.NFNL_SUBSYS_CTNETLINK<<8|msgGet, unix.NLM_F_DUMP)
+	req.AddData(&nl.Nfgenmsg{NfgenFamily: unix.AF_UNSPEC, Version: unix.NFNETLINK_V0})
+
+	msgs, err := req.Execute(unix.NETLINK_NETFILTER, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]Flow, 0, len(msgs))
+	for _, msg := range msgs {
+		flow, err := parseFlow(msg)
+		if err != nil {
+			log.Printf("Skipping conntrack entry: %v", err)
+			continue
+		}
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+// Watch calls fn with the conntrack events of the agent's namespace until ctx is done.
+// Events lost when the socket overflows are logged
+func Watch(ctx context.Context, fn func(Event)) error {
+	s, err := nl.Subscribe(unix.NETLINK_NETFILTER, unix.NFNLGRP_CONNTRACK_NEW, unix.NFNLGRP_CONNTRACK_DESTROY)
+	if err != nil {
+		return fmt.Errorf("subscribing to conntrack events: %w", err)
+	}
+	defer s.Close()
+
+	// Wake up now and then to notice the end of ctx
+	if err := s.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		msgs, _, err := s.Receive()
+		switch {
+		case errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOBUFS):
+			log.Printf("Lost conntrack events, the agent is not keeping up")
+			continue
+		case err != nil:
+			return err
+		}
+
+		for _, msg := range msgs {
+			if ev, ok := parseEvent(msg); ok {
+				fn(ev)
+			}
+		}
+	}
+	return nil
+}
+
+func parseEvent(msg syscall.NetlinkMessage) (Event, bool) {
+	if msg.Header.Type>>8 != unix.NFNL_SUBSYS_CTNETLINK {
+		return Event{}, false
+	}
+
+	var ev Event
+	switch msg.Header.Type & 0xff {
+	case msgNew:
+		ev.Type = New
+	case msgDelete:
+		ev.Type = Destroy
+	default:
+		return Event{}, false
+	}
+
+	flow, err := parseFlow(msg.Data)
+	if err != nil {
+		log.Printf("Skipping conntrack event: %v", err)
+		return Event{}, false
+	}
+	ev.Flow = flow
+	return ev, true
+}
+
+// parseFlow parses a ctnetlink message, starting with its nfgenmsg header
+func parseFlow(data []byte) (Flow, error) {
+	var flow Flow
+	if len(data) < nl.SizeofNfgenmsg {
+		return flow, errors.New("short conntrack message")
+	}
+
+	err := walkAttrs(data[nl.SizeofNfgenmsg:], func(typ uint16, value []byte) error {
+		switch typ {
+		case attrTupleOrig:
+			return parseTuple(value, &flow.Orig, &flow.Proto)
+		case attrTupleReply:
+			return parseTuple(value, &flow.Reply, &flow.Proto)
+		case attrCountersOrig:
+			return parseCounters(value, &flow.OrigPackets, &flow.OrigBytes)
+		case attrCountersReply:
+			return parseCounters(value, &flow.ReplyPackets, &flow.ReplyBytes)
+		case attrID:
+			if len(value) >= 4 {
+				flow.ID = binary.BigEndian.Uint32(value)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return flow, err
+	}
+	if !flow.Orig.Src.IsValid() || !flow.Reply.Src.IsValid() {
+		return flow, errors.New("conntrack message without tuples")
+	}
+	return flow, nil
+}
+
+func parseTuple(data []byte, tuple *Tuple, proto *uint8) error {
+	return walkAttrs(data, func(typ uint16, value []byte) error {
+		switch typ {
+		case attrTupleIP:
+			return walkAttrs(value, func(typ uint16, value []byte) error {
+				addr, ok := netip.AddrFromSlice(value)
+				if !ok {
+					return fmt.Errorf("invalid conntrack address %x", value)
+				}
+				addr = netip.AddrFrom16(addr.As16())
+				switch typ {
+				case attrIPv4Src, attrIPv6Src:
+					tuple.Src = addr
+				case attrIPv4Dst, attrIPv6Dst:
+					tuple.Dst = addr
+				}
+				return nil
+			})
+		case attrTupleProto:
+			return walkAttrs(value, func(typ uint16, value []byte) error {
+				switch {
+				case typ == attrProtoNum && len(value) >= 1:
+					*proto = value[0]
+				case typ == attrProtoSrcPort && len(value) >= 2:
+					tuple.SrcPort = binary.BigEndian.Uint16(value)
+				case typ == attrProtoDstPort && len(value) >= 2:
+					tuple.DstPort = binary.BigEndian.Uint16(value)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func parseCounters(data []byte, packets, bytes *uint64) error {
+	return walkAttrs(data, func(typ uint16, value []byte) error {
+		if len(value) < 8 {
+			return nil
+		}
+		switch typ {
+		case attrCountersPackets:
+			*packets = binary.BigEndian.Uint64(value)
+		case attrCountersBytes:
+			*bytes = binary.BigEndian.Uint64(value)
+		}
+		return nil
+	})
+}
+
+// walkAttrs calls fn with the type, without flags, and the value of each netlink attribute
+func walkAttrs(data []byte, fn func(typ uint16, value []byte) error) error {
+	for len(data) >= unix.SizeofNlAttr {
+		length := int(nl.NativeEndian().Uint16(data))
+		typ := nl.NativeEndian().Uint16(data[2:]) & attrTypeMask
+		if length < unix.SizeofNlAttr || length > len(data) {
+			return fmt.Errorf("invalid conntrack attribute length %v", length)
+		}
+		if err := fn(typ, data[unix.SizeofNlAttr:length]); err != nil {
+			return err
+		}
+
+		aligned := (length + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if aligned >= len(data) {
+			break
+		}
+		data = data[aligned:]
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package conntrack
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+// attr encodes a netlink attribute, padded
+func attr(typ uint16, value ...[]byte) []byte {
+	var data []byte
+	for _, v := range value {
+		data = append(data, v...)
+	}
+	b := make([]byte, unix.SizeofNlAttr, unix.SizeofNlAttr+len(data)+3)
+	nl.NativeEndian().PutUint16(b, uint16(unix.SizeofNlAttr+len(data)))
+	nl.NativeEndian().PutUint16(b[2:], typ)
+	b = append(b, data...)
+	for len(b)%unix.NLA_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func be16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func be64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+func tupleAttr(typ uint16, src, dst string, sport, dport uint16) []byte {
+	return attr(typ|unix.NLA_F_NESTED,
+		attr(attrTupleIP|unix.NLA_F_NESTED,
+			attr(attrIPv4Src, netip.MustParseAddr(src).AsSlice()),
+			attr(attrIPv4Dst, netip.MustParseAddr(dst).AsSlice())),
+		attr(attrTupleProto|unix.NLA_F_NESTED,
+			attr(attrProtoNum, []byte{unix.IPPROTO_TCP}),
+			attr(attrProtoSrcPort, be16(sport)),
+			attr(attrProtoDstPort, be16(dport))))
+}
+
+// snatMessage is a ctnetlink message of a connection from 192.168.1.10:40000 to
+// 93.184.216.34:443, masqueraded as 203.0.113.1:50000
+func snatMessage() []byte {
+	msg := []byte{unix.AF_INET, unix.NFNETLINK_V0, 0, 0}
+	msg = append(msg, tupleAttr(attrTupleOrig, "192.168.1.10", "93.184.216.34", 40000, 443)...)
+	msg = append(msg, tupleAttr(attrTupleReply, "93.184.216.34", "203.0.113.1", 443, 50000)...)
+	msg = append(msg, attr(3, be32(0x1b0))...) // CTA_STATUS, not parsed
+	msg = append(msg, attr(attrCountersOrig|unix.NLA_F_NESTED, attr(attrCountersPackets, be64(5)), attr(attrCountersBytes, be64(400)))...)
+	msg = append(msg, attr(attrCountersReply|unix.NLA_F_NESTED, attr(attrCountersPackets, be64(4)), attr(attrCountersBytes, be64(3000)))...)
+	msg = append(msg, attr(attrID, be32(77))...)
+	return msg
+}
+
+func mapped(s string) netip.Addr {
+	return netip.AddrFrom16(netip.MustParseAddr(s).As16())
+}
+
+func TestParseFlow(t *testing.T) {
+	flow, err := parseFlow(snatMessage())
+	require.NoError(t, err)
+
+	require.Equal(t, Flow{
+		ID:    77,
+		Proto: unix.IPPROTO_TCP,
+		Orig:  Tuple{Src: mapped("192.168.1.10"), Dst: mapped("93.184.216.34"), SrcPort: 40000, DstPort: 443},
+		Reply: Tuple{Src: mapped("93.184.216.34"), Dst: mapped("203.0.113.1"), SrcPort: 443, DstPort: 50000},
+
+		OrigPackets: 5, OrigBytes: 400,
+		ReplyPackets: 4, ReplyBytes: 3000,
+	}, flow)
+
+	require.True(t, flow.NATed())
+	require.Equal(t, Tuple{Src: mapped("203.0.113.1"), Dst: mapped("93.184.216.34"), SrcPort: 50000, DstPort: 443}, flow.Translated())
+
+	_, err = parseFlow(snatMessage()[:20])
+	require.Error(t, err)
+}
+
+func TestParseEvent(t *testing.T) {
+	msg := syscall.NetlinkMessage{Data: snatMessage()}
+
+	msg.Header.Type = unix.NFNL_SUBSYS_CTNETLINK<<8 | msgDelete
+	ev, ok := parseEvent(msg)
+	require.True(t, ok)
+	require.Equal(t, Destroy, ev.Type)
+	require.Equal(t, uint32(77), ev.Flow.ID)
+
+	msg.Header.Type = unix.NFNL_SUBSYS_CTNETLINK<<8 | msgNew
+	ev, ok = parseEvent(msg)
+	require.True(t, ok)
+	require.Equal(t, New, ev.Type)
+
+	// Expectations are another subsystem
+	msg.Header.Type = 2<<8 | msgNew
+	_, ok = parseEvent(msg)
+	require.False(t, ok)
+}
@@ -0,0 +1,128 @@
+package conntrack
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// natGrace is how long a translation is kept after conntrack forgets its connection, so the
+// probe flow, pruned after a minute idle, is still joined to it until it goes
+const natGrace = 2 * time.Minute
+
+// Translation is the address translation of a connection, in the direction of its initiator
+type Translation struct {
+	PreNAT  Tuple // as sent by the initiator
+	PostNAT Tuple // as it leaves the NAT for the responder
+}
+
+// endpointKey identifies both directions of a flow
+type endpointKey struct {
+	proto  uint8
+	lo, hi netip.AddrPort
+}
+
+func keyOf(proto uint8, a, b netip.AddrPort) endpointKey {
+	if c := b.Addr().Compare(a.Addr()); c < 0 || c == 0 && b.Port() < a.Port() {
+		a, b = b, a
+	}
+	return endpointKey{proto: proto, lo: a, hi: b}
+}
+
+type natEntry struct {
+	translation Translation
+	expires     time.Time // zero while conntrack tracks the connection
+}
+
+// NATTable joins the flows of the probe to the translations of the conntrack entries of the
+// agent's namespace. A flow seen before the NAT, matching the orig tuple, and one seen after
+// it, matching the reply tuple, are both joined to the same translation
+type NATTable struct {
+	mu      sync.Mutex
+	entries map[endpointKey]*natEntry
+}
+
+func NewNATTable() *NATTable {
+	return &NATTable{entries: make(map[endpointKey]*natEntry)}
+}
+
+// Lookup returns the translation of the flow between a and b, in either direction
+func (t *NATTable) Lookup(proto uint8, a, b netip.AddrPort) (Translation, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[keyOf(proto, a, b)]
+	if !ok {
+		return Translation{}, false
+	}
+	return e.translation, true
+}
+
+// Run loads the translated connections tracked, then follows the conntrack events until ctx
+// is done
+func (t *NATTable) Run(ctx context.Context) error {
+	flows, err := Dump()
+	if err != nil {
+		log.Printf("Failed listing the conntrack entries: %v", err)
+	}
+	for _, flow := range flows {
+		t.update(Event{Type: New, Flow: flow}, time.Now())
+	}
+
+	go func() {
+		ticker := time.NewTicker(natGrace / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				t.prune(now)
+			}
+		}
+	}()
+
+	return Watch(ctx, func(ev Event) {
+		t.update(ev, time.Now())
+	})
+}
+
+func (t *NATTable) update(ev Event, now time.Time) {
+	flow := ev.Flow
+	if !flow.NATed() {
+		return
+	}
+
+	orig := keyOf(flow.Proto, netip.AddrPortFrom(flow.Orig.Src, flow.Orig.SrcPort), netip.AddrPortFrom(flow.Orig.Dst, flow.Orig.DstPort))
+	reply := keyOf(flow.Proto, netip.AddrPortFrom(flow.Reply.Src, flow.Reply.SrcPort), netip.AddrPortFrom(flow.Reply.Dst, flow.Reply.DstPort))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ev.Type == Destroy {
+		for _, key := range []endpointKey{orig, reply} {
+			if e, ok := t.entries[key]; ok {
+				e.expires = now.Add(natGrace)
+			}
+		}
+		return
+	}
+
+	e := &natEntry{translation: Translation{PreNAT: flow.Orig, PostNAT: flow.Translated()}}
+	t.entries[orig] = e
+	t.entries[reply] = e
+}
+
+// prune removes the translations of the connections conntrack forgot more than natGrace ago
+func (t *NATTable) prune(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, e := range t.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package conntrack
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATTable(t *testing.T) {
+	flow, err := parseFlow(snatMessage())
+	require.NoError(t, err)
+
+	nats := NewNATTable()
+	now := time.Now()
+	nats.update(Event{Type: New, Flow: flow}, now)
+
+	client := netip.AddrPortFrom(mapped("192.168.1.10"), 40000)
+	server := netip.AddrPortFrom(mapped("93.184.216.34"), 443)
+	masqueraded := netip.AddrPortFrom(mapped("203.0.113.1"), 50000)
+	want := Translation{PreNAT: flow.Orig, PostNAT: flow.Translated()}
+
+	// The flow seen inside the NAT, then outside it, in either orientation
+	for _, ends := range [][2]netip.AddrPort{{client, server}, {server, client}, {masqueraded, server}, {server, masqueraded}} {
+		nat, ok := nats.Lookup(unix.IPPROTO_TCP, ends[0], ends[1])
+		require.True(t, ok, ends)
+		require.Equal(t, want, nat)
+	}
+
+	_, ok := nats.Lookup(unix.IPPROTO_UDP, client, server)
+	require.False(t, ok)
+
+	// Kept for a while after conntrack forgets the connection
+	nats.update(Event{Type: Destroy, Flow: flow}, now)
+	nats.prune(now.Add(natGrace / 2))
+	_, ok = nats.Lookup(unix.IPPROTO_TCP, client, server)
+	require.True(t, ok)
+
+	nats.prune(now.Add(natGrace + time.Second))
+	_, ok = nats.Lookup(unix.IPPROTO_TCP, masqueraded, server)
+	require.False(t, ok)
+	require.Empty(t, nats.entries)
+}
+
+func TestNATTableSkipsUntranslated(t *testing.T) {
+	flow, err := parseFlow(snatMessage())
+	require.NoError(t, err)
+	flow.Reply = flow.Orig.Reversed()
+
+	nats := NewNATTable()
+	nats.update(Event{Type: New, Flow: flow}, time.Now())
+	require.Empty(t, nats.entries)
+}
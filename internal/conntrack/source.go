@@ -0,0 +1,138 @@
+package conntrack
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+)
+
+const acctSysctl = "/proc/sys/net/netfilter/nf_conntrack_acct"
+
+// Source builds the flows from the conntrack entries of the agent's namespace, for the hosts
+// the eBPF probe cannot run on. A is the initiator, its packets are counted as outbound.
+// Flows have no interface and no TCP history, and their counters, kept by conntrack only
+// when nf_conntrack_acct is enabled, are refreshed every Interval
+type Source struct {
+	Interval time.Duration
+}
+
+func NewSource(interval time.Duration) *Source {
+	return &Source{Interval: interval}
+}
+
+func (src *Source) Run(ctx context.Context, ft *flowtable.FlowTable, workers *pipeline.Pipeline) error {
+	if acct, err := os.ReadFile(acctSysctl); err == nil && strings.TrimSpace(string(acct)) == "0" {
+		log.Printf("Conntrack accounting is disabled, flows have no counters. Enable it with sysctl net.netfilter.nf_conntrack_acct=1")
+	}
+
+	if err := src.refresh(ft, workers); err != nil {
+		return err
+	}
+
+	events := make(chan Event)
+	watchDone := make(chan error, 1)
+	go func() {
+		watchDone <- Watch(ctx, func(ev Event) {
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	ticker := time.NewTicker(src.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return <-watchDone
+
+		case err := <-watchDone:
+			return err
+
+		case ev := <-events:
+			queue(ft, workers, ev.Flow, ev.Type == Destroy)
+			workers.Flush()
+
+		case <-ticker.C:
+			if err := src.refresh(ft, workers); err != nil {
+				log.Printf("Failed listing the conntrack entries: %v", err)
+			}
+		}
+	}
+}
+
+// refresh updates the flows of the connections tracked
+func (src *Source) refresh(ft *flowtable.FlowTable, workers *pipeline.Pipeline) error {
+	flows, err := Dump()
+	if err != nil {
+		return err
+	}
+	for _, flow := range flows {
+		queue(ft, workers, flow, false)
+	}
+	workers.Flush()
+	return nil
+}
+
+// queue hands the update of the flow of a conntrack entry to the worker owning it
+func queue(ft *flowtable.FlowTable, workers *pipeline.Pipeline, flow Flow, destroyed bool) {
+	if hash, update, ok := flowUpdate(ft, flow, destroyed); ok {
+		workers.Upsert(hash, update)
+	}
+}
+
+// flowUpdate returns the hash of the flow of a conntrack entry and the update copying the
+// entry counters to it, closing it when the entry was destroyed. The flow is keyed by the
+// orig tuple, as the probe would key its packets
+func flowUpdate(ft *flowtable.FlowTable, flow Flow, destroyed bool) (uint64, func(*flowtable.Connection, bool) flowtable.Verdict, bool) {
+	proto, ok := packet.ProtoName(flow.Proto)
+	if !ok {
+		return 0, nil, false
+	}
+
+	pkt := packet.Packet{
+		SrcIP:    flow.Orig.Src,
+		DstIP:    flow.Orig.Dst,
+		SrcPort:  flow.Orig.SrcPort,
+		DstPort:  flow.Orig.DstPort,
+		Protocol: flow.Proto,
+		Outbound: true,
+	}
+	hash := pkt.Hash()
+	now := timer.Now()
+
+	return hash, func(conn *flowtable.Connection, found bool) flowtable.Verdict {
+		if !found {
+			if destroyed {
+				return flowtable.Skip
+			}
+			conn.Hash = hash
+			conn.Proto = proto
+			conn.AIp, conn.APort = pkt.SrcIP, pkt.SrcPort
+			conn.BIp, conn.BPort = pkt.DstIP, pkt.DstPort
+			conn.Outbound = true
+			conn.Ts_ini = now
+			conn.CommunityID = pkt.CommunityID(ft.CommunityIDSeed)
+			conn.SampleRate = ft.SampleRate()
+		}
+
+		// Seen as long as conntrack tracks it, idle or not
+		conn.Ts_fin = now
+		conn.Packets_out, conn.Bytes_out = flow.OrigPackets, flow.OrigBytes
+		conn.Packets_in, conn.Bytes_in = flow.ReplyPackets, flow.ReplyBytes
+
+		if destroyed {
+			return flowtable.Close
+		}
+		return flowtable.Keep
+	}, true
+}
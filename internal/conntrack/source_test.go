@@ -0,0 +1,55 @@
+package conntrack
+
+import (
+	"testing"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	flow, err := parseFlow(snatMessage())
+	require.NoError(t, err)
+
+	ft := flowtable.NewFlowTable()
+	var events []flowtable.Event
+	ft.OnEvent(func(ev flowtable.Event) { events = append(events, ev) })
+
+	// Each update is applied by the workers before the checks
+	update := func(ft *flowtable.FlowTable, flow Flow, destroyed bool) {
+		workers := pipeline.New(ft, pipeline.Config{})
+		queue(ft, workers, flow, destroyed)
+		workers.Close()
+	}
+
+	// Destroyed before it was seen
+	update(ft, flow, true)
+	require.Zero(t, ft.Len())
+
+	update(ft, flow, false)
+	conns := ft.GetConnList()
+	require.Len(t, conns, 1)
+	conn := conns[0]
+	require.Equal(t, "TCP", conn.Proto)
+	require.Equal(t, flow.Orig.Src, conn.AIp)
+	require.Equal(t, uint16(443), conn.BPort)
+	require.True(t, conn.Outbound)
+	require.Equal(t, uint64(5), conn.Packets_out)
+	require.Equal(t, uint64(3000), conn.Bytes_in)
+	require.NotEmpty(t, conn.CommunityID)
+
+	// The counters of conntrack are totals
+	flow.OrigPackets, flow.ReplyPackets = 7, 6
+	update(ft, flow, false)
+	conn, _ = ft.Get(conn.Hash)
+	require.Equal(t, uint64(7), conn.Packets_out)
+	require.Equal(t, uint64(6), conn.Packets_in)
+
+	update(ft, flow, true)
+	require.Zero(t, ft.Len())
+	require.Len(t, events, 2)
+	require.Equal(t, flowtable.FlowEnd, events[1].Type)
+	require.Equal(t, uint64(7), events[1].Conn.Packets_out)
+}
@@ -0,0 +1,210 @@
+package dataset
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/record"
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	fileSuffix       = ".parquet"
+	inProgressSuffix = ".inprogress"
+)
+
+type Config struct {
+	Dir           string        // directory the parquet files are written to
+	Prefix        string        // file name prefix, the file name is <prefix>-<timestamp>-<seq>.parquet
+	MaxSize       int64         // rotate once the file reaches this many bytes, 0 disables it
+	MaxAge        time.Duration // rotate once the file has been open this long, 0 disables it
+	FlushInterval time.Duration // how often buffered records are written as a row group
+}
+
+// Writer appends flow records to a dataset of parquet files. The file being written has
+// the .inprogress suffix, which is removed once the file is rotated and its footer written
+type Writer struct {
+	cfg    Config
+	mu     sync.Mutex
+	file   *os.File
+	size   *countingWriter
+	pw     *parquet.GenericWriter[record.Record]
+	opened time.Time
+	seq    int
+	rows   []record.Record
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// NewWriter Constructs a new Writer and starts flushing records every cfg.FlushInterval
+func NewWriter(cfg Config) (*Writer, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "flows"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second * 10
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		cfg:    cfg,
+		ticker: time.NewTicker(cfg.FlushInterval),
+		done:   make(chan struct{}),
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-w.ticker.C:
+				if err := w.Flush(); err != nil {
+					log.Printf("Failed flushing parquet records: %v", err)
+				}
+			}
+		}
+	}()
+
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	w.opened = time.Now()
+	w.seq++
+	name := fmt.Sprintf("%s-%s-%04d%s%s", w.cfg.Prefix, w.opened.UTC().Format("20060102T150405"), w.seq, fileSuffix, inProgressSuffix)
+
+	file, err := os.OpenFile(filepath.Join(w.cfg.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w.file = file
+	w.size = &countingWriter{w: file}
+	// Row groups are already batched by FlushInterval, write them straight to the file so
+	// that the size seen by the rotation is accurate
+	w.pw = parquet.NewGenericWriter[record.Record](w.size,
+		parquet.Compression(&parquet.Snappy),
+		parquet.WriteBufferSize(0),
+	)
+
+	return nil
+}
+
+// close writes the parquet footer and gives the file its final name. Files without any
+// row group are removed instead
+func (w *Writer) close() error {
+	if w.size.n == 0 {
+		w.file.Close()
+		return os.Remove(w.file.Name())
+	}
+
+	if err := w.pw.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	name := w.file.Name()
+	return os.Rename(name, strings.TrimSuffix(name, inProgressSuffix))
+}
+
+func (w *Writer) Name() string {
+	return "parquet"
+}
+
+// Write buffers the record of ev until the next flush. Flow start events are ignored
+func (w *Writer) Write(ev flowtable.Event) error {
+	if ev.Type == flowtable.FlowStart {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rows = append(w.rows, record.New(ev))
+
+	return nil
+}
+
+// writeRows writes the buffered records as a row group
+func (w *Writer) writeRows() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	if _, err := w.pw.Write(w.rows); err != nil {
+		return err
+	}
+	if err := w.pw.Flush(); err != nil {
+		return err
+	}
+	w.rows = w.rows[:0]
+
+	return nil
+}
+
+// Flush writes the buffered records and rotates the file if needed
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.writeRows(); err != nil {
+		return err
+	}
+
+	if w.size.n == 0 {
+		return nil
+	}
+
+	if (w.cfg.MaxSize > 0 && w.size.n >= w.cfg.MaxSize) ||
+		(w.cfg.MaxAge > 0 && time.Since(w.opened) >= w.cfg.MaxAge) {
+		if err := w.close(); err != nil {
+			return err
+		}
+		return w.open()
+	}
+
+	return nil
+}
+
+// Close writes the pending records and closes the current file
+func (w *Writer) Close() error {
+	w.ticker.Stop()
+	close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.writeRows(); err != nil {
+		log.Printf("Failed writing parquet records: %v", err)
+	}
+
+	return w.close()
+}
@@ -0,0 +1,54 @@
+package dataset
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/record"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(Config{Dir: dir, MaxSize: 1})
+	require.NoError(t, err)
+
+	conn := flowtable.Connection{
+		Hash:        1,
+		Proto:       "TCP",
+		AIp:         netip.MustParseAddr("192.168.0.156"),
+		BIp:         netip.MustParseAddr("1.1.1.1"),
+		APort:       53264,
+		BPort:       443,
+		Packets_in:  2,
+		Packets_out: 4,
+		Bytes_in:    200,
+		Bytes_out:   100,
+		Ts_ini:      1000000000,
+		Ts_fin:      3000000000,
+	}
+
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowActive, Conn: conn}))
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowEnd, Reason: flowtable.EndClosed, Conn: conn}))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "flows-*"+fileSuffix))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	rows, err := parquet.ReadFile[record.Record](files[1])
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+
+	require.True(t, rows[0].Final)
+	require.Equal(t, flowtable.EndClosed, rows[0].EndReason)
+	require.Equal(t, "1.1.1.1", rows[0].BIp)
+	require.Equal(t, float64(2), rows[0].Duration)
+	require.Equal(t, 0.5, rows[0].InPoutP)
+}
@@ -0,0 +1,352 @@
+package doctor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Status is the outcome of a check
+type Status uint8
+
+const (
+	OK   Status = iota // the feature works
+	Warn               // the probe runs, with a feature degraded or missing
+	Fail               // the probe will not start
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	}
+	return "FAIL"
+}
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report holds the checks in the order they ran
+type Report []Check
+
+// MemlockBytes is the locked memory limit the probe sets on kernels accounting BPF maps to it
+const MemlockBytes = 1024 * 1024 * 10
+
+// Kernel versions the features appeared in
+var (
+	ringbufVersion = kernelVersion{5, 8}
+	memcgVersion   = kernelVersion{5, 11} // BPF memory is charged to the cgroup, not to memlock
+	tcxVersion     = kernelVersion{6, 6}
+)
+
+// Run checks the kernel, the process and the interfaces the probe attaches to, without
+// loading the probe
+func Run(ifaces ...string) Report {
+	var r Report
+
+	version, err := uname()
+	if err != nil {
+		r.add("kernel version", Fail, err.Error())
+	} else {
+		status := OK
+		detail := version.String()
+		if version.less(ringbufVersion) {
+			status = Warn
+			detail += ", ringbufs need " + ringbufVersion.String() + ", events go through a perf event array"
+		}
+		r.add("kernel version", status, detail)
+	}
+
+	r.checkBTF()
+	r.checkCapabilities()
+	r.checkMemlock(version)
+	r.checkFeatures(version)
+
+	for _, iface := range ifaces {
+		r.checkInterface(iface)
+	}
+
+	return r
+}
+
+func (r *Report) add(name string, status Status, detail string) {
+	*r = append(*r, Check{Name: name, Status: status, Detail: detail})
+}
+
+// Failed reports whether any check failed
+func (r Report) Failed() bool {
+	for _, c := range r {
+		if c.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints a line per check, then the verdict
+func (r Report) Write(w io.Writer) error {
+	for _, c := range r {
+		if _, err := fmt.Fprintf(w, "[%4s] %-20s %s\n", c.Status, c.Name, c.Detail); err != nil {
+			return err
+		}
+	}
+
+	verdict := "The probe can be loaded"
+	if r.Failed() {
+		verdict = "The probe will fail to load, fix the failed checks first"
+	}
+	_, err := fmt.Fprintln(w, verdict)
+	return err
+}
+
+func (r *Report) checkBTF() {
+	if _, err := os.Stat("/sys/kernel/btf/vmlinux"); err != nil {
+		r.add("BTF", Warn, "no /sys/kernel/btf/vmlinux, the kernel types are not available")
+		return
+	}
+	if _, err := btf.LoadKernelSpec(); err != nil {
+		r.add("BTF", Warn, fmt.Sprintf("failed loading the kernel types: %v", err))
+		return
+	}
+	r.add("BTF", OK, "/sys/kernel/btf/vmlinux")
+}
+
+// Capabilities used by the probe
+const (
+	capNetAdmin    = 12
+	capSysAdmin    = 21
+	capSysResource = 24
+	capPerfmon     = 38
+	capBPF         = 39
+)
+
+func (r *Report) checkCapabilities() {
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		r.add("capabilities", Fail, err.Error())
+		return
+	}
+	caps, err := parseCapEff(string(status))
+	if err != nil {
+		r.add("capabilities", Fail, err.Error())
+		return
+	}
+
+	has := func(c uint) bool { return caps&(1<<c) != 0 }
+
+	var missing []string
+	if !has(capNetAdmin) {
+		missing = append(missing, "CAP_NET_ADMIN")
+	}
+	if !has(capSysAdmin) && !(has(capBPF) && has(capPerfmon)) {
+		missing = append(missing, "CAP_BPF and CAP_PERFMON, or CAP_SYS_ADMIN")
+	}
+	if len(missing) > 0 {
+		r.add("capabilities", Fail, "missing "+strings.Join(missing, ", "))
+		return
+	}
+	r.add("capabilities", OK, fmt.Sprintf("effective %#x", caps))
+}
+
+// parseCapEff returns the effective capabilities from the contents of /proc/self/status
+func parseCapEff(status string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(status))
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+	}
+	return 0, errors.New("no CapEff in /proc/self/status")
+}
+
+func (r *Report) checkMemlock(version kernelVersion) {
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		r.add("memlock limit", Fail, err.Error())
+		return
+	}
+
+	detail := fmt.Sprintf("soft %v, hard %v", rlimitString(limit.Cur), rlimitString(limit.Max))
+	if !version.less(memcgVersion) {
+		r.add("memlock limit", OK, detail+", not used by this kernel")
+		return
+	}
+
+	if limit.Max != unix.RLIM_INFINITY && limit.Max < MemlockBytes {
+		caps, err := os.ReadFile("/proc/self/status")
+		eff, perr := parseCapEff(string(caps))
+		if err != nil || perr != nil || eff&(1<<capSysResource) == 0 {
+			r.add("memlock limit", Fail, fmt.Sprintf("%v, the probe raises it to %v, which needs CAP_SYS_RESOURCE", detail, MemlockBytes))
+			return
+		}
+	}
+	r.add("memlock limit", OK, detail)
+}
+
+func rlimitString(v uint64) string {
+	if v == unix.RLIM_INFINITY {
+		return "unlimited"
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+func (r *Report) checkFeatures(version kernelVersion) {
+	if err := features.HaveProgramType(ebpf.SchedCLS); err != nil {
+		r.add("tc programs", Fail, err.Error())
+	} else {
+		r.add("tc programs", OK, "BPF_PROG_TYPE_SCHED_CLS")
+	}
+
+	switch err := features.HaveMapType(ebpf.RingBuf); {
+	case err == nil:
+		r.add("ringbuf", OK, "BPF_MAP_TYPE_RINGBUF")
+	case errors.Is(err, ebpf.ErrNotSupported):
+		r.add("ringbuf", Warn, "not supported, events go through a perf event array and packet capture is disabled")
+	default:
+		r.add("ringbuf", Warn, err.Error())
+	}
+
+	if err := features.HaveMapType(ebpf.PerfEventArray); err != nil {
+		r.add("perf event array", Warn, err.Error())
+	} else {
+		r.add("perf event array", OK, "BPF_MAP_TYPE_PERF_EVENT_ARRAY")
+	}
+
+	if err := features.HaveMapType(ebpf.LPMTrie); err != nil {
+		r.add("LPM trie", Warn, "not supported, network filter rules are disabled")
+	} else {
+		r.add("LPM trie", OK, "BPF_MAP_TYPE_LPM_TRIE")
+	}
+
+	if err := features.HaveProgramType(ebpf.XDP); err != nil {
+		r.add("XDP programs", Warn, "not supported, -ingress must be tc")
+	} else {
+		r.add("XDP programs", OK, "BPF_PROG_TYPE_XDP, native mode depends on the driver")
+	}
+
+	if version.less(tcxVersion) {
+		r.add("tcx", Warn, "needs "+tcxVersion.String()+", the probe attaches through a clsact qdisc, -attach auto falls back to it")
+	} else {
+		r.add("tcx", OK, "available from the kernel version")
+	}
+}
+
+func (r *Report) checkInterface(name string) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		r.add("interface", Fail, fmt.Sprintf("%v: %v", name, err))
+		return
+	}
+
+	attrs := link.Attrs()
+	status := OK
+	detail := fmt.Sprintf("%v, index %d, type %v", name, attrs.Index, link.Type())
+	if attrs.OperState != netlink.OperUp && attrs.OperState != netlink.OperUnknown {
+		status = Warn
+		detail += ", " + attrs.OperState.String()
+	}
+	r.add("interface", status, detail)
+
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		r.add("qdiscs", Warn, name+": "+err.Error())
+		return
+	}
+	clsact := false
+	for _, q := range qdiscs {
+		if q.Type() == "clsact" {
+			clsact = true
+		}
+	}
+	if !clsact {
+		r.add("qdiscs", OK, name+": no clsact qdisc, the probe creates one")
+		return
+	}
+	r.add("qdiscs", OK, name+": clsact qdisc present, the probe reuses it")
+
+	for _, parent := range []struct {
+		name   string
+		handle uint32
+	}{{"ingress", netlink.HANDLE_MIN_INGRESS}, {"egress", netlink.HANDLE_MIN_EGRESS}} {
+		filters, err := netlink.FilterList(link, parent.handle)
+		if err != nil {
+			r.add(parent.name+" filters", Warn, name+": "+err.Error())
+			continue
+		}
+		r.add(parent.name+" filters", OK, name+": "+describeFilters(filters))
+	}
+}
+
+// describeFilters lists the tc filters found on a hook, which the probe's filters replace
+// when they share its handle and priority
+func describeFilters(filters []netlink.Filter) string {
+	if len(filters) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(filters))
+	for _, f := range filters {
+		attrs := f.Attrs()
+		name := fmt.Sprintf("%v %x:%x prio %d", f.Type(), attrs.Handle>>16, attrs.Handle&0xffff, attrs.Priority)
+		if bpf, ok := f.(*netlink.BpfFilter); ok && bpf.Name != "" {
+			name += " " + bpf.Name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// kernelVersion is the major and minor version of a kernel release
+type kernelVersion struct {
+	major, minor int
+}
+
+func (v kernelVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+func (v kernelVersion) less(o kernelVersion) bool {
+	return v.major < o.major || v.major == o.major && v.minor < o.minor
+}
+
+func uname() (kernelVersion, error) {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil {
+		return kernelVersion{}, err
+	}
+	return parseRelease(unix.ByteSliceToString(u.Release[:]))
+}
+
+// parseRelease parses a kernel release such as 6.1.0-18-amd64
+func parseRelease(release string) (kernelVersion, error) {
+	fields := strings.SplitN(release, ".", 3)
+	if len(fields) < 2 {
+		return kernelVersion{}, fmt.Errorf("invalid kernel release %q", release)
+	}
+	major, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return kernelVersion{}, fmt.Errorf("invalid kernel release %q", release)
+	}
+	minor, err := strconv.Atoi(fields[1][:len(fields[1])-len(strings.TrimLeft(fields[1], "0123456789"))])
+	if err != nil {
+		return kernelVersion{}, fmt.Errorf("invalid kernel release %q", release)
+	}
+	return kernelVersion{major, minor}, nil
+}
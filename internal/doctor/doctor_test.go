@@ -0,0 +1,47 @@
+package doctor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRelease(t *testing.T) {
+	for release, want := range map[string]kernelVersion{
+		"6.1.0-18-amd64":  {6, 1},
+		"5.15-rc3":        {5, 15},
+		"4.19.112+":       {4, 19},
+		"6.18.44-fc-v139": {6, 18},
+	} {
+		v, err := parseRelease(release)
+		require.NoError(t, err)
+		require.Equal(t, want, v, release)
+	}
+
+	_, err := parseRelease("linux")
+	require.Error(t, err)
+
+	require.True(t, kernelVersion{5, 4}.less(ringbufVersion))
+	require.False(t, kernelVersion{6, 1}.less(ringbufVersion))
+}
+
+func TestParseCapEff(t *testing.T) {
+	status := "Name:\tconnstats\nCapInh:\t0000000000000000\nCapEff:\t000001ffffffffff\n"
+	caps, err := parseCapEff(status)
+	require.NoError(t, err)
+	require.NotZero(t, caps&(1<<capBPF))
+
+	_, err = parseCapEff("Name:\tconnstats\n")
+	require.Error(t, err)
+}
+
+func TestReport(t *testing.T) {
+	r := Run("lo")
+	require.NotEmpty(t, r)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf))
+	require.Contains(t, buf.String(), "kernel version")
+	require.Contains(t, buf.String(), "lo, index 1")
+}
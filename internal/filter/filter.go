@@ -0,0 +1,342 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gabspt/ConnectionStats/internal/capture"
+)
+
+// MaxRules is the number of hit counters of the probe, rule ids are below it
+const MaxRules = 1024
+
+type Action uint8
+
+const (
+	Allow Action = 1
+	Deny  Action = 2
+)
+
+var (
+	ErrTooManyRules = errors.New("too many filter rules")
+	ErrNotFound     = errors.New("filter rule not found")
+)
+
+// PortRange is an inclusive range of ports, matching any port when Max is 0
+type PortRange struct {
+	Min, Max uint16
+}
+
+func (r PortRange) IsSet() bool {
+	return r.Max > 0
+}
+
+func (r PortRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(int(r.Min))
+	}
+	return strconv.Itoa(int(r.Min)) + "-" + strconv.Itoa(int(r.Max))
+}
+
+// Rule matches the packets meeting all of its conditions, on the prefix or the port range of
+// the local and remote ends and on the protocol. Conditions left unset match anything.
+// Deny rules win over allow rules, and once an allow rule exists only the packets matching
+// one are monitored
+type Rule struct {
+	ID          uint32
+	Action      Action
+	LocalNet    netip.Prefix
+	RemoteNet   netip.Prefix
+	LocalPorts  PortRange
+	RemotePorts PortRange
+	Proto       uint8 // 0 matches any protocol, only TCP and UDP when a port range is set
+}
+
+// Protocols returns the protocols the rule matches, nil when it matches any
+func (r Rule) Protocols() []uint8 {
+	switch {
+	case r.Proto != 0:
+		return []uint8{r.Proto}
+	case r.LocalPorts.IsSet() || r.RemotePorts.IsSet():
+		return []uint8{6, 17}
+	}
+	return nil
+}
+
+var protoNames = map[string]uint8{"tcp": 6, "udp": 17}
+
+// ParseRule parses a rule written as an action followed by one or more conditions
+//
+//	allow|deny [local|remote <address or prefix>]... [local|remote port <port>[-<port>][/tcp|udp]]... [proto tcp|udp]
+//
+// such as "deny remote 10.0.0.0/8 remote port 53/udp". Each condition is given once
+func ParseRule(s string) (Rule, error) {
+	rule := Rule{}
+	fields := strings.Fields(strings.ToLower(s))
+
+	if len(fields) < 2 {
+		return rule, fmt.Errorf("invalid rule %q", s)
+	}
+
+	switch fields[0] {
+	case "allow":
+		rule.Action = Allow
+	case "deny":
+		rule.Action = Deny
+	default:
+		return rule, fmt.Errorf("invalid rule %q: unknown action %v", s, fields[0])
+	}
+
+	setProto := func(name string) error {
+		proto := protoNames[name]
+		if proto == 0 {
+			return fmt.Errorf("invalid rule %q: expected tcp or udp", s)
+		}
+		if rule.Proto != 0 && rule.Proto != proto {
+			return fmt.Errorf("invalid rule %q: conflicting protocols", s)
+		}
+		rule.Proto = proto
+		return nil
+	}
+
+	for fields = fields[1:]; len(fields) > 0; {
+		if fields[0] == "proto" {
+			if len(fields) < 2 {
+				return rule, fmt.Errorf("invalid rule %q: expected tcp or udp", s)
+			}
+			if err := setProto(fields[1]); err != nil {
+				return rule, err
+			}
+			fields = fields[2:]
+			continue
+		}
+
+		var net *netip.Prefix
+		var ports *PortRange
+		switch fields[0] {
+		case "local":
+			net, ports = &rule.LocalNet, &rule.LocalPorts
+		case "remote":
+			net, ports = &rule.RemoteNet, &rule.RemotePorts
+		default:
+			return rule, fmt.Errorf("invalid rule %q: expected local, remote or proto, got %v", s, fields[0])
+		}
+
+		switch {
+		case len(fields) >= 3 && fields[1] == "port":
+			if ports.IsSet() {
+				return rule, fmt.Errorf("invalid rule %q: %v port given twice", s, fields[0])
+			}
+			portRange, proto, found := strings.Cut(fields[2], "/")
+			if found {
+				if err := setProto(proto); err != nil {
+					return rule, err
+				}
+			}
+			first, last, found := strings.Cut(portRange, "-")
+			if !found {
+				last = first
+			}
+			min, err1 := strconv.ParseUint(first, 10, 16)
+			max, err2 := strconv.ParseUint(last, 10, 16)
+			if err1 != nil || err2 != nil || min > max || max == 0 {
+				return rule, fmt.Errorf("invalid rule %q: bad port range %v", s, portRange)
+			}
+			*ports = PortRange{Min: uint16(min), Max: uint16(max)}
+			fields = fields[3:]
+
+		case len(fields) >= 2:
+			if net.IsValid() {
+				return rule, fmt.Errorf("invalid rule %q: %v prefix given twice", s, fields[0])
+			}
+			prefix, err := capture.ParseNet(fields[1])
+			if err != nil || !prefix.IsValid() {
+				return rule, fmt.Errorf("invalid rule %q: bad prefix %v", s, fields[1])
+			}
+			*net = prefix
+			fields = fields[2:]
+
+		default:
+			return rule, fmt.Errorf("invalid rule %q", s)
+		}
+	}
+
+	return rule, nil
+}
+
+// String formats the rule the way ParseRule reads it
+func (r Rule) String() string {
+	var b strings.Builder
+	if r.Action == Deny {
+		b.WriteString("deny")
+	} else {
+		b.WriteString("allow")
+	}
+
+	proto := ""
+	for name, num := range protoNames {
+		if num == r.Proto {
+			proto = name
+		}
+	}
+
+	// The protocol follows the first port range, or is a condition of its own
+	for _, side := range []struct {
+		name  string
+		net   netip.Prefix
+		ports PortRange
+	}{{"local", r.LocalNet, r.LocalPorts}, {"remote", r.RemoteNet, r.RemotePorts}} {
+		if side.net.IsValid() {
+			fmt.Fprintf(&b, " %s %s", side.name, side.net)
+		}
+		if side.ports.IsSet() {
+			fmt.Fprintf(&b, " %s port %s", side.name, side.ports)
+			if proto != "" {
+				b.WriteString("/" + proto)
+				proto = ""
+			}
+		}
+	}
+	if proto != "" {
+		b.WriteString(" proto " + proto)
+	}
+
+	return b.String()
+}
+
+// Backend installs the rules in the probe and reads their hit counters
+type Backend interface {
+	SetRules(rules []Rule) error
+	Hits() ([MaxRules]uint64, error)
+}
+
+// RuleStats is a rule with the number of packets it matched
+type RuleStats struct {
+	Rule
+	Hits uint64
+}
+
+// Manager keeps the filter rules and installs them in the probe
+type Manager struct {
+	mu       sync.Mutex
+	backend  Backend
+	rules    map[uint32]Rule
+	baseline [MaxRules]uint64
+}
+
+func NewManager() *Manager {
+	return &Manager{rules: make(map[uint32]Rule)}
+}
+
+// SetBackend installs the rules in the probe, a nil backend detaches it
+func (m *Manager) SetBackend(backend Backend) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.backend = backend
+	if backend == nil {
+		return nil
+	}
+
+	hits, err := backend.Hits()
+	if err != nil {
+		return err
+	}
+	m.baseline = hits
+
+	return backend.SetRules(m.list())
+}
+
+func (m *Manager) list() []Rule {
+	return sortRules(m.rules)
+}
+
+func sortRules(byID map[uint32]Rule) []Rule {
+	rules := make([]Rule, 0, len(byID))
+	for _, rule := range byID {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Update adds and removes rules in one step, the new rules get the lowest free ids
+func (m *Manager) Update(add []Rule, remove []uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range remove {
+		if _, ok := m.rules[id]; !ok {
+			return fmt.Errorf("rule %d: %w", id, ErrNotFound)
+		}
+	}
+	if len(m.rules)-len(remove)+len(add) > MaxRules {
+		return ErrTooManyRules
+	}
+
+	hits := m.baseline
+	if m.backend != nil {
+		var err error
+		if hits, err = m.backend.Hits(); err != nil {
+			return err
+		}
+	}
+
+	// The rules and counters are only replaced once the probe took the new rules
+	rules := make(map[uint32]Rule, len(m.rules)+len(add))
+	for id, rule := range m.rules {
+		rules[id] = rule
+	}
+	for _, id := range remove {
+		delete(rules, id)
+	}
+
+	baseline := m.baseline
+	id := uint32(0)
+	for _, rule := range add {
+		for _, taken := rules[id]; taken; _, taken = rules[id] {
+			id++
+		}
+		rule.ID = id
+		rules[id] = rule
+		baseline[id] = hits[id]
+	}
+
+	if m.backend != nil {
+		if err := m.backend.SetRules(sortRules(rules)); err != nil {
+			log.Printf("Failed installing filter rules: %v", err)
+			return err
+		}
+	}
+
+	m.rules = rules
+	m.baseline = baseline
+
+	return nil
+}
+
+// Rules returns the rules with their hit counters, ordered by id
+func (m *Manager) Rules() ([]RuleStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hits := m.baseline
+	if m.backend != nil {
+		var err error
+		if hits, err = m.backend.Hits(); err != nil {
+			return nil, err
+		}
+	}
+
+	stats := []RuleStats{}
+	for _, rule := range m.list() {
+		stats = append(stats, RuleStats{Rule: rule, Hits: hits[rule.ID] - m.baseline[rule.ID]})
+	}
+	return stats, nil
+}
@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	rules []Rule
+	hits  [MaxRules]uint64
+	err   error
+}
+
+func (b *fakeBackend) SetRules(rules []Rule) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.rules = rules
+	return nil
+}
+
+func (b *fakeBackend) Hits() ([MaxRules]uint64, error) {
+	return b.hits, nil
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("deny local port 50051/tcp")
+	require.NoError(t, err)
+	require.Equal(t, Rule{Action: Deny, LocalPorts: PortRange{50051, 50051}, Proto: 6}, rule)
+
+	rule, err = ParseRule("allow remote 10.1.2.3/8")
+	require.NoError(t, err)
+	require.Equal(t, Rule{Action: Allow, RemoteNet: netip.MustParsePrefix("10.0.0.0/8")}, rule)
+	require.Nil(t, rule.Protocols())
+
+	rule, err = ParseRule("deny proto udp remote 10.0.0.0/8 local 192.168.0.0/16 remote port 53")
+	require.NoError(t, err)
+	require.Equal(t, Rule{
+		Action:      Deny,
+		LocalNet:    netip.MustParsePrefix("192.168.0.0/16"),
+		RemoteNet:   netip.MustParsePrefix("10.0.0.0/8"),
+		RemotePorts: PortRange{53, 53},
+		Proto:       17,
+	}, rule)
+	require.Equal(t, "deny local 192.168.0.0/16 remote 10.0.0.0/8 remote port 53/udp", rule.String())
+
+	for _, s := range []string{
+		"deny local port 50051/tcp",
+		"allow remote port 1000-2000",
+		"allow local 2001:db8::/32",
+		"deny proto udp",
+		"allow local 10.0.0.0/8 local port 22/tcp",
+		"deny local port 1-1023 remote port 1024-65535",
+		"allow remote 10.0.0.0/8 proto tcp",
+	} {
+		rule, err := ParseRule(s)
+		require.NoError(t, err)
+		require.Equal(t, s, rule.String())
+	}
+
+	for _, s := range []string{"", "deny", "drop local 10.0.0.0/8", "deny local port 20-10", "deny proto icmp", "allow local 10.0.0",
+		"deny local 10.0.0.0/8 local 11.0.0.0/8", "deny local port 22/tcp proto udp", "allow local port 0", "deny remote"} {
+		_, err := ParseRule(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestManagerHits(t *testing.T) {
+	backend := &fakeBackend{}
+	backend.hits[0] = 7
+
+	m := NewManager()
+	deny, _ := ParseRule("deny local port 50051/tcp")
+	allow, _ := ParseRule("allow remote 10.0.0.0/8")
+	require.NoError(t, m.Update([]Rule{deny, allow}, nil))
+
+	// Counters left by an earlier run are not reported
+	require.NoError(t, m.SetBackend(backend))
+	require.Len(t, backend.rules, 2)
+
+	backend.hits[0] += 3
+	backend.hits[1] += 5
+	stats, err := m.Rules()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), stats[0].Hits)
+	require.Equal(t, uint64(5), stats[1].Hits)
+
+	// The freed id is reused and its counter starts over
+	require.NoError(t, m.Update([]Rule{deny}, []uint32{0}))
+	stats, err = m.Rules()
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), stats[0].ID)
+	require.Equal(t, uint64(0), stats[0].Hits)
+
+	require.ErrorIs(t, m.Update(nil, []uint32{9}), ErrNotFound)
+	require.Len(t, backend.rules, 2)
+}
+
+func TestManagerFailedInstall(t *testing.T) {
+	backend := &fakeBackend{}
+	m := NewManager()
+	require.NoError(t, m.SetBackend(backend))
+
+	deny, _ := ParseRule("deny local port 50051/tcp")
+	require.NoError(t, m.Update([]Rule{deny}, nil))
+	backend.hits[0], backend.hits[1] = 4, 9
+
+	// Neither the rules nor the counters change when the probe refuses the rules
+	backend.err = ErrTooManyRules
+	allow, _ := ParseRule("allow remote 10.0.0.0/8")
+	require.ErrorIs(t, m.Update([]Rule{allow}, []uint32{0}), ErrTooManyRules)
+
+	stats, err := m.Rules()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, deny.String(), stats[0].String())
+	require.Equal(t, uint64(4), stats[0].Hits)
+
+	backend.err = nil
+	require.NoError(t, m.Update([]Rule{allow}, nil))
+	stats, err = m.Rules()
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), stats[1].Hits)
+}
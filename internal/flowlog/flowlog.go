@@ -0,0 +1,345 @@
+package flowlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/record"
+	"github.com/gabspt/ConnectionStats/internal/zeek"
+)
+
+const (
+	JSON     = "json"      // one record per line
+	CSV      = "csv"       // records with a header line
+	Zeek     = "zeek"      // Zeek conn.log, tab separated
+	ZeekJSON = "zeek-json" // Zeek conn.log, one JSON entry per line
+)
+
+const gzipSuffix = ".gz"
+
+type Config struct {
+	Path       string        // file the records are appended to
+	Format     string        // JSON, CSV, Zeek or ZeekJSON
+	MaxSize    int64         // rotate once the file reaches this many bytes, 0 disables it
+	MaxAge     time.Duration // rotate once the file has been open this long, 0 disables it
+	Compress   bool          // gzip the rotated files
+	MaxBackups int           // number of rotated files to keep, 0 keeps all of them
+
+	Locals flowtable.Locals // tells the local ends of the Zeek entries, by the flow direction when nil
+}
+
+// Writer appends flow records to Config.Path. Rotated files are renamed to
+// <path>.<timestamp>, and optionally compressed, so that the live file can be tailed
+type Writer struct {
+	cfg    Config
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	buf    bytes.Buffer
+	csv    *csv.Writer
+
+	rotatedMu sync.Mutex
+	rotated   []string      // files moved aside, waiting for compression and retention
+	wake      chan struct{} // tells the background worker about rotated files
+	done      chan struct{} // closed once the background worker returned
+}
+
+// NewWriter Constructs a new Writer, appending to Config.Path if it already exists
+func NewWriter(cfg Config) (*Writer, error) {
+	switch cfg.Format {
+	case JSON, CSV, Zeek, ZeekJSON:
+	default:
+		return nil, fmt.Errorf("unknown flow log format %q", cfg.Format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{cfg: cfg, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	w.csv = csv.NewWriter(&w.buf)
+	go w.runBackground()
+
+	// A conn.log closed by a previous run is done with, records appended after its footer
+	// would be dropped by the Zeek tools
+	if cfg.Format == Zeek && zeekClosed(cfg.Path) {
+		if err := w.moveAside(); err != nil {
+			w.stopBackground()
+			return nil, err
+		}
+	}
+
+	if err := w.open(); err != nil {
+		w.stopBackground()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.opened = time.Now()
+
+	if w.size == 0 {
+		switch w.cfg.Format {
+		case CSV:
+			w.csv.Write(record.Header())
+			w.csv.Flush()
+		case Zeek:
+			w.buf.WriteString(zeek.Header(w.opened))
+		}
+		return w.writeBuf()
+	}
+
+	return nil
+}
+
+// closeFile writes the footer of the formats having one and closes the file
+func (w *Writer) closeFile() error {
+	if w.cfg.Format == Zeek {
+		if _, err := w.file.WriteString(zeek.Footer(time.Now())); err != nil {
+			w.file.Close()
+			return err
+		}
+	}
+	return w.file.Close()
+}
+
+func (w *Writer) writeBuf() error {
+	n, err := w.file.Write(w.buf.Bytes())
+	w.size += int64(n)
+	w.buf.Reset()
+	return err
+}
+
+func (w *Writer) Name() string {
+	return "flowlog"
+}
+
+// Write appends the record of ev to the log, rotating the file first if needed. The
+// record based formats skip the flow start events, the Zeek ones only log ended flows
+func (w *Writer) Write(ev flowtable.Event) error {
+	if ev.Type == flowtable.FlowStart || (ev.Type != flowtable.FlowEnd && (w.cfg.Format == Zeek || w.cfg.Format == ZeekJSON)) {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch w.cfg.Format {
+	case JSON:
+		if err := json.NewEncoder(&w.buf).Encode(record.New(ev)); err != nil {
+			return err
+		}
+	case CSV:
+		w.csv.Write(record.New(ev).Strings())
+		w.csv.Flush()
+	case Zeek:
+		w.buf.WriteString(zeek.NewConn(ev.Conn, w.cfg.Locals).TSV())
+	case ZeekJSON:
+		if err := json.NewEncoder(&w.buf).Encode(zeek.NewConn(ev.Conn, w.cfg.Locals)); err != nil {
+			return err
+		}
+	}
+
+	if (w.cfg.MaxSize > 0 && w.size+int64(w.buf.Len()) > w.cfg.MaxSize && w.size > 0) ||
+		(w.cfg.MaxAge > 0 && time.Since(w.opened) >= w.cfg.MaxAge) {
+		pending := append([]byte(nil), w.buf.Bytes()...)
+		w.buf.Reset()
+
+		if err := w.rotate(); err != nil {
+			return err
+		}
+
+		w.buf.Write(pending)
+	}
+
+	return w.writeBuf()
+}
+
+// zeekClosed reports whether the conn.log at path ends with its #close footer
+func zeekClosed(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	footer := len(zeek.Footer(time.Time{}))
+	if info.Size() < int64(footer) {
+		return false
+	}
+	last := make([]byte, footer)
+	if _, err := file.ReadAt(last, info.Size()-int64(footer)); err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(last, []byte("#close\t"))
+}
+
+// rotate moves the current file aside and opens a new one
+func (w *Writer) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	if err := w.moveAside(); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// moveAside renames the file at Config.Path to its rotated name and queues it for the
+// background worker, so that writers are not blocked by compression and retention
+func (w *Writer) moveAside() error {
+	rotated := fmt.Sprintf("%s.%s", w.cfg.Path, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(w.cfg.Path, rotated); err != nil {
+		return err
+	}
+
+	w.rotatedMu.Lock()
+	w.rotated = append(w.rotated, rotated)
+	w.rotatedMu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// runBackground compresses the rotated files then applies the retention, one file at a
+// time, so that retention only ever sees finished files
+func (w *Writer) runBackground() {
+	defer close(w.done)
+
+	for range w.wake {
+		for {
+			w.rotatedMu.Lock()
+			if len(w.rotated) == 0 {
+				w.rotatedMu.Unlock()
+				break
+			}
+			rotated := w.rotated[0]
+			w.rotated = w.rotated[1:]
+			w.rotatedMu.Unlock()
+
+			if w.cfg.Compress {
+				if err := compress(rotated); err != nil {
+					log.Printf("Failed compressing %v: %v", rotated, err)
+				}
+			}
+			if w.cfg.MaxBackups > 0 {
+				w.removeOld()
+			}
+		}
+	}
+}
+
+// stopBackground waits for the background worker to finish the rotated files
+func (w *Writer) stopBackground() {
+	close(w.wake)
+	<-w.done
+}
+
+func compress(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(name+gzipSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// removeOld deletes the oldest rotated files beyond Config.MaxBackups
+func (w *Writer) removeOld() {
+	matches, err := filepath.Glob(w.cfg.Path + ".*")
+	if err != nil {
+		log.Printf("Failed listing rotated flow logs: %v", err)
+		return
+	}
+
+	var backups []string
+	for _, match := range matches {
+		// Skip the files waiting for compression, they are counted once they get the .gz suffix
+		if w.cfg.Compress && !strings.HasSuffix(match, gzipSuffix) {
+			continue
+		}
+		backups = append(backups, match)
+	}
+
+	// The timestamp in the name sorts the files from oldest to newest
+	sort.Strings(backups)
+
+	for len(backups) > w.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			log.Printf("Failed removing rotated flow log: %v", err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// Close closes the current file and waits for pending compressions
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.closeFile()
+	w.stopBackground()
+
+	return err
+}
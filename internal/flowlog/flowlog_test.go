@@ -0,0 +1,137 @@
+package flowlog
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/record"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.json")
+
+	w, err := NewWriter(Config{Path: path, Format: JSON})
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowStart, Conn: flowtable.Connection{Hash: 1}}))
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowActive, Conn: flowtable.Connection{Hash: 1}}))
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowEnd, Conn: flowtable.Connection{Hash: 2}}))
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var rec record.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+	require.Equal(t, uint64(2), rec.Hash)
+	require.True(t, rec.Final)
+}
+
+func TestCSVRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.csv")
+
+	w, err := NewWriter(Config{Path: path, Format: CSV, MaxSize: 1, Compress: true, MaxBackups: 2})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowEnd, Conn: flowtable.Connection{Hash: uint64(i)}}))
+	}
+	require.NoError(t, w.Close())
+
+	backups, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	f, err := os.Open(backups[1])
+	require.NoError(t, err)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(gz).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, record.Header(), rows[0])
+	require.Equal(t, "3", rows[1][0])
+
+	rows, err = readCSV(path)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, "4", rows[1][0])
+}
+
+func readCSV(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return csv.NewReader(f).ReadAll()
+}
+
+func TestZeekConnLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conn.log")
+
+	w, err := NewWriter(Config{Path: path, Format: Zeek})
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowActive, Conn: flowtable.Connection{Hash: 1, Proto: "TCP"}}))
+	require.NoError(t, w.Write(flowtable.Event{Type: flowtable.FlowEnd, Conn: flowtable.Connection{Hash: 2, Proto: "TCP", History: "ShAFf"}}))
+	require.NoError(t, w.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 10)
+	require.True(t, strings.HasPrefix(lines[6], "#fields\tts\tuid\t"))
+	require.True(t, strings.HasPrefix(lines[9], "#close\t"))
+
+	values := strings.Split(lines[8], "\t")
+	require.Len(t, values, 22)
+	require.Equal(t, "tcp", values[6])
+	require.Equal(t, "SF", values[11])
+	require.Equal(t, "ShAFf", values[15])
+}
+
+func TestZeekReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conn.log")
+	end := flowtable.Event{Type: flowtable.FlowEnd, Conn: flowtable.Connection{Hash: 1, Proto: "UDP"}}
+
+	w, err := NewWriter(Config{Path: path, Format: Zeek})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(end))
+	require.NoError(t, w.Close())
+
+	// The closed log is moved aside, and the new one gets its own header
+	w, err = NewWriter(Config{Path: path, Format: Zeek})
+	require.NoError(t, err)
+	require.NoError(t, w.Write(end))
+	require.NoError(t, w.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+
+	for _, name := range []string{path, rotated[0]} {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 10, name)
+		require.True(t, strings.HasPrefix(lines[0], "#separator"), name)
+		require.True(t, strings.HasPrefix(lines[9], "#close\t"), name)
+	}
+}
@@ -0,0 +1,126 @@
+package flowtable
+
+import (
+	"fmt"
+	"slices"
+	"unsafe"
+)
+
+// EvictionPolicy chooses the connections removed when the table is full. Closing TCP and
+// UDP connections are always evicted before the open TCP ones
+type EvictionPolicy uint8
+
+const (
+	EvictLRU    EvictionPolicy = iota // least recently seen first
+	EvictOldest                       // earliest started first
+)
+
+func ParseEvictionPolicy(s string) (EvictionPolicy, error) {
+	switch s {
+	case "lru":
+		return EvictLRU, nil
+	case "oldest":
+		return EvictOldest, nil
+	}
+	return 0, fmt.Errorf("unknown eviction policy %q, expected lru or oldest", s)
+}
+
+// entrySize estimates the memory held by a connection: the entry, its pointer and hash in
+// the map, and the strings built for it
+const entrySize = int64(unsafe.Sizeof(entry{})) + 64
+
+// evictFraction of a full shard is evicted at once, so the scan is not repeated for every
+// new connection
+const evictFraction = 16
+
+// Stats describe the occupancy of the table
+type Stats struct {
+	Flows        int
+	MaxFlows     int   // 0 when unlimited
+	MemoryBytes  int64 // estimated
+	MemoryBudget int64 // 0 when unlimited
+	Evictions    uint64
+}
+
+func (table *FlowTable) Stats() Stats {
+	flows := table.flows.Load()
+
+	return Stats{
+		Flows:        int(flows),
+		MaxFlows:     table.limit(),
+		MemoryBytes:  flows * entrySize,
+		MemoryBudget: table.MemoryBudget,
+		Evictions:    table.evictions.Load(),
+	}
+}
+
+// limit returns the number of connections the table holds, 0 when unlimited
+func (table *FlowTable) limit() int {
+	limit := table.MaxFlows
+	if table.MemoryBudget > 0 {
+		budget := int(max(table.MemoryBudget/entrySize, 1))
+		if limit == 0 || budget < limit {
+			limit = budget
+		}
+	}
+	return limit
+}
+
+// evictionClass orders the candidates, closing TCP and UDP connections go first
+func evictionClass(conn *Connection) int {
+	if conn.Proto != "TCP" || conn.Fins > 0 {
+		return 0
+	}
+	return 1
+}
+
+// makeRoom evicts connections of the shard when the table is full, returning their
+// FlowEnd events. Called with the shard locked
+func (table *FlowTable) makeRoom(s *shard) []Event {
+	limit := table.limit()
+	if limit == 0 || table.flows.Load() < int64(limit) || len(s.conns) == 0 {
+		return nil
+	}
+
+	candidates := s.candidates[:0]
+	for hash, e := range s.conns {
+		candidates = append(candidates, candidate{hash: hash, entry: e})
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if ca, cb := evictionClass(&a.entry.conn), evictionClass(&b.entry.conn); ca != cb {
+			return ca - cb
+		}
+		ta, tb := a.entry.conn.Ts_fin, b.entry.conn.Ts_fin
+		if table.Eviction == EvictOldest {
+			ta, tb = a.entry.conn.Ts_ini, b.entry.conn.Ts_ini
+		}
+		switch {
+		case ta < tb:
+			return -1
+		case ta > tb:
+			return 1
+		}
+		return 0
+	})
+
+	n := max(len(candidates)/evictFraction, 1)
+	events := make([]Event, 0, n)
+	for _, c := range candidates[:n] {
+		delete(s.conns, c.hash)
+		events = append(events, Event{Type: FlowEnd, Reason: EndEvicted, Conn: c.entry.conn})
+	}
+	table.flows.Add(-int64(n))
+	table.evictions.Add(uint64(n))
+
+	// Keep the backing array for the next scan without holding on to the entries
+	clear(candidates)
+	s.candidates = candidates[:0]
+
+	return events
+}
+
+type candidate struct {
+	hash  uint64
+	entry *entry
+}
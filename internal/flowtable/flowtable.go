@@ -4,14 +4,81 @@ import (
 	"log"
 	"net/netip"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gabspt/ConnectionStats/internal/timer"
 )
 
+// NumShards is the number of locks the table is split in. Flows are assigned to shards by
+// hash, so pipeline workers owning disjoint sets of shards never contend
+const NumShards = 256
+
 type FlowTable struct {
-	Ticker *time.Ticker
-	sync.Map
+	Ticker          *time.Ticker
+	ActiveTimeout   time.Duration
+	CommunityIDSeed uint16         // seed of the Community ID flow hashes
+	MaxFlows        int            // connections held at most, give or take one per shard, 0 is unlimited
+	MemoryBudget    int64          // estimated bytes the connections may take, 0 is unlimited
+	Eviction        EvictionPolicy // connections removed first when the table is full
+	PickupMidStream bool           // start TCP connections from any packet, not only from their SYN
+	Roles           PortRoles      // tells the server ports, to guess the initiator of connections picked up mid-stream
+	sampleRate      atomic.Uint32
+	flows           atomic.Int64
+	evictions       atomic.Uint64
+	shards          [NumShards]shard
+	eventFns        []func(Event)
+}
+
+// entry is a connection stored in a shard. Connections are updated in place, so known
+// flows do not allocate
+type entry struct {
+	conn       Connection
+	lastRecord uint64 // time of the last record emitted for the connection
+}
+
+type shard struct {
+	sync.Mutex
+	conns      map[uint64]*entry
+	scratch    entry       // holds a connection Upsert may not add
+	candidates []candidate // reused by the eviction scans
+}
+
+// ShardOf returns the shard of a flow hash
+func ShardOf(hash uint64) int {
+	return int(hash % NumShards)
+}
+
+// Verdict tells Upsert what to do with the connection it updated
+type Verdict uint8
+
+const (
+	Keep  Verdict = iota // store the connection, emitting FlowStart when it is new
+	Skip                 // leave the table unchanged, a new connection is not added
+	Close                // remove the connection, emitting FlowEnd with EndClosed
+)
+
+type EventType uint8
+
+const (
+	FlowStart  EventType = iota // a new connection was added to the table
+	FlowActive                  // interim record of a connection active for longer than ActiveTimeout
+	FlowEnd                     // the connection was removed from the table
+)
+
+// Reasons for a FlowEnd event
+const (
+	EndClosed   = "closed"   // TCP connection finished
+	EndIdle     = "idle"     // no packets seen for 60 seconds
+	EndShutdown = "shutdown" // the agent stopped while the connection was active
+	EndEvicted  = "evicted"  // removed to make room in a full table
+)
+
+// Event is a flow lifecycle notification, carrying a copy of the connection at that moment
+type Event struct {
+	Type   EventType
+	Reason string
+	Conn   Connection
 }
 
 type Connection struct {
@@ -27,11 +94,121 @@ type Connection struct {
 	Ts_fin      uint64
 	Bytes_in    uint64
 	Bytes_out   uint64
+	Outbound    bool     // the first packet, sent by A, left through the interface
+	History     string   // TCP flags seen, in Zeek notation: upper case when sent by A, lower case when sent by B
+	CommunityID string   // Community ID v1 flow hash
+	SampleRate  uint32   // counters were taken from 1 in SampleRate packets when the flow started
+	Estimated   bool     // counters were scaled by the sampling rates
+	Resampled   bool     // the sampling rate changed during the flow, Weighted holds its estimates
+	Weighted    Counters // counters with each packet weighted by the rate it was sampled at, once Resampled
+	Fins        uint8    // FIN packets seen, the ACK following the second one closes the connection
+	Ifindex     uint32   // interface the connection was seen on, 0 when unknown
+	Netns       uint64   // cookie of the network namespace of the interface, 0 when unknown
+	MidStream   bool     // picked up after its handshake: the counters miss the packets before, and A, the initiator, is a guess
+}
+
+// Counters are the packet and byte counters of both directions of a connection
+type Counters struct {
+	Packets_in  uint64
+	Packets_out uint64
+	Bytes_in    uint64
+	Bytes_out   uint64
+}
+
+// PortRoles tells server ports from client ones
+type PortRoles interface {
+	Listening(port uint16) bool // a local socket listens on the TCP port
+	Ephemeral(port uint16) bool // the port is in the range the local clients are bound to
 }
 
 // NewFlowTable Constructs a new FlowTable
 func NewFlowTable() *FlowTable {
-	return &FlowTable{Ticker: time.NewTicker(time.Second * 10)}
+	table := &FlowTable{Ticker: time.NewTicker(time.Second * 10)}
+	table.sampleRate.Store(1)
+	for i := range table.shards {
+		table.shards[i].conns = make(map[uint64]*entry)
+	}
+	return table
+}
+
+// SampleRate returns the rate the probe samples packets at, 1 when not sampling
+func (table *FlowTable) SampleRate() uint32 {
+	return table.sampleRate.Load()
+}
+
+// SetSampleRate sets the sampling rate recorded in the new connections
+func (table *FlowTable) SetSampleRate(rate uint32) {
+	if rate == 0 {
+		rate = 1
+	}
+	table.sampleRate.Store(rate)
+}
+
+// OnEvent registers fn to receive the flow lifecycle events. It must be called before the probe starts
+func (table *FlowTable) OnEvent(fn func(Event)) {
+	table.eventFns = append(table.eventFns, fn)
+}
+
+// Emit notifies the registered functions of a flow lifecycle event
+func (table *FlowTable) Emit(ev Event) {
+	for _, fn := range table.eventFns {
+		fn(ev)
+	}
+}
+
+// Count adds packets and bytes sampled at rate to the counters of a direction. Once the rate
+// differs from the one the flow started with, the flow is Resampled and its estimates weigh
+// each packet by the rate in effect when it was counted
+func (c *Connection) Count(outbound bool, packets, bytes uint64, rate uint32) {
+	rate = max(rate, 1)
+	if !c.Resampled && rate != max(c.SampleRate, 1) {
+		n := uint64(max(c.SampleRate, 1))
+		c.Resampled = true
+		c.Weighted = Counters{c.Packets_in * n, c.Packets_out * n, c.Bytes_in * n, c.Bytes_out * n}
+	}
+
+	n := uint64(rate)
+	if outbound {
+		c.Packets_out += packets
+		c.Bytes_out += bytes
+		if c.Resampled {
+			c.Weighted.Packets_out += packets * n
+			c.Weighted.Bytes_out += bytes * n
+		}
+	} else {
+		c.Packets_in += packets
+		c.Bytes_in += bytes
+		if c.Resampled {
+			c.Weighted.Packets_in += packets * n
+			c.Weighted.Bytes_in += bytes * n
+		}
+	}
+}
+
+// Extrapolated returns the connection with its packet and byte counters scaled by the
+// sampling rates, estimating the totals of the flow
+func (c Connection) Extrapolated() Connection {
+	if c.Estimated {
+		return c
+	}
+	if c.Resampled {
+		c.Packets_in, c.Packets_out = c.Weighted.Packets_in, c.Weighted.Packets_out
+		c.Bytes_in, c.Bytes_out = c.Weighted.Bytes_in, c.Weighted.Bytes_out
+		c.Estimated = true
+		return c
+	}
+	if c.SampleRate <= 1 {
+		return c
+	}
+
+	n := uint64(c.SampleRate)
+	c.Packets_in *= n
+	c.Packets_out *= n
+	c.Bytes_in *= n
+	c.Bytes_out *= n
+	c.Estimated = true
+
+	return c
 }
 
 // NewConnection Constructs a new Connection
@@ -39,74 +216,185 @@ func NewConnection() Connection {
 	return Connection{}
 }
 
+// Upsert calls update with the connection of hash under the lock of its shard, and applies
+// the verdict it returns. A connection not in the table is passed zero valued, with found false
+func (table *FlowTable) Upsert(hash uint64, update func(conn *Connection, found bool) Verdict) {
+	s := &table.shards[ShardOf(hash)]
+	s.Lock()
+
+	e, found := s.conns[hash]
+	if !found {
+		s.scratch = entry{}
+		e = &s.scratch
+	}
+
+	var ev Event
+	var evicted []Event
+	emit := true
+
+	switch verdict := update(&e.conn, found); {
+	case verdict == Keep && !found:
+		evicted = table.makeRoom(s)
+		s.conns[hash] = &entry{conn: e.conn}
+		table.flows.Add(1)
+		ev = Event{Type: FlowStart, Conn: e.conn}
+	case verdict == Close && found:
+		delete(s.conns, hash)
+		table.flows.Add(-1)
+		ev = Event{Type: FlowEnd, Reason: EndClosed, Conn: e.conn}
+	default:
+		emit = false
+	}
+
+	s.Unlock()
+
+	for _, ev := range evicted {
+		table.Emit(ev)
+	}
+	if emit {
+		table.Emit(ev)
+	}
+}
+
 // add adds packet hash as a new connection and its connection attributes to the FlowTable
 func (table *FlowTable) Insert(hash uint64, conn Connection) {
-	table.Store(hash, conn)
+	s := &table.shards[ShardOf(hash)]
+	s.Lock()
+
+	var evicted []Event
+	if e, ok := s.conns[hash]; ok {
+		e.conn = conn
+	} else {
+		evicted = table.makeRoom(s)
+		s.conns[hash] = &entry{conn: conn}
+		table.flows.Add(1)
+	}
+
+	s.Unlock()
+
+	for _, ev := range evicted {
+		table.Emit(ev)
+	}
 }
 
 // load loads packet or connection hash and its attributes from the FlowTable
 func (table *FlowTable) Get(hash uint64) (Connection, bool) {
-	value, ok := table.Load(hash)
+	s := &table.shards[ShardOf(hash)]
+	s.Lock()
+	defer s.Unlock()
 
+	e, ok := s.conns[hash]
 	if !ok { //if nothing was found return 0,false
 		return Connection{}, ok
 	}
-	return value.(Connection), true
+	return e.conn, true
 }
 
 // delete deletes connection hash and its data from the FlowTable
 func (table *FlowTable) Remove(hash uint64) {
-	_, found := table.Load(hash)
+	s := &table.shards[ShardOf(hash)]
+	s.Lock()
+	e, found := s.conns[hash]
+	if found {
+		delete(s.conns, hash)
+		table.flows.Add(-1)
+	}
+	s.Unlock()
 
 	if found {
 		// log.Printf("Removing hash %v from flow table", hash)
-		table.Delete(hash)
+		table.Emit(Event{Type: FlowEnd, Reason: EndClosed, Conn: e.conn})
 	} else {
 		log.Printf("hash %v is not in flow table", hash)
 	}
 }
 
-// Prune clears the stale entries (older than 60 seconds) from the FlowTable
+// Prune clears the stale entries (older than 60 seconds) from the FlowTable and emits
+// interim records for the connections that have been active for longer than ActiveTimeout
 func (table *FlowTable) Prune() {
-	now := timer.GetNanosecSinceBoot()
+	now := timer.Now()
+	pruned := false
+	events := []Event{}
 
-	table.Range(func(hash, value interface{}) bool {
-		connection, ok := value.(Connection)
-		if !ok {
-			// Not a Connection instance
-			return false
+	for i := range table.shards {
+		s := &table.shards[i]
+		s.Lock()
+		for hash, e := range s.conns {
+			// Packets stamped after now was read are not stale, and would underflow now-lastts
+			lastts := e.conn.Ts_fin
+			if lastts < now && (now-lastts)/1000000 > 60000 {
+				delete(s.conns, hash)
+				table.flows.Add(-1)
+				events = append(events, Event{Type: FlowEnd, Reason: EndIdle, Conn: e.conn})
+				pruned = true
+				continue
+			}
+			if table.ActiveTimeout > 0 {
+				last := e.conn.Ts_ini
+				if e.lastRecord != 0 {
+					last = e.lastRecord
+				}
+				if last < now && now-last >= uint64(table.ActiveTimeout.Nanoseconds()) {
+					e.lastRecord = now
+					events = append(events, Event{Type: FlowActive, Conn: e.conn})
+				}
+			}
 		}
-		lastts := connection.Ts_fin
-		if (now-lastts)/1000000 > 60000 {
-			log.Printf("Pruning stale entry from flow table: %v after %vms", hash, (now-lastts)/1000000)
+		s.Unlock()
 
-			table.Delete(hash)
-			table.CountActiveConns()
+		for _, ev := range events {
+			table.Emit(ev)
+		}
+		events = events[:0]
+	}
 
-			return true
+	if pruned {
+		table.CountActiveConns()
+	}
+}
+
+// Flush removes every connection from the FlowTable, emitting their FlowEnd events with reason
+func (table *FlowTable) Flush(reason string) {
+	for i := range table.shards {
+		s := &table.shards[i]
+		s.Lock()
+		conns := s.conns
+		s.conns = make(map[uint64]*entry)
+		table.flows.Add(-int64(len(conns)))
+		s.Unlock()
+
+		for _, e := range conns {
+			table.Emit(Event{Type: FlowEnd, Reason: reason, Conn: e.conn})
 		}
-		return false
-	})
+	}
 }
 
-func (table *FlowTable) CountActiveConns() {
+// Len returns the number of connections in the FlowTable
+func (table *FlowTable) Len() int {
 	counter := 0
-	table.Range(func(hash, value interface{}) bool {
-		counter++
-		return true
-	})
-	log.Printf("There are %v active connections", counter)
+	for i := range table.shards {
+		s := &table.shards[i]
+		s.Lock()
+		counter += len(s.conns)
+		s.Unlock()
+	}
+	return counter
+}
+
+func (table *FlowTable) CountActiveConns() {
+	log.Printf("There are %v active connections", table.Len())
 }
 
 func (table *FlowTable) GetConnList() []Connection {
 	var connlist []Connection
-	table.Range(func(hash, value interface{}) bool {
-		connection, ok := value.(Connection)
-		if ok {
-			connlist = append(connlist, connection)
+	for i := range table.shards {
+		s := &table.shards[i]
+		s.Lock()
+		for _, e := range s.conns {
+			connlist = append(connlist, e.conn)
 		}
-		return true
-	})
+		s.Unlock()
+	}
 	return connlist
 }
 
@@ -0,0 +1,99 @@
+package flowtable
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/timer"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fill inserts n connections with hashes in the same shard, started and last seen at i
+func fill(table *FlowTable, n int, conn func(i int) Connection) {
+	for i := 0; i < n; i++ {
+		table.Insert(uint64(i*NumShards), conn(i))
+	}
+}
+
+func TestEvictionPrefersClosedFlows(t *testing.T) {
+	table := NewFlowTable()
+	table.MaxFlows = 4
+
+	var evicted []Event
+	table.OnEvent(func(ev Event) { evicted = append(evicted, ev) })
+
+	fill(table, 4, func(i int) Connection {
+		conn := Connection{Hash: uint64(i * NumShards), Proto: "TCP", Ts_ini: uint64(i), Ts_fin: uint64(i)}
+		if i == 3 {
+			conn.Fins = 1
+		}
+		return conn
+	})
+	require.Empty(t, evicted)
+
+	// The closing connection goes first even though it is the most recent
+	table.Insert(100*NumShards, Connection{Proto: "TCP", Ts_ini: 100, Ts_fin: 100})
+	require.Len(t, evicted, 1)
+	require.Equal(t, EndEvicted, evicted[0].Reason)
+	require.Equal(t, uint64(3*NumShards), evicted[0].Conn.Hash)
+
+	// Then the least recently seen
+	table.Insert(101*NumShards, Connection{Proto: "TCP", Ts_ini: 101, Ts_fin: 101})
+	require.Len(t, evicted, 2)
+	require.Equal(t, uint64(0), evicted[1].Conn.Hash)
+
+	stats := table.Stats()
+	require.Equal(t, 4, stats.Flows)
+	require.Equal(t, uint64(2), stats.Evictions)
+}
+
+func TestEvictionOldestAndMemoryBudget(t *testing.T) {
+	table := NewFlowTable()
+	table.MemoryBudget = 3 * entrySize
+	table.Eviction = EvictOldest
+
+	var evicted []Event
+	table.OnEvent(func(ev Event) { evicted = append(evicted, ev) })
+
+	// Connection 1 started first but was seen last
+	fill(table, 3, func(i int) Connection {
+		conn := Connection{Hash: uint64(i * NumShards), Proto: "TCP", Ts_ini: uint64(1 + i), Ts_fin: uint64(10 + i)}
+		if i == 1 {
+			conn.Ts_ini, conn.Ts_fin = 0, 100
+		}
+		return conn
+	})
+	require.Equal(t, 3, table.Stats().MaxFlows)
+
+	table.Insert(5*NumShards, Connection{Proto: "TCP", Ts_ini: 50, Ts_fin: 50})
+	require.Len(t, evicted, 1)
+	require.Equal(t, uint64(NumShards), evicted[0].Conn.Hash)
+	require.Equal(t, 3*entrySize, table.Stats().MemoryBytes)
+
+	table.Flush(EndShutdown)
+	require.Zero(t, table.Stats().Flows)
+}
+
+func TestPruneFlowsFromTheFuture(t *testing.T) {
+	clock := &timer.Manual{}
+	timer.SetClock(clock)
+	defer timer.SetClock(timer.Monotonic{})
+
+	table := NewFlowTable()
+	table.ActiveTimeout = time.Minute
+	var events []Event
+	table.OnEvent(func(ev Event) { events = append(events, ev) })
+
+	// A packet stamped after the pruner read the clock is neither stale nor due a record
+	now := uint64(time.Hour)
+	clock.Set(now)
+	table.Insert(1, Connection{Hash: 1, Proto: "UDP", Ts_ini: now + 1, Ts_fin: now + 1})
+	table.Insert(2, Connection{Hash: 2, Proto: "UDP", Ts_ini: 0, Ts_fin: 0})
+
+	table.Prune()
+	require.Len(t, table.GetConnList(), 1)
+	require.Len(t, events, 1)
+	require.Equal(t, EndIdle, events[0].Reason)
+	require.Equal(t, uint64(2), events[0].Conn.Hash)
+}
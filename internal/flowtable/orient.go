@@ -0,0 +1,60 @@
+package flowtable
+
+import "net/netip"
+
+// Endpoint is one end of a connection
+type Endpoint struct {
+	IP   netip.Addr
+	Port uint16
+}
+
+// Locals tells the addresses of the network namespaces the connections are seen in
+type Locals interface {
+	// IsLocal reports whether addr is an address of the namespace with the netns cookie,
+	// ok false when the addresses of the namespace are unknown
+	IsLocal(netns uint64, addr netip.Addr) (local, ok bool)
+}
+
+// ALocal reports whether A is the local end of the connection: the one with an address of
+// its namespace, or the one sending the outbound packets when the addresses do not tell,
+// as for forwarded traffic, unknown namespaces or locals nil
+func (c Connection) ALocal(locals Locals) bool {
+	if locals != nil {
+		aLocal, ok := locals.IsLocal(c.Netns, c.AIp)
+		bLocal, _ := locals.IsLocal(c.Netns, c.BIp)
+		if ok && aLocal != bLocal {
+			return aLocal
+		}
+	}
+	return c.Outbound
+}
+
+// Endpoints returns the local and remote ends of the connection
+func (c Connection) Endpoints(locals Locals) (local, remote Endpoint) {
+	a, b := Endpoint{c.AIp, c.APort}, Endpoint{c.BIp, c.BPort}
+	if c.ALocal(locals) {
+		return a, b
+	}
+	return b, a
+}
+
+// ServerPort returns the port of the server, B, as A initiated the connection
+func (c Connection) ServerPort() uint16 {
+	return c.BPort
+}
+
+// ToServer returns the counters of the packets sent by the client, A
+func (c Connection) ToServer() (packets, bytes uint64) {
+	if c.Outbound {
+		return c.Packets_out, c.Bytes_out
+	}
+	return c.Packets_in, c.Bytes_in
+}
+
+// ToClient returns the counters of the packets sent by the server, B
+func (c Connection) ToClient() (packets, bytes uint64) {
+	if c.Outbound {
+		return c.Packets_in, c.Bytes_in
+	}
+	return c.Packets_out, c.Bytes_out
+}
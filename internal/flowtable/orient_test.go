@@ -0,0 +1,45 @@
+package flowtable
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testLocals has 192.168.0.156 in namespace 2, and does not know the others
+type testLocals struct{}
+
+func (testLocals) IsLocal(netns uint64, addr netip.Addr) (bool, bool) {
+	return addr == netip.MustParseAddr("192.168.0.156"), netns == 2
+}
+
+func TestOrientation(t *testing.T) {
+	// A remote client connected to the local server, first seen inbound
+	conn := Connection{
+		AIp: netip.MustParseAddr("1.1.1.1"), APort: 53264,
+		BIp: netip.MustParseAddr("192.168.0.156"), BPort: 443,
+		Netns:      2,
+		Packets_in: 3, Bytes_in: 300,
+		Packets_out: 2, Bytes_out: 2000,
+	}
+
+	local, remote := conn.Endpoints(testLocals{})
+	require.Equal(t, Endpoint{conn.BIp, 443}, local)
+	require.Equal(t, Endpoint{conn.AIp, 53264}, remote)
+	require.False(t, conn.ALocal(testLocals{}))
+	require.Equal(t, uint16(443), conn.ServerPort())
+
+	packets, bytes := conn.ToServer()
+	require.Equal(t, [2]uint64{3, 300}, [2]uint64{packets, bytes})
+	packets, bytes = conn.ToClient()
+	require.Equal(t, [2]uint64{2, 2000}, [2]uint64{packets, bytes})
+
+	// Without the addresses, or when they do not tell, the direction does
+	conn.Outbound = true
+	require.True(t, conn.ALocal(nil))
+	conn.Netns = 3
+	require.True(t, conn.ALocal(testLocals{}))
+	packets, _ = conn.ToServer()
+	require.Equal(t, uint64(2), packets)
+}
@@ -0,0 +1,159 @@
+package flowtable
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshotVersion changes when Connection does incompatibly, older snapshots are not
+// restored. Added fields are zero in the connections of older snapshots
+const snapshotVersion = 1
+
+// ErrStaleSnapshot is returned for a snapshot taken in another boot, whose timestamps are
+// not comparable to the current clock
+var ErrStaleSnapshot = errors.New("snapshot taken before the last reboot")
+
+// bootID identifies the boot the monotonic timestamps of the connections are relative to
+var bootID = func() string {
+	id, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(id))
+}
+
+type snapshotHeader struct {
+	Version int
+	BootID  string
+	Saved   time.Time
+	Flows   int
+}
+
+type snapshotEntry struct {
+	Conn       Connection
+	LastRecord uint64
+}
+
+// WriteSnapshot writes the connections of the table to w. Each shard is copied under its
+// lock, so the table keeps being updated meanwhile
+func (table *FlowTable) WriteSnapshot(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+
+	header := snapshotHeader{Version: snapshotVersion, BootID: bootID(), Saved: time.Now(), Flows: int(table.flows.Load())}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	var entries []snapshotEntry
+	for i := range table.shards {
+		s := &table.shards[i]
+		s.Lock()
+		entries = entries[:0]
+		for _, e := range s.conns {
+			entries = append(entries, snapshotEntry{Conn: e.conn, LastRecord: e.lastRecord})
+		}
+		s.Unlock()
+
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	// A zero entry ends the snapshot, telling a complete one from a truncated one
+	return enc.Encode(snapshotEntry{})
+}
+
+// ReadSnapshot adds the connections of a snapshot to the table, without emitting events as
+// they already started, and returns how many it restored. The connections already in the
+// table are kept, and restoring stops once the table is full
+func (table *FlowTable) ReadSnapshot(r io.Reader) (int, error) {
+	dec := gob.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("reading snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return 0, fmt.Errorf("snapshot version %d, expected %d", header.Version, snapshotVersion)
+	}
+	if header.BootID != bootID() {
+		return 0, ErrStaleSnapshot
+	}
+
+	restored := 0
+	limit := table.limit()
+
+	for {
+		var e snapshotEntry
+		if err := dec.Decode(&e); err != nil {
+			return restored, fmt.Errorf("reading snapshot: %w", err)
+		}
+		if e.Conn.Hash == 0 && e.Conn.Proto == "" {
+			return restored, nil
+		}
+		if limit > 0 && int(table.flows.Load()) >= limit {
+			continue
+		}
+
+		s := &table.shards[ShardOf(e.Conn.Hash)]
+		s.Lock()
+		if _, ok := s.conns[e.Conn.Hash]; !ok {
+			s.conns[e.Conn.Hash] = &entry{conn: e.Conn, lastRecord: e.LastRecord}
+			table.flows.Add(1)
+			restored++
+		}
+		s.Unlock()
+	}
+}
+
+// SaveSnapshot writes the snapshot of the table to path. It is written aside and renamed, so
+// path always holds a complete snapshot
+func (table *FlowTable) SaveSnapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := table.WriteSnapshot(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot restores the snapshot saved at path, none being there is not an error
+func (table *FlowTable) LoadSnapshot(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return table.ReadSnapshot(bufio.NewReader(f))
+}
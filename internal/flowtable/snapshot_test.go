@@ -0,0 +1,67 @@
+package flowtable
+
+import (
+	"bytes"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	table := NewFlowTable()
+	fill(table, 3, func(i int) Connection {
+		return Connection{
+			Hash: uint64(i * NumShards), Proto: "TCP",
+			AIp: netip.MustParseAddr("10.0.0.1"), BIp: netip.MustParseAddr("2001:db8::1"),
+			APort: 40000, BPort: 443, Packets_in: uint64(i), Ts_ini: uint64(i), History: "ShAD",
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "flows.snapshot")
+	require.NoError(t, table.SaveSnapshot(path))
+
+	restored := NewFlowTable()
+	var events []Event
+	restored.OnEvent(func(ev Event) { events = append(events, ev) })
+
+	n, err := restored.LoadSnapshot(path)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 3, restored.Stats().Flows)
+	require.Empty(t, events)
+
+	conn, ok := restored.Get(2 * NumShards)
+	require.True(t, ok)
+	want, _ := table.Get(2 * NumShards)
+	require.Equal(t, want, conn)
+
+	// Restoring again does not duplicate the connections
+	n, err = restored.LoadSnapshot(path)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = NewFlowTable().LoadSnapshot(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestSnapshotRejected(t *testing.T) {
+	table := NewFlowTable()
+	fill(table, 2, func(i int) Connection { return Connection{Hash: uint64(i * NumShards), Proto: "UDP"} })
+
+	var buf bytes.Buffer
+	require.NoError(t, table.WriteSnapshot(&buf))
+
+	// Truncated
+	_, err := NewFlowTable().ReadSnapshot(bytes.NewReader(buf.Bytes()[:buf.Len()-8]))
+	require.Error(t, err)
+
+	// Taken in another boot
+	orig := bootID
+	defer func() { bootID = orig }()
+	bootID = func() string { return "other" }
+	_, err = NewFlowTable().ReadSnapshot(bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(t, err, ErrStaleSnapshot)
+}
@@ -0,0 +1,82 @@
+package packet
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// InterfaceAddrs knows the addresses of the interfaces of the agent's network namespace
+type InterfaceAddrs struct {
+	netns uint64 // cookie of the agent's namespace, 0 when unknown
+	mu    sync.RWMutex
+	addrs map[netip.Addr]struct{}
+}
+
+// NewInterfaceAddrs returns the addresses of the interfaces of the agent's namespace, with the
+// netns cookie, refreshed by Run
+func NewInterfaceAddrs(netns uint64) *InterfaceAddrs {
+	a := &InterfaceAddrs{netns: netns}
+	if err := a.Refresh(); err != nil {
+		log.Printf("Failed reading the interface addresses: %v", err)
+	}
+	return a
+}
+
+// IsLocal reports whether addr is an address of any interface of the namespace, as the kernel
+// accepts the packets to its addresses on every interface. The addresses of the other
+// namespaces are unknown, a cookie of 0 being the agent's namespace
+func (a *InterfaceAddrs) IsLocal(netns uint64, addr netip.Addr) (local, ok bool) {
+	if netns != 0 && netns != a.netns {
+		return false, false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	_, local = a.addrs[addr.Unmap()]
+	return local, true
+}
+
+// Refresh reads the addresses of the interfaces again
+func (a *InterfaceAddrs) Refresh() error {
+	ifaddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+
+	addrs := make(map[netip.Addr]struct{}, len(ifaddrs))
+	for _, ifaddr := range ifaddrs {
+		prefix, err := netip.ParsePrefix(ifaddr.String())
+		if err != nil {
+			continue
+		}
+		addrs[prefix.Addr().Unmap()] = struct{}{}
+	}
+
+	a.mu.Lock()
+	a.addrs = addrs
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Run refreshes the addresses every interval until ctx is done
+func (a *InterfaceAddrs) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Refresh(); err != nil {
+				log.Printf("Failed reading the interface addresses: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package packet
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfaceAddrs(t *testing.T) {
+	addrs := NewInterfaceAddrs(1)
+	loopback := netip.MustParseAddr("127.0.0.1")
+
+	for _, test := range []struct {
+		netns     uint64
+		addr      netip.Addr
+		local, ok bool
+	}{
+		{1, loopback, true, true},
+		{0, loopback, true, true},
+		{0, netip.MustParseAddr("::ffff:127.0.0.1"), true, true},
+		{0, netip.MustParseAddr("192.0.2.1"), false, true},
+		// The addresses of the other namespaces are unknown
+		{2, loopback, false, false},
+	} {
+		local, ok := addrs.IsLocal(test.netns, test.addr)
+		require.Equal(t, test.local, local, "%v in %v", test.addr, test.netns)
+		require.Equal(t, test.ok, ok, "%v in %v", test.addr, test.netns)
+	}
+}
@@ -2,13 +2,13 @@ package packet
 
 import (
 	"encoding/binary"
-	"fmt"
-	"hash/fnv"
 	"log"
 	"net/netip"
+	"strings"
 
 	//"sync"
 
+	"github.com/gabspt/ConnectionStats/internal/communityid"
 	"github.com/gabspt/ConnectionStats/internal/flowtable"
 )
 
@@ -42,34 +42,70 @@ type Packet struct {
 	TimeStamp uint64
 	Outbound  bool
 	Len       uint32
+	Ifindex   uint32 // interface the packet was seen on, 0 when unknown
+	Netns     uint64 // cookie of the network namespace of the interface, 0 when unknown
 }
 
-func hash(value []byte) uint64 {
-	hash := fnv.New64a()
-	hash.Write(value)
-	return hash.Sum64()
-}
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
 
-func (pkt *Packet) Hash() uint64 {
-	tmp := make([]byte, 2)
+// fnvEndpoint returns the FNV-1a hash of the address bytes followed by the port, without
+// allocating. IPv4 addresses are hashed in their 4 byte form, like netip.Addr.AsSlice
+func fnvEndpoint(addr netip.Addr, port uint16) uint64 {
+	h := uint64(fnvOffset)
+
+	if addr.Is4() {
+		for _, b := range addr.As4() {
+			h = (h ^ uint64(b)) * fnvPrime
+		}
+	} else if addr.IsValid() {
+		for _, b := range addr.As16() {
+			h = (h ^ uint64(b)) * fnvPrime
+		}
+	}
 
-	var src []byte
-	var dst []byte
-	var proto []byte
+	h = (h ^ uint64(port>>8)) * fnvPrime
+	h = (h ^ uint64(port&0xff)) * fnvPrime
 
-	binary.BigEndian.PutUint16(tmp, pkt.SrcPort)
-	src = append(pkt.SrcIP.AsSlice(), tmp...)
+	return h
+}
+
+// Hash returns the flow key of the packet, the same for both directions of a connection.
+// The same connection seen on two interfaces, or namespaces, makes two flows
+func (pkt *Packet) Hash() uint64 {
+	proto := uint64(fnvOffset)
+	proto = (proto ^ 0) * fnvPrime
+	proto = (proto ^ uint64(pkt.Protocol)) * fnvPrime
 
-	binary.BigEndian.PutUint16(tmp, pkt.DstPort)
-	dst = append(pkt.DstIP.AsSlice(), tmp...)
+	if pkt.Ifindex != 0 {
+		for shift := 24; shift >= 0; shift -= 8 {
+			proto = (proto ^ uint64(pkt.Ifindex>>shift&0xff)) * fnvPrime
+		}
+	}
+	if pkt.Netns != 0 {
+		for shift := 56; shift >= 0; shift -= 8 {
+			proto = (proto ^ pkt.Netns>>shift&0xff) * fnvPrime
+		}
+	}
 
-	binary.BigEndian.PutUint16(tmp, uint16(pkt.Protocol))
-	proto = append(proto, tmp...)
+	return fnvEndpoint(pkt.SrcIP, pkt.SrcPort) + fnvEndpoint(pkt.DstIP, pkt.DstPort) + proto
+}
 
-	return hash(src) + hash(dst) + hash(proto)
+// CommunityID returns the Community ID v1 flow hash of the packet 5-tuple
+func (pkt *Packet) CommunityID(seed uint16) string {
+	return communityid.Compute(seed, pkt.SrcIP, pkt.DstIP, pkt.SrcPort, pkt.DstPort, pkt.Protocol)
 }
 
+// EventSize is the size of a packet event, struct packet_t of connstats.c
+const EventSize = 72
+
 func UnmarshalBinary(in []byte) (Packet, bool) {
+	if len(in) < EventSize {
+		return Packet{}, false
+	}
+
 	srcIP, ok := netip.AddrFromSlice(in[0:16])
 
 	if !ok {
@@ -94,6 +130,8 @@ func UnmarshalBinary(in []byte) (Packet, bool) {
 		TimeStamp: binary.LittleEndian.Uint64(in[40:48]),
 		Outbound:  in[48] == 1, //If in[49] == 1 then Outbound=true, if in[38] == 0 then Outbound=false
 		Len:       binary.BigEndian.Uint32(in[49:53]),
+		Ifindex:   binary.NativeEndian.Uint32(in[56:60]),
+		Netns:     binary.NativeEndian.Uint64(in[64:72]),
 	}, true
 }
 
@@ -102,15 +140,66 @@ var ipProtoNums = map[uint8]string{
 	17: "UDP",
 }
 
-var contFin int = 0
-var wait4ACK bool = false
+// ProtoName returns the name the flow table records the IP protocol with, false for the
+// protocols it does not track
+func ProtoName(num uint8) (string, bool) {
+	name, ok := ipProtoNums[num]
+	return name, ok
+}
+
+// updateHistory appends the TCP flags of pkt to the connection history. Like Zeek, each
+// letter is only recorded the first time it is seen in each direction
+func updateHistory(conn *flowtable.Connection, pkt Packet) {
+	if pkt.Protocol != 6 {
+		return
+	}
 
-func CalcStats(pkt Packet, table *flowtable.FlowTable) {
+	var letter byte
+	switch {
+	case pkt.Syn && pkt.Ack:
+		letter = 'H'
+	case pkt.Syn:
+		letter = 'S'
+	case pkt.Fin:
+		letter = 'F'
+	case pkt.Ack:
+		letter = 'A'
+	default:
+		return
+	}
+
+	// Packets sent by B travel in the opposite direction of the first one
+	if pkt.Outbound != conn.Outbound {
+		letter += 'a' - 'A'
+	}
 
-	convertIPToString := func(address netip.Addr) string {
-		return address.Unmap().String()
+	if !strings.ContainsRune(conn.History, rune(letter)) {
+		conn.History += string(letter)
+	}
+}
+
+// pickUp marks a TCP connection started after its SYN, and orients it from the initiator
+// guessed. A flipped connection starts its history with ^, as Zeek does
+func pickUp(conn *flowtable.Connection, pkt Packet, roles flowtable.PortRoles) {
+	conn.MidStream = true
+	if srcIsClient(pkt, roles) {
+		return
 	}
 
+	// The initiator is A, so this packet was sent by B
+	conn.AIp, conn.BIp = conn.BIp, conn.AIp
+	conn.APort, conn.BPort = conn.BPort, conn.APort
+	conn.Outbound = !pkt.Outbound
+	conn.History = "^"
+}
+
+func CalcStats(pkt Packet, table *flowtable.FlowTable) {
+	UpdateStats(pkt, pkt.Hash(), table)
+}
+
+// UpdateStats is CalcStats for a packet whose hash is already known
+func UpdateStats(pkt Packet, pktHash uint64, table *flowtable.FlowTable) {
+
 	proto, ok := ipProtoNums[pkt.Protocol]
 
 	if !ok {
@@ -118,127 +207,100 @@ func CalcStats(pkt Packet, table *flowtable.FlowTable) {
 		return
 	}
 
-	//Calculate packet hash
-	pktHash := pkt.Hash()
-
-	//Search if this Hash already exists in the table, if nothing was found return 0,false, else: ts,true
-	//ftMutex.RLock()
-	conn, ok := table.Get(pktHash)
-	//ftMutex.RUnlock()
-
-	if !ok { //&& ((pkt.Syn) || (proto == udp)) { //new connection and it is a syn tcp or a new udp conn
-		//ask if the pkt is inbound or outbound and update the corresponding counters
-		if pkt.Syn || (proto == udp) {
-			if pkt.Outbound {
-				conn.Packets_out++
-				conn.Bytes_out = conn.Bytes_out + uint64(pkt.Len)
-				conn.Ts_ini = pkt.TimeStamp
-			} else {
-				conn.Packets_in++
-				conn.Bytes_in = conn.Bytes_in + uint64(pkt.Len)
-				conn.Ts_ini = pkt.TimeStamp
+	table.Upsert(pktHash, func(conn *flowtable.Connection, found bool) flowtable.Verdict {
+		if !found { //new connection, it is a syn tcp or a new udp conn
+			// A sampled connection may have lost its SYN, so any packet starts it
+			if !pkt.Syn && proto != udp && table.SampleRate() <= 1 && !table.PickupMidStream {
+				return flowtable.Skip
 			}
+
+			conn.SampleRate = table.SampleRate()
+			//ask if the pkt is inbound or outbound and update the corresponding counters
+			conn.Count(pkt.Outbound, 1, uint64(pkt.Len), conn.SampleRate)
+			conn.Ts_ini = pkt.TimeStamp
+			conn.Ts_fin = pkt.TimeStamp
+			conn.Outbound = pkt.Outbound
 			conn.AIp = pkt.SrcIP
 			conn.APort = pkt.SrcPort
 			conn.BIp = pkt.DstIP
 			conn.BPort = pkt.DstPort
 			conn.Hash = pktHash
+			conn.Ifindex = pkt.Ifindex
+			conn.Netns = pkt.Netns
+			conn.CommunityID = pkt.CommunityID(table.CommunityIDSeed)
 			conn.Proto = proto
+			if proto == tcp && !(pkt.Syn && !pkt.Ack) && table.PickupMidStream {
+				pickUp(conn, pkt, table.Roles)
+			}
+			updateHistory(conn, pkt)
 
 			//add new connection to the table
-			//ftMutex.Lock()
-			table.Insert(pktHash, conn)
-			//ftMutex.Unlock()
-
-			fmt.Printf("GOT A NEW CONNECTION\n")
-			fmt.Printf("(%v) (%v) Flow | A: %v:%v B: %v:%v\n", // nice format
-				proto,
-				pktHash,
-				convertIPToString(pkt.SrcIP),
-				pkt.SrcPort,
-				convertIPToString(pkt.DstIP),
-				pkt.DstPort,
-			)
-			return
+			return flowtable.Keep
 		}
-		return
-	} else { //existing connection, in other words it's a new packet that belongs to an existing connection
+
+		//existing connection, in other words it's a new packet that belongs to an existing connection
 		//ask if the pkt is inbound or outbound and update the corresponding counters
-		if pkt.Outbound {
-			conn.Packets_out++
-			conn.Bytes_out = conn.Bytes_out + uint64(pkt.Len)
-			conn.Ts_fin = pkt.TimeStamp
-		} else {
-			conn.Packets_in++
-			conn.Bytes_in = conn.Bytes_in + uint64(pkt.Len)
-			conn.Ts_fin = pkt.TimeStamp
-		}
-		//in this case "Insert" updates the existing connection with new value c
-		//ftMutex.Lock()
-		table.Insert(pktHash, conn)
-		//ftMutex.Unlock()
-
-		//print connection statistics
-
-		// inpps := float64(conn.Packets_in) / ((float64(conn.Ts_fin) - float64(conn.Ts_ini)) / 1000000000)
-		// outpps := float64(conn.Packets_out) / ((float64(conn.Ts_fin) - float64(conn.Ts_ini)) / 1000000000)
-		// inBpp := float64(0)
-		// if conn.Packets_in != 0 {
-		// 	inBpp = float64(conn.Bytes_in) / float64(conn.Packets_in)
-		// }
-		// outBpp := float64(0)
-		// if conn.Packets_out != 0 {
-		// 	outBpp = float64(conn.Bytes_out) / float64(conn.Packets_out)
-		// }
-		// inBoutB := float64(0)
-		// if conn.Bytes_out != 0 {
-		// 	inBoutB = float64(conn.Bytes_in) / float64(conn.Bytes_out)
-		// }
-		// inPoutP := float64(0)
-		// if conn.Packets_out != 0 {
-		// 	inPoutP = float64(conn.Packets_in) / float64(conn.Packets_out)
-		// }
-
-		// fmt.Printf("(%v) (%v) Flow | A: %v:%v B: %v:%v | inpps: %.2f | outpps: %.2f | inBpp: %.2f | outBpp: %.2f| inBoutB: %.2f | inPoutP: %.2f\n", // nice format
-		// 	// fmt.Printf("(%v) Flow | A: %v:%v | B: %v:%v | In_pps: %v |\tlatency: %.3f ms\n",
-		// 	proto,
-		// 	pktHash,
-		// 	convertIPToString(conn.AIp),
-		// 	conn.APort,
-		// 	convertIPToString(conn.BIp),
-		// 	conn.BPort,
-		// 	inpps,
-		// 	outpps,
-		// 	inBpp,
-		// 	outBpp,
-		// 	inBoutB,
-		// 	inPoutP,
-		// )
-
-		// fmt.Printf("conn: %+v\n", conn)
-		// fmt.Printf("pkt: %+v\n", pkt)
-		fmt.Printf(" \n")
+		conn.Count(pkt.Outbound, 1, uint64(pkt.Len), table.SampleRate())
+		conn.Ts_fin = pkt.TimeStamp
+		updateHistory(conn, pkt)
 
 		if pkt.Fin {
-			//si es fin empezar contadores nuevos locales temporales, cuando vea que han pasado 2fin y 2ack para esta conexion, autoaticamente eliminarla de la tabla
-			contFin++
-			if contFin >= 2 {
-				wait4ACK = true
-				contFin = 0
-			}
-		} else if wait4ACK && pkt.Ack && !pkt.Syn {
-			if wait4ACK && pkt.Ack && !pkt.Syn && !pkt.Fin {
-				//that was the last packet of the TCP connection. The TCP connection is closed. Remove it.
-				//ftMutex.Lock()
-				table.Remove(pktHash)
-				//ftMutex.Unlock()
-				wait4ACK = false
-				//ftMutex.RLock()
-				table.CountActiveConns()
-				//ftMutex.RUnlock()
-			}
+			//cuando vea que han pasado 2fin y el ack final para esta conexion, automaticamente eliminarla de la tabla
+			conn.Fins++
+		} else if conn.Fins >= 2 && pkt.Ack && !pkt.Syn {
+			//that was the last packet of the TCP connection. The TCP connection is closed. Remove it.
+			return flowtable.Close
 		}
 
+		return flowtable.Keep
+	})
+}
+
+// Aggregate holds the counters of a flow aggregated in the kernel. Its packet describes
+// the flow from the local end, as an outbound packet stamped with the last time it was seen,
+// on the interface and namespace of the kernel flow key
+type Aggregate struct {
+	Packet
+	PacketsIn  uint64
+	PacketsOut uint64
+	BytesIn    uint64
+	BytesOut   uint64
+	TsStart    uint64
+}
+
+// AddAggregate merges the counters aggregated in the kernel into the table. A flow not
+// in the table yet is started with the local end as A
+func AddAggregate(agg Aggregate, table *flowtable.FlowTable) {
+	proto, ok := ipProtoNums[agg.Protocol]
+	if !ok {
+		return
 	}
 
+	pktHash := agg.Hash()
+
+	table.Upsert(pktHash, func(conn *flowtable.Connection, found bool) flowtable.Verdict {
+		if !found {
+			conn.Hash = pktHash
+			conn.Proto = proto
+			conn.AIp = agg.SrcIP
+			conn.APort = agg.SrcPort
+			conn.BIp = agg.DstIP
+			conn.BPort = agg.DstPort
+			conn.Outbound = true
+			conn.Ifindex = agg.Ifindex
+			conn.Netns = agg.Netns
+			conn.Ts_ini = agg.TsStart
+			conn.CommunityID = agg.CommunityID(table.CommunityIDSeed)
+			conn.SampleRate = table.SampleRate()
+		}
+
+		rate := table.SampleRate()
+		conn.Count(false, agg.PacketsIn, agg.BytesIn, rate)
+		conn.Count(true, agg.PacketsOut, agg.BytesOut, rate)
+		if agg.TimeStamp > conn.Ts_fin {
+			conn.Ts_fin = agg.TimeStamp
+		}
+
+		return flowtable.Keep
+	})
 }
@@ -1,9 +1,13 @@
 package packet
 
 import (
+	"encoding/binary"
+	"hash/fnv"
 	"net/netip"
 	"testing"
 
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,3 +27,182 @@ func TestHashReverseCollision(t *testing.T) {
 
 	require.Equal(t, pakcetOutgoing.Hash(), pakcetIncoming.Hash())
 }
+
+func TestSampledConnection(t *testing.T) {
+	pkt := Packet{
+		SrcIP:    netip.MustParseAddr("192.168.0.156"),
+		DstIP:    netip.MustParseAddr("1.1.1.1"),
+		SrcPort:  53264,
+		DstPort:  443,
+		Protocol: 6,
+		Ack:      true,
+		Len:      100,
+	}
+
+	// Without sampling a TCP connection only starts with a SYN
+	table := flowtable.NewFlowTable()
+	CalcStats(pkt, table)
+	require.Empty(t, table.GetConnList())
+
+	table.SetSampleRate(10)
+	CalcStats(pkt, table)
+	conns := table.GetConnList()
+	require.Len(t, conns, 1)
+	require.Equal(t, uint32(10), conns[0].SampleRate)
+
+	// Only picked up mid-stream when asked for, it keeps the sender of its first packet as A
+	require.False(t, conns[0].MidStream)
+	require.Equal(t, pkt.SrcIP, conns[0].AIp)
+
+	conn := conns[0].Extrapolated()
+	require.True(t, conn.Estimated)
+	require.Equal(t, uint64(10), conn.Packets_in)
+	require.Equal(t, uint64(1000), conn.Bytes_in)
+	require.Equal(t, conn, conn.Extrapolated())
+}
+
+func TestResampledConnection(t *testing.T) {
+	pkt := Packet{
+		SrcIP:    netip.MustParseAddr("192.168.0.156"),
+		DstIP:    netip.MustParseAddr("1.1.1.1"),
+		SrcPort:  53264,
+		DstPort:  53,
+		Protocol: 17,
+		Len:      100,
+	}
+
+	table := flowtable.NewFlowTable()
+	table.SetSampleRate(10)
+	CalcStats(pkt, table)
+
+	// Backpressure raises the sampling factor mid-flow
+	table.SetSampleRate(40)
+	CalcStats(pkt, table)
+	CalcStats(pkt, table)
+
+	conns := table.GetConnList()
+	require.Len(t, conns, 1)
+	require.Equal(t, uint32(10), conns[0].SampleRate)
+	require.True(t, conns[0].Resampled)
+	require.Equal(t, uint64(3), conns[0].Packets_in)
+
+	conn := conns[0].Extrapolated()
+	require.True(t, conn.Estimated)
+	require.Equal(t, uint64(90), conn.Packets_in)
+	require.Equal(t, uint64(9000), conn.Bytes_in)
+
+	// Lowered back, each packet keeps the weight of its own rate
+	table.SetSampleRate(10)
+	CalcStats(pkt, table)
+	conn = table.GetConnList()[0].Extrapolated()
+	require.Equal(t, uint64(100), conn.Packets_in)
+}
+
+func TestAddAggregate(t *testing.T) {
+	table := flowtable.NewFlowTable()
+	started := 0
+	table.OnEvent(func(ev flowtable.Event) { started++ })
+
+	agg := Aggregate{
+		Packet: Packet{
+			SrcIP:     netip.MustParseAddr("192.168.0.156"),
+			DstIP:     netip.MustParseAddr("1.1.1.1"),
+			SrcPort:   53264,
+			DstPort:   443,
+			Protocol:  6,
+			TimeStamp: 200,
+			Outbound:  true,
+			Ifindex:   3,
+			Netns:     4096,
+		},
+		PacketsIn:  2,
+		PacketsOut: 3,
+		BytesIn:    200,
+		BytesOut:   300,
+		TsStart:    100,
+	}
+	AddAggregate(agg, table)
+
+	agg.TimeStamp = 300
+	AddAggregate(agg, table)
+
+	conns := table.GetConnList()
+	require.Len(t, conns, 1)
+	require.Equal(t, 1, started)
+	require.Equal(t, uint64(4), conns[0].Packets_in)
+	require.Equal(t, uint64(600), conns[0].Bytes_out)
+	require.Equal(t, uint64(100), conns[0].Ts_ini)
+	require.Equal(t, uint64(300), conns[0].Ts_fin)
+	require.Equal(t, uint32(3), conns[0].Ifindex)
+	require.Equal(t, uint64(4096), conns[0].Netns)
+
+	// The packets of the flow seen once user space catches up land in the same flow
+	pkt := agg.Packet
+	pkt.TimeStamp = 400
+	CalcStats(pkt, table)
+	conns = table.GetConnList()
+	require.Len(t, conns, 1)
+	require.Equal(t, uint64(7), conns[0].Packets_out)
+}
+
+// referenceHash is the original implementation of Hash, the flow keys must not change
+func referenceHash(pkt Packet) uint64 {
+	sum := func(value []byte) uint64 {
+		hash := fnv.New64a()
+		hash.Write(value)
+		return hash.Sum64()
+	}
+	tmp := make([]byte, 2)
+
+	binary.BigEndian.PutUint16(tmp, pkt.SrcPort)
+	src := append(pkt.SrcIP.AsSlice(), tmp...)
+	binary.BigEndian.PutUint16(tmp, pkt.DstPort)
+	dst := append(pkt.DstIP.AsSlice(), tmp...)
+	binary.BigEndian.PutUint16(tmp, uint16(pkt.Protocol))
+
+	return sum(src) + sum(dst) + sum(tmp)
+}
+
+func TestHashMatchesReference(t *testing.T) {
+	for _, pkt := range []Packet{
+		{SrcIP: netip.MustParseAddr("192.168.0.156"), DstIP: netip.MustParseAddr("1.1.1.1"), SrcPort: 53264, DstPort: 53, Protocol: 17},
+		{SrcIP: netip.MustParseAddr("::ffff:10.0.0.1"), DstIP: netip.MustParseAddr("::ffff:10.0.0.2"), SrcPort: 1, DstPort: 443, Protocol: 6},
+		{SrcIP: netip.MustParseAddr("2001:db8::1"), DstIP: netip.MustParseAddr("2001:db8::2"), SrcPort: 80, DstPort: 8080, Protocol: 6},
+	} {
+		require.Equal(t, referenceHash(pkt), pkt.Hash())
+		require.Zero(t, testing.AllocsPerRun(10, func() { pkt.Hash() }))
+	}
+}
+
+func TestHashIfindex(t *testing.T) {
+	out := Packet{SrcIP: netip.MustParseAddr("10.0.0.1"), DstIP: netip.MustParseAddr("10.0.0.2"), SrcPort: 1234, DstPort: 80, Protocol: 6, Ifindex: 3}
+	in := Packet{SrcIP: out.DstIP, DstIP: out.SrcIP, SrcPort: out.DstPort, DstPort: out.SrcPort, Protocol: 6, Ifindex: 3}
+	require.Equal(t, out.Hash(), in.Hash())
+
+	other := out
+	other.Ifindex = 4
+	require.NotEqual(t, out.Hash(), other.Hash())
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	in := make([]byte, EventSize)
+	copy(in[0:16], netip.MustParseAddr("::ffff:10.0.0.1").AsSlice())
+	copy(in[16:32], netip.MustParseAddr("::ffff:10.0.0.2").AsSlice())
+	binary.BigEndian.PutUint16(in[32:34], 1234)
+	binary.BigEndian.PutUint16(in[34:36], 80)
+	in[36] = 6
+	binary.BigEndian.PutUint32(in[49:53], 60)
+	binary.NativeEndian.PutUint32(in[56:60], 3)
+	binary.NativeEndian.PutUint64(in[64:72], 4096)
+
+	pkt, ok := UnmarshalBinary(in)
+	require.True(t, ok)
+	require.Equal(t, uint16(80), pkt.DstPort)
+	require.Equal(t, uint32(60), pkt.Len)
+	require.Equal(t, uint32(3), pkt.Ifindex)
+	require.Equal(t, uint64(4096), pkt.Netns)
+
+	// Events are never truncated, a short one is rejected rather than read as zeroes
+	_, ok = UnmarshalBinary(in[:53])
+	require.False(t, ok)
+}
@@ -0,0 +1,174 @@
+package packet
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+)
+
+// wellKnownPorts are below this one
+const wellKnownPorts = 1024
+
+// Listeners knows the TCP ports the local sockets listen on, read from /proc/net, and the
+// range of ephemeral ports the local clients are bound to
+type Listeners struct {
+	mu        sync.RWMutex
+	ports     map[uint16]bool
+	ephemeral [2]uint16
+}
+
+// NewListeners returns the listeners of the host, refreshed by Run
+func NewListeners() *Listeners {
+	l := &Listeners{ephemeral: [2]uint16{32768, 60999}}
+	if err := l.Refresh(); err != nil {
+		log.Printf("Failed reading the listening sockets: %v", err)
+	}
+	return l
+}
+
+func (l *Listeners) Listening(port uint16) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.ports[port]
+}
+
+func (l *Listeners) Ephemeral(port uint16) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return port >= l.ephemeral[0] && port <= l.ephemeral[1]
+}
+
+// Refresh reads the listening sockets and the ephemeral port range again
+func (l *Listeners) Refresh() error {
+	ports := make(map[uint16]bool)
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = parseListening(f, ports)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+	}
+
+	ephemeral := l.ephemeral
+	if r, err := os.ReadFile("/proc/sys/net/ipv4/ip_local_port_range"); err == nil {
+		if lo, hi, ok := parsePortRange(string(r)); ok {
+			ephemeral = [2]uint16{lo, hi}
+		}
+	}
+
+	l.mu.Lock()
+	l.ports = ports
+	l.ephemeral = ephemeral
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Run refreshes the listeners every interval until ctx is done
+func (l *Listeners) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Refresh(); err != nil {
+				log.Printf("Failed reading the listening sockets: %v", err)
+			}
+		}
+	}
+}
+
+// tcpListen is the state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// parseListening adds the ports of the listening sockets of a /proc/net/tcp table to ports
+func parseListening(r io.Reader, ports map[uint16]bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+
+	for scanner.Scan() {
+		// sl local_address rem_address st ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		_, port, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(port, 16, 16)
+		if err != nil {
+			return err
+		}
+		ports[uint16(n)] = true
+	}
+
+	return scanner.Err()
+}
+
+func parsePortRange(s string) (lo, hi uint16, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	l, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	h, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil || h < l {
+		return 0, 0, false
+	}
+	return uint16(l), uint16(h), true
+}
+
+// srcIsClient guesses whether the sender of a packet of a connection picked up mid-stream
+// initiated it: the sender of a SYN-ACK is the server, then so is the local end listening on
+// its port, a well-known port, a port out of the ephemeral range, and the lower port
+func srcIsClient(pkt Packet, roles flowtable.PortRoles) bool {
+	if pkt.Syn && pkt.Ack {
+		return false
+	}
+
+	// The local end sent the outbound packets
+	local := pkt.SrcPort
+	if !pkt.Outbound {
+		local = pkt.DstPort
+	}
+	if roles != nil && roles.Listening(local) {
+		return local == pkt.DstPort
+	}
+
+	srcWellKnown, dstWellKnown := pkt.SrcPort < wellKnownPorts, pkt.DstPort < wellKnownPorts
+	if srcWellKnown != dstWellKnown {
+		return dstWellKnown
+	}
+
+	if roles != nil {
+		srcEphemeral, dstEphemeral := roles.Ephemeral(pkt.SrcPort), roles.Ephemeral(pkt.DstPort)
+		if srcEphemeral != dstEphemeral {
+			return srcEphemeral
+		}
+	}
+
+	return pkt.SrcPort > pkt.DstPort
+}
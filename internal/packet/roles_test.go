@@ -0,0 +1,88 @@
+package packet
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+
+	"github.com/stretchr/testify/require"
+)
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1234 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1235 1 0000000000000000 100 0 0 10 0
+   2: 9C00A8C0:D010 01010101:01BB 01 00000000:00000000 00:00000000 00000000     0        0 1236 1 0000000000000000 100 0 0 10 0
+`
+
+func TestParseListening(t *testing.T) {
+	ports := make(map[uint16]bool)
+	require.NoError(t, parseListening(strings.NewReader(procNetTCP), ports))
+	require.Equal(t, map[uint16]bool{22: true, 8080: true}, ports)
+
+	lo, hi, ok := parsePortRange("32768\t60999\n")
+	require.True(t, ok)
+	require.Equal(t, [2]uint16{32768, 60999}, [2]uint16{lo, hi})
+	_, _, ok = parsePortRange("60999 32768")
+	require.False(t, ok)
+}
+
+// testRoles listens on 8080, with the default ephemeral range
+type testRoles struct{}
+
+func (testRoles) Listening(port uint16) bool { return port == 8080 }
+func (testRoles) Ephemeral(port uint16) bool { return port >= 32768 && port <= 60999 }
+
+func TestSrcIsClient(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		pkt         Packet
+		roles       flowtable.PortRoles
+		srcIsClient bool
+	}{
+		{"syn-ack", Packet{SrcPort: 40000, DstPort: 50000, Syn: true, Ack: true}, nil, false},
+		{"listening", Packet{SrcPort: 8080, DstPort: 2000, Outbound: true}, testRoles{}, false},
+		{"listening inbound", Packet{SrcPort: 2000, DstPort: 8080}, testRoles{}, true},
+		{"well-known", Packet{SrcPort: 443, DstPort: 53264}, testRoles{}, false},
+		{"ephemeral", Packet{SrcPort: 5000, DstPort: 40000}, testRoles{}, false},
+		{"lower port", Packet{SrcPort: 5000, DstPort: 6000}, nil, false},
+	} {
+		require.Equal(t, c.srcIsClient, srcIsClient(c.pkt, c.roles), c.name)
+	}
+}
+
+func TestMidStreamPickup(t *testing.T) {
+	// A reply from the server to a connection opened before the probe started
+	pkt := Packet{
+		SrcIP:    netip.MustParseAddr("1.1.1.1"),
+		DstIP:    netip.MustParseAddr("192.168.0.156"),
+		SrcPort:  443,
+		DstPort:  53264,
+		Protocol: 6,
+		Ack:      true,
+		Len:      100,
+	}
+
+	table := flowtable.NewFlowTable()
+	table.PickupMidStream = true
+	CalcStats(pkt, table)
+
+	conns := table.GetConnList()
+	require.Len(t, conns, 1)
+	conn := conns[0]
+	require.True(t, conn.MidStream)
+	require.Equal(t, pkt.DstIP, conn.AIp)
+	require.Equal(t, uint16(53264), conn.APort)
+	require.True(t, conn.Outbound)
+	require.Equal(t, "^a", conn.History)
+	require.Equal(t, uint64(1), conn.Packets_in)
+
+	// A connection starting with its SYN is not mid-stream
+	pkt.SrcPort, pkt.Syn, pkt.Ack = 80, true, false
+	CalcStats(pkt, table)
+	require.Len(t, table.GetConnList(), 2)
+	conn, _ = table.Get(pkt.Hash())
+	require.False(t, conn.MidStream)
+	require.Equal(t, "S", conn.History)
+}
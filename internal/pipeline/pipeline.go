@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+)
+
+const (
+	DefaultBatchSize = 256
+	batchesPerWorker = 4
+)
+
+// Config sizes the pipeline
+type Config struct {
+	Workers   int // goroutines updating the flow table, the number of CPUs when 0
+	BatchSize int // packets handed to a worker at once
+}
+
+// event is a packet, or an update of the flow of hash when update is set
+type event struct {
+	pkt    packet.Packet
+	hash   uint64
+	update func(conn *flowtable.Connection, found bool) flowtable.Verdict
+}
+
+// batch is handed by a producer to a worker, which returns it to the free channel it came
+// from. Batches go around between the free and work channels, so the steady state does not
+// allocate
+type batch struct {
+	events []event
+	free   chan *batch
+}
+
+// worker updates the flows of the table shards it owns
+type worker struct {
+	work chan *batch
+}
+
+// Producer queues packets for the workers. Each goroutine reading events uses its own
+// Producer, keeping the packets of a flow it reads in order
+type Producer struct {
+	pipeline *Pipeline
+	pending  []*batch
+	free     []chan *batch
+}
+
+// Pipeline spreads the packets read from the probe over workers sharded by flow. Every
+// packet of a flow goes to the same worker
+type Pipeline struct {
+	table     *flowtable.FlowTable
+	workers   []*worker
+	batchSize int
+	wg        sync.WaitGroup
+
+	mu        sync.Mutex
+	producers []*Producer
+	main      *Producer // used by Add and Flush
+}
+
+func New(table *flowtable.FlowTable, cfg Config) *Pipeline {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.Workers > flowtable.NumShards {
+		cfg.Workers = flowtable.NumShards
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	p := &Pipeline{table: table, batchSize: cfg.BatchSize}
+
+	for i := 0; i < cfg.Workers; i++ {
+		w := &worker{work: make(chan *batch, batchesPerWorker)}
+		p.workers = append(p.workers, w)
+
+		p.wg.Add(1)
+		go p.run(w)
+	}
+	p.main = p.NewProducer()
+
+	return p
+}
+
+// NewProducer returns a Producer with its own batches for every worker
+func (p *Pipeline) NewProducer() *Producer {
+	prod := &Producer{
+		pipeline: p,
+		pending:  make([]*batch, len(p.workers)),
+		free:     make([]chan *batch, len(p.workers)),
+	}
+	for i := range p.workers {
+		free := make(chan *batch, batchesPerWorker)
+		for j := 0; j < batchesPerWorker; j++ {
+			free <- &batch{events: make([]event, 0, p.batchSize), free: free}
+		}
+		prod.free[i] = free
+	}
+
+	p.mu.Lock()
+	p.producers = append(p.producers, prod)
+	p.mu.Unlock()
+
+	return prod
+}
+
+func (p *Pipeline) run(w *worker) {
+	defer p.wg.Done()
+
+	for b := range w.work {
+		for i := range b.events {
+			ev := &b.events[i]
+			if ev.update != nil {
+				p.table.Upsert(ev.hash, ev.update)
+			} else {
+				packet.UpdateStats(ev.pkt, ev.hash, p.table)
+			}
+			*ev = event{}
+		}
+		b.events = b.events[:0]
+		b.free <- b
+	}
+}
+
+// Add queues the packet for the worker owning its flow. It blocks while that worker is
+// behind, and is meant to be called from a single reader goroutine
+func (prod *Producer) Add(pkt packet.Packet) {
+	prod.queue(event{pkt: pkt, hash: pkt.Hash()})
+}
+
+// Upsert queues an update of the flow of hash, applied with FlowTable.Upsert by the worker
+// owning it, in order with the packets of the flow. It is meant for the sources reading
+// flows rather than packets
+func (prod *Producer) Upsert(hash uint64, update func(conn *flowtable.Connection, found bool) flowtable.Verdict) {
+	prod.queue(event{hash: hash, update: update})
+}
+
+func (prod *Producer) queue(ev event) {
+	p := prod.pipeline
+	i := flowtable.ShardOf(ev.hash) % len(p.workers)
+
+	b := prod.pending[i]
+	if b == nil {
+		b = <-prod.free[i]
+		prod.pending[i] = b
+	}
+	b.events = append(b.events, ev)
+
+	if len(b.events) == p.batchSize {
+		p.workers[i].work <- b
+		prod.pending[i] = nil
+	}
+}
+
+// Flush hands the partial batches to the workers, called when the reader runs out of events
+func (prod *Producer) Flush() {
+	for i, b := range prod.pending {
+		if b != nil && len(b.events) > 0 {
+			prod.pipeline.workers[i].work <- b
+			prod.pending[i] = nil
+		}
+	}
+}
+
+// Add queues the packet with the Producer of the pipeline
+func (p *Pipeline) Add(pkt packet.Packet) {
+	p.main.Add(pkt)
+}
+
+// Upsert queues the flow update with the Producer of the pipeline
+func (p *Pipeline) Upsert(hash uint64, update func(conn *flowtable.Connection, found bool) flowtable.Verdict) {
+	p.main.Upsert(hash, update)
+}
+
+// Flush hands the partial batches of the Producer of the pipeline to the workers
+func (p *Pipeline) Flush() {
+	p.main.Flush()
+}
+
+// Close flushes the pending packets of every Producer and waits for the workers to process
+// them. The producers must be stopped before
+func (p *Pipeline) Close() {
+	p.mu.Lock()
+	for _, prod := range p.producers {
+		prod.Flush()
+	}
+	p.mu.Unlock()
+
+	for _, w := range p.workers {
+		close(w.work)
+	}
+	p.wg.Wait()
+}
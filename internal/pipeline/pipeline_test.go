@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+
+	"github.com/stretchr/testify/require"
+)
+
+// udpPackets returns n packets spread over flows UDP flows
+func udpPackets(n, flows int) []packet.Packet {
+	pkts := make([]packet.Packet, n)
+	for i := range pkts {
+		flow := i % flows
+		addr := [4]byte{10, 0, 0, 0}
+		binary.BigEndian.PutUint16(addr[2:], uint16(flow))
+
+		pkts[i] = packet.Packet{
+			SrcIP:     netip.AddrFrom4(addr),
+			DstIP:     netip.MustParseAddr("10.255.0.1"),
+			SrcPort:   uint16(1024 + flow),
+			DstPort:   53,
+			Protocol:  17,
+			TimeStamp: uint64(time.Millisecond) * uint64(i),
+			Outbound:  flow%2 == 0,
+			Len:       100,
+		}
+	}
+	return pkts
+}
+
+func TestPipelineMatchesSerial(t *testing.T) {
+	pkts := udpPackets(10000, 100)
+
+	serial := flowtable.NewFlowTable()
+	for _, pkt := range pkts {
+		packet.CalcStats(pkt, serial)
+	}
+
+	sharded := flowtable.NewFlowTable()
+	p := New(sharded, Config{Workers: 4, BatchSize: 16})
+	for _, pkt := range pkts {
+		p.Add(pkt)
+	}
+	p.Close()
+
+	require.Equal(t, 100, sharded.Len())
+	for _, conn := range serial.GetConnList() {
+		got, ok := sharded.Get(conn.Hash)
+		require.True(t, ok)
+		require.Equal(t, conn, got)
+	}
+}
+
+func TestProducersMatchSerial(t *testing.T) {
+	pkts := udpPackets(10000, 100)
+
+	serial := flowtable.NewFlowTable()
+	for _, pkt := range pkts {
+		packet.CalcStats(pkt, serial)
+	}
+
+	// Each producer reads the flows of its own CPU, as with RSS
+	sharded := flowtable.NewFlowTable()
+	p := New(sharded, Config{Workers: 3, BatchSize: 16})
+	producers := []*Producer{p.NewProducer(), p.NewProducer()}
+
+	var wg sync.WaitGroup
+	for i, prod := range producers {
+		wg.Add(1)
+		go func(cpu int, prod *Producer) {
+			defer wg.Done()
+			for j, pkt := range pkts {
+				if j%100%len(producers) == cpu {
+					prod.Add(pkt)
+				}
+			}
+			prod.Flush()
+		}(i, prod)
+	}
+	wg.Wait()
+	p.Close()
+
+	require.Equal(t, 100, sharded.Len())
+	for _, conn := range serial.GetConnList() {
+		got, ok := sharded.Get(conn.Hash)
+		require.True(t, ok)
+		require.Equal(t, conn, got)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	pkts := udpPackets(1<<16, 4096)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			table := flowtable.NewFlowTable()
+			p := New(table, Config{Workers: workers})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			start := time.Now()
+
+			for i := 0; i < b.N; i++ {
+				p.Add(pkts[i%len(pkts)])
+			}
+			p.Close()
+
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+	"github.com/gabspt/ConnectionStats/internal/replay"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Geometry of the AF_PACKET rings. The kernel hands a block to user space when it is full,
+// or packetBlockTimeout milliseconds after its first packet
+const (
+	packetBlockSize    = 1 << 20
+	packetBlocks       = 8
+	packetFrameSize    = 1 << 11
+	packetBlockTimeout = 10
+	packetPollTimeout  = 100 // milliseconds between the checks for the end of the run
+)
+
+// Offsets in the TPACKET_V3 block descriptor, whose header follows its version and private
+// area offset
+const (
+	blockStatusOffset   = 8
+	blockPacketsOffset  = 12
+	blockFirstPktOffset = 16
+)
+
+// packetSource reads the packets of the interfaces matching ifaces from AF_PACKET rings, for
+// the hosts that forbid loading eBPF programs. The interfaces are those present when it
+// starts, the ones appearing later are not followed
+type packetSource struct {
+	ifaces Interfaces
+}
+
+// NewPacketSource returns the source capturing the packets of the interfaces with AF_PACKET
+// TPACKET_V3 rings. It neither filters nor samples, and captures are not available
+func NewPacketSource(ifaces Interfaces) FlowSource {
+	return &packetSource{ifaces: ifaces}
+}
+
+func (src *packetSource) Run(ctx context.Context, ft *flowtable.FlowTable, workers *pipeline.Pipeline) error {
+	rings, err := src.openRings()
+	if err != nil {
+		return err
+	}
+
+	var ringsDone sync.WaitGroup
+	for _, ring := range rings {
+		ringsDone.Add(1)
+		go func(ring *packetRing, producer *pipeline.Producer) {
+			defer ringsDone.Done()
+			ring.read(ctx, producer)
+		}(ring, workers.NewProducer())
+	}
+
+	<-ctx.Done()
+	ringsDone.Wait()
+	for _, ring := range rings {
+		ring.Close()
+	}
+
+	return nil
+}
+
+// openRings opens a ring on each interface matching the patterns, in its namespace
+func (src *packetSource) openRings() ([]*packetRing, error) {
+	var rings []*packetRing
+	closeRings := func() {
+		for _, ring := range rings {
+			ring.Close()
+		}
+	}
+
+	for _, name := range src.ifaces.Namespaces() {
+		ns, err := openNamespace(name)
+		if err != nil {
+			closeRings()
+			return nil, err
+		}
+
+		links, err := src.ifaces.In(name).linksIn(ns.nl.LinkList)
+		if err != nil {
+			ns.Close()
+			closeRings()
+			return nil, err
+		}
+
+		for _, link := range links {
+			ring, err := openPacketRing(ns, link)
+			if err != nil {
+				log.Printf("Failed opening a packet ring on %v of namespace %v: %v", link.Attrs().Name, ns, err)
+				ns.Close()
+				closeRings()
+				return nil, err
+			}
+			log.Printf("Capturing the packets of %v in namespace %v", link.Attrs().Name, ns)
+			rings = append(rings, ring)
+		}
+
+		// The sockets stay in the namespace they were created in
+		ns.Close()
+	}
+
+	if len(rings) == 0 {
+		return nil, fmt.Errorf("no interface matching %v", src.ifaces)
+	}
+	return rings, nil
+}
+
+// packetRing is an AF_PACKET socket bound to an interface, with its TPACKET_V3 ring mapped
+type packetRing struct {
+	fd    int
+	ring  []byte
+	next  int    // block read next
+	netns uint64 // cookie of the namespace of the interface
+}
+
+func openPacketRing(ns *namespace, link netlink.Link) (*packetRing, error) {
+	var fd int
+	err := ns.do(func() error {
+		var err error
+		// No protocol until the ring is set up, so no packet is queued before
+		fd, err = unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &packetRing{fd: fd, netns: ns.cookie}
+	if err := r.setup(link.Attrs().Index); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *packetRing) setup(ifindex int) error {
+	if err := unix.SetsockoptInt(r.fd, unix.SOL_PACKET, unix.PACKET_VERSION, unix.TPACKET_V3); err != nil {
+		return fmt.Errorf("selecting TPACKET_V3: %w", err)
+	}
+
+	req := unix.TpacketReq3{
+		Block_size:     packetBlockSize,
+		Block_nr:       packetBlocks,
+		Frame_size:     packetFrameSize,
+		Frame_nr:       packetBlockSize / packetFrameSize * packetBlocks,
+		Retire_blk_tov: packetBlockTimeout,
+	}
+	if err := unix.SetsockoptTpacketReq3(r.fd, unix.SOL_PACKET, unix.PACKET_RX_RING, &req); err != nil {
+		return fmt.Errorf("setting up the ring: %w", err)
+	}
+
+	ring, err := unix.Mmap(r.fd, 0, packetBlockSize*packetBlocks, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mapping the ring: %w", err)
+	}
+	r.ring = ring
+
+	addr := &unix.SockaddrLinklayer{Protocol: networkOrder(unix.ETH_P_ALL), Ifindex: ifindex}
+	if err := unix.Bind(r.fd, addr); err != nil {
+		return fmt.Errorf("binding to the interface: %w", err)
+	}
+	return nil
+}
+
+// read hands the packets of the ring to the pipeline until ctx is done. Partial batches are
+// flushed once the ring is drained
+func (r *packetRing) read(ctx context.Context, workers *pipeline.Producer) {
+	fds := []unix.PollFd{{Fd: int32(r.fd), Events: unix.POLLIN | unix.POLLERR}}
+
+	for ctx.Err() == nil {
+		block := r.ring[r.next*packetBlockSize : (r.next+1)*packetBlockSize]
+		status := (*uint32)(unsafe.Pointer(&block[blockStatusOffset]))
+
+		if atomic.LoadUint32(status)&unix.TP_STATUS_USER == 0 {
+			workers.Flush()
+			if _, err := unix.Poll(fds, packetPollTimeout); err != nil && !errors.Is(err, unix.EINTR) {
+				log.Printf("Failed polling the packet ring: %v", err)
+				return
+			}
+			continue
+		}
+
+		// The ring is stamped with the wall clock, the flow table with the time since boot
+		bootTime := time.Now().UnixNano() - int64(timer.GetNanosecSinceBoot())
+		blockPackets(block, func(pkt packet.Packet) {
+			pkt.TimeStamp -= uint64(bootTime)
+			pkt.Netns = r.netns
+			workers.Add(pkt)
+		})
+
+		// Hand the block back to the kernel
+		atomic.StoreUint32(status, unix.TP_STATUS_KERNEL)
+		r.next = (r.next + 1) % packetBlocks
+	}
+}
+
+func (r *packetRing) Close() {
+	if r.ring != nil {
+		if err := unix.Munmap(r.ring); err != nil {
+			log.Printf("Failed unmapping the packet ring: %v", err)
+		}
+	}
+	if err := unix.Close(r.fd); err != nil {
+		log.Printf("Failed closing the packet socket: %v", err)
+	}
+}
+
+// blockPackets calls fn with the IP packets of a ring block, stamped with the wall clock.
+// Other packets are skipped
+func blockPackets(block []byte, fn func(packet.Packet)) {
+	count := binary.NativeEndian.Uint32(block[blockPacketsOffset:])
+	offset := int(binary.NativeEndian.Uint32(block[blockFirstPktOffset:]))
+
+	for i := uint32(0); i < count; i++ {
+		hdr := (*unix.Tpacket3Hdr)(unsafe.Pointer(&block[offset]))
+		sll := (*unix.RawSockaddrLinklayer)(unsafe.Pointer(&block[offset+tpacketAlign(unix.SizeofTpacket3Hdr)]))
+
+		// The network header follows the link layer one, if any, in the captured bytes
+		end := offset + int(hdr.Mac) + int(hdr.Snaplen)
+		if start := offset + int(hdr.Net); start < end {
+			pkt, ok := replay.Decode(block[start:end], layers.LinkTypeRaw, gopacket.CaptureInfo{Length: int(hdr.Len)})
+			if ok {
+				pkt.TimeStamp = uint64(hdr.Sec)*uint64(time.Second) + uint64(hdr.Nsec)
+				pkt.Outbound = sll.Pkttype == unix.PACKET_OUTGOING
+				pkt.Ifindex = uint32(sll.Ifindex)
+				fn(pkt)
+			}
+		}
+
+		offset += int(hdr.Next_offset)
+	}
+}
+
+func tpacketAlign(n int) int {
+	return (n + unix.TPACKET_ALIGNMENT - 1) &^ (unix.TPACKET_ALIGNMENT - 1)
+}
+
+// networkOrder returns v with its bytes in network order, as the socket addresses take it
+func networkOrder(v uint16) uint16 {
+	return binary.NativeEndian.Uint16(binary.BigEndian.AppendUint16(nil, v))
+}
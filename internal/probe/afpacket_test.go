@@ -0,0 +1,106 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+func udpFrame(t *testing.T) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{5, 4, 3, 2, 1, 0},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}, Protocol: layers.IPProtocolUDP}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload("query")))
+	return buf.Bytes()
+}
+
+// putPacket writes a TPACKET_V3 packet at offset of the block, returning the offset of the next one
+func putPacket(block []byte, offset int, frame []byte, pkttype uint8) int {
+	hdr := (*unix.Tpacket3Hdr)(unsafe.Pointer(&block[offset]))
+	sll := (*unix.RawSockaddrLinklayer)(unsafe.Pointer(&block[offset+tpacketAlign(unix.SizeofTpacket3Hdr)]))
+
+	mac := tpacketAlign(tpacketAlign(unix.SizeofTpacket3Hdr) + int(unsafe.Sizeof(*sll)))
+	copy(block[offset+mac:], frame)
+
+	hdr.Sec, hdr.Nsec = 1700000000, 500
+	hdr.Snaplen, hdr.Len = uint32(len(frame)), uint32(len(frame))
+	hdr.Mac, hdr.Net = uint16(mac), uint16(mac+14)
+	hdr.Next_offset = uint32(tpacketAlign(mac + len(frame)))
+	sll.Ifindex, sll.Pkttype = 7, pkttype
+
+	return offset + int(hdr.Next_offset)
+}
+
+func TestBlockPackets(t *testing.T) {
+	block := make([]byte, 4096)
+	first := 48
+	binary.NativeEndian.PutUint32(block[blockPacketsOffset:], 2)
+	binary.NativeEndian.PutUint32(block[blockFirstPktOffset:], uint32(first))
+
+	frame := udpFrame(t)
+	next := putPacket(block, first, frame, unix.PACKET_OUTGOING)
+	putPacket(block, next, frame, unix.PACKET_HOST)
+
+	var pkts []packet.Packet
+	blockPackets(block, func(pkt packet.Packet) { pkts = append(pkts, pkt) })
+
+	require.Len(t, pkts, 2)
+	require.Equal(t, netip.MustParseAddr("::ffff:10.0.0.1"), pkts[0].SrcIP)
+	require.Equal(t, uint16(53), pkts[0].DstPort)
+	require.Equal(t, uint8(17), pkts[0].Protocol)
+	require.Equal(t, uint32(len(frame)), pkts[0].Len)
+	require.Equal(t, uint32(7), pkts[0].Ifindex)
+	require.Equal(t, uint64(1700000000*time.Second+500), pkts[0].TimeStamp)
+	require.True(t, pkts[0].Outbound)
+	require.False(t, pkts[1].Outbound)
+}
+
+func TestPacketSource(t *testing.T) {
+	ft := flowtable.NewFlowTable()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, NewPacketSource(Interfaces{"lo"}), ft, pipeline.Config{})
+	}()
+
+	conn, err := net.Dial("udp", "127.0.0.1:9")
+	require.NoError(t, err)
+	defer conn.Close()
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	require.Eventually(t, func() bool {
+		conn.Write([]byte("ping"))
+		for _, c := range ft.GetConnList() {
+			if c.Proto == "UDP" && c.APort == port && c.BPort == 9 {
+				// Stamped with the time since boot, like the eBPF events
+				return c.Ifindex == 1 && timer.Now()-c.Ts_ini < uint64(time.Second)
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,321 @@
+package probe
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/gabspt/ConnectionStats/clsact"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Interfaces selects the links the probe attaches to, by name or glob such as veth*. A
+// pattern prefixed by a network namespace, as web/eth0 or pid:1234/eth*, selects links in
+// that namespace, named as by "ip netns" or by the PID of a process in it
+type Interfaces []string
+
+// ParseInterfaces parses a comma separated list of interface names and globs
+func ParseInterfaces(s string) (Interfaces, error) {
+	var ifaces Interfaces
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		ns, name := splitNetns(pattern)
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid interface pattern %q: %w", pattern, err)
+		}
+		if strings.HasSuffix(pattern, "/") || ns == "pid:" {
+			return nil, fmt.Errorf("invalid interface pattern %q", pattern)
+		}
+		ifaces = append(ifaces, pattern)
+	}
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no interface in %q", s)
+	}
+	return ifaces, nil
+}
+
+// splitNetns splits a pattern into its namespace, empty for the agent's, and interface
+func splitNetns(pattern string) (ns, name string) {
+	if i := strings.LastIndexByte(pattern, '/'); i >= 0 {
+		return pattern[:i], pattern[i+1:]
+	}
+	return "", pattern
+}
+
+// Namespaces returns the namespaces of the patterns, the agent's one as an empty name
+func (ifaces Interfaces) Namespaces() []string {
+	var names []string
+	for _, pattern := range ifaces {
+		ns, _ := splitNetns(pattern)
+		found := false
+		for _, name := range names {
+			found = found || name == ns
+		}
+		if !found {
+			names = append(names, ns)
+		}
+	}
+	return names
+}
+
+// In returns the patterns of the namespace, without it
+func (ifaces Interfaces) In(ns string) Interfaces {
+	var in Interfaces
+	for _, pattern := range ifaces {
+		if patternNs, name := splitNetns(pattern); patternNs == ns {
+			in = append(in, name)
+		}
+	}
+	return in
+}
+
+// Match reports whether the interface name of the agent's namespace matches any of the patterns
+func (ifaces Interfaces) Match(name string) bool {
+	for _, pattern := range ifaces.In("") {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Links returns the links present in the agent's namespace with a matching name
+func (ifaces Interfaces) Links() ([]netlink.Link, error) {
+	return ifaces.linksIn(netlink.LinkList)
+}
+
+func (ifaces Interfaces) linksIn(list func() ([]netlink.Link, error)) ([]netlink.Link, error) {
+	all, err := list()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []netlink.Link
+	for _, link := range all {
+		if ifaces.Match(link.Attrs().Name) {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// attachment holds the tcx links, or the qdisc and filters, running the probe programs on a
+// link. Every attachment shares the programs and maps of the probe
+type attachment struct {
+	ns       *namespace
+	iface    netlink.Link
+	links    []link.Link
+	xdp      bool   // the ingress program runs on XDP
+	ingress  string // hook of each direction, as reported
+	egress   string
+	qdisc    *clsact.ClsAct
+	ownQdisc bool // the qdisc was created by the probe
+	filters  []*netlink.BpfFilter
+}
+
+// linkUpdate is a change of a link in a namespace the probe follows
+type linkUpdate struct {
+	ns *namespace
+	netlink.LinkUpdate
+}
+
+// follow opens the namespace, forwards the changes of its links to updates until done is
+// closed, and attaches to the matching links present
+func (p *probe) follow(name string, ifaces Interfaces, updates chan<- linkUpdate, done <-chan struct{}) error {
+	ns, err := openNamespace(name)
+	if err != nil {
+		log.Printf("Failed opening network namespace: %v", err)
+		return err
+	}
+	ns.ifaces = ifaces
+	p.namespaces[name] = ns
+
+	// Subscribe before listing the links, so none is missed in between
+	ch := make(chan netlink.LinkUpdate, 16)
+	if err := netlink.LinkSubscribeAt(ns.handle, ch, done); err != nil {
+		log.Printf("Not following interface changes in namespace %v: %v", ns, err)
+	} else {
+		go func() {
+			for update := range ch {
+				select {
+				case updates <- linkUpdate{ns: ns, LinkUpdate: update}:
+				case <-done:
+					return
+				}
+			}
+			log.Printf("Stopped following interface changes in namespace %v", ns)
+		}()
+	}
+
+	links, err := ifaces.linksIn(ns.nl.LinkList)
+	if err != nil {
+		log.Printf("Failed listing interfaces: %v", err)
+		return err
+	}
+	for _, link := range links {
+		if err := p.attach(ns, link); err != nil {
+			return err
+		}
+	}
+	if len(links) == 0 {
+		log.Printf("No interface matches %v in namespace %v yet, waiting for one to appear", ifaces, ns)
+	}
+
+	return nil
+}
+
+// attach runs the probe programs on the link
+func (p *probe) attach(ns *namespace, iface netlink.Link) error {
+	a := &attachment{ns: ns, iface: iface}
+
+	if err := p.setL3Device(ns, iface, !hasL2Header(iface)); err != nil {
+		log.Printf("Failed setting the L2 header of %v: %v", iface.Attrs().Name, err)
+		return err
+	}
+
+	if err := p.attachXDP(a); err != nil {
+		return err
+	}
+
+	if err := p.attachTC(a); err != nil {
+		p.closeLinks(a.links, true)
+		return err
+	}
+
+	ns.links[iface.Attrs().Index] = a
+	log.Printf("Attached to %v, index %d, namespace %v, ingress on %v, egress on %v",
+		iface.Attrs().Name, iface.Attrs().Index, ns, a.ingress, a.egress)
+	p.reportAttachments()
+
+	return nil
+}
+
+// attachTC runs the tc programs on the link, with tcx or through a clsact qdisc
+func (p *probe) attachTC(a *attachment) error {
+	if p.attachMode != AttachNetlink {
+		err := p.attachLinks(a, p.tcxHooks(a))
+		switch {
+		case err == nil:
+			a.setTC("tcx")
+			return nil
+		case p.attachMode == AttachTCX || !tcxUnsupported(err):
+			log.Printf("Failed linking to %v: %v", a.iface.Attrs().Name, err)
+			return err
+		}
+		log.Printf("tcx not supported, attaching through a clsact qdisc")
+		p.attachMode = AttachNetlink
+	}
+
+	if err := p.createQdisc(a); err != nil {
+		log.Printf("Failed creating qdisc: %v", err)
+		return err
+	}
+
+	if err := p.createFilters(a); err != nil {
+		log.Printf("Failed creating qdisc filters: %v", err)
+		p.deleteQdisc(a)
+		return err
+	}
+	a.setTC("tc")
+
+	return nil
+}
+
+// setTC records the tc hook of the directions not running on XDP
+func (a *attachment) setTC(hook string) {
+	a.egress = hook
+	if !a.xdp {
+		a.ingress = hook
+	}
+}
+
+// detach removes the probe from the link, leaving what others installed on it. The links,
+// qdisc and filters of a link that is gone were removed by the kernel with it
+func (p *probe) detach(ns *namespace, index int, gone bool) error {
+	a, ok := ns.links[index]
+	if !ok {
+		return nil
+	}
+	delete(ns.links, index)
+
+	if err := p.setL3Device(ns, a.iface, false); err != nil {
+		log.Printf("Failed clearing the L2 header of %v: %v", a.iface.Attrs().Name, err)
+	}
+	if a.links != nil {
+		p.closeLinks(a.links, true)
+	}
+	p.reportAttachments()
+	if gone {
+		log.Printf("Interface %v removed from namespace %v", a.iface.Attrs().Name, ns)
+		return nil
+	}
+	if a.qdisc == nil {
+		return nil
+	}
+
+	if err := p.deleteQdisc(a); err != nil {
+		log.Println("Failed deleting qdisc")
+		return err
+	}
+
+	return nil
+}
+
+// reportAttachments publishes the attachments to the monitor, by namespace and interface
+func (p *probe) reportAttachments() {
+	if p.monitor == nil {
+		return
+	}
+
+	var attachments []Attachment
+	for _, ns := range p.namespaces {
+		for _, a := range ns.links {
+			attachments = append(attachments, Attachment{
+				Namespace: ns.String(),
+				Interface: a.iface.Attrs().Name,
+				Index:     a.iface.Attrs().Index,
+				Ingress:   a.ingress,
+				Egress:    a.egress,
+			})
+		}
+	}
+	slices.SortFunc(attachments, func(a, b Attachment) int {
+		if c := cmp.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Interface, b.Interface)
+	})
+
+	p.monitor.setAttachments(attachments)
+}
+
+// linkChanged attaches to the new links matching the patterns of the namespace, and
+// detaches from the removed links and those renamed to a name not matching
+func (p *probe) linkChanged(ns *namespace, update netlink.LinkUpdate) {
+	attrs := update.Link.Attrs()
+	_, attached := ns.links[attrs.Index]
+	matches := ns.ifaces.Match(attrs.Name)
+
+	switch {
+	case update.Header.Type == unix.RTM_DELLINK:
+		p.detach(ns, attrs.Index, true)
+
+	case !attached && matches:
+		if err := p.attach(ns, update.Link); err != nil {
+			log.Printf("Failed attaching to %v: %v", attrs.Name, err)
+		}
+
+	case attached && !matches:
+		if err := p.detach(ns, attrs.Index, false); err != nil {
+			log.Printf("Failed detaching from %v: %v", attrs.Name, err)
+		}
+	}
+}
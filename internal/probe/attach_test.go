@@ -0,0 +1,111 @@
+package probe
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInterfaces(t *testing.T) {
+	ifaces, err := ParseInterfaces("eth0, veth*,web/eth0,pid:1234/eth*")
+	require.NoError(t, err)
+	require.Equal(t, Interfaces{"eth0", "veth*", "web/eth0", "pid:1234/eth*"}, ifaces)
+
+	require.True(t, ifaces.Match("eth0"))
+	require.True(t, ifaces.Match("veth1a2b"))
+	require.False(t, ifaces.Match("eth1"))
+
+	require.Equal(t, []string{"", "web", "pid:1234"}, ifaces.Namespaces())
+	require.Equal(t, Interfaces{"eth*"}, ifaces.In("pid:1234"))
+
+	for _, invalid := range []string{"eth[", " , ", "web/", "pid:/eth0"} {
+		_, err = ParseInterfaces(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func newTestProbe(t *testing.T) *probe {
+	prbe := &probe{namespaces: make(map[string]*namespace)}
+	require.NoError(t, prbe.loadObjects())
+	return prbe
+}
+
+func TestFollowLinks(t *testing.T) {
+	prbe := newTestProbe(t)
+	defer prbe.Close()
+
+	ns, err := openNamespace("")
+	require.NoError(t, err)
+	prbe.namespaces[""] = ns
+
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cstest0"}, PeerName: "cstest1"}
+	require.NoError(t, netlink.LinkAdd(veth))
+	defer netlink.LinkDel(veth)
+
+	link, err := netlink.LinkByName("cstest0")
+	require.NoError(t, err)
+	index := link.Attrs().Index
+	newLink := netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_NEWLINK}, Link: link}
+
+	ns.ifaces = Interfaces{"other"}
+	prbe.linkChanged(ns, newLink)
+	require.NotContains(t, ns.links, index)
+
+	ns.ifaces = Interfaces{"cstest*"}
+	prbe.linkChanged(ns, newLink)
+	require.Contains(t, ns.links, index)
+
+	// Further changes of an attached link do not attach twice
+	prbe.linkChanged(ns, newLink)
+	require.Len(t, ns.links, 1)
+
+	require.NoError(t, netlink.LinkDel(veth))
+	prbe.linkChanged(ns, netlink.LinkUpdate{Header: unix.NlMsghdr{Type: unix.RTM_DELLINK}, Link: link})
+	require.Empty(t, ns.links)
+}
+
+func TestFollowNamespace(t *testing.T) {
+	runtime.LockOSThread()
+	orig, err := netns.Get()
+	require.NoError(t, err)
+	handle, err := netns.NewNamed("cstest")
+	require.NoError(t, netns.Set(orig))
+	runtime.UnlockOSThread()
+	if err != nil {
+		t.Skipf("Creating a network namespace: %v", err)
+	}
+	defer netns.DeleteNamed("cstest")
+	defer handle.Close()
+
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cstest0"}, PeerName: "cstest1"}
+	require.NoError(t, netlink.LinkAdd(veth))
+	defer netlink.LinkDel(veth)
+
+	peer, err := netlink.LinkByName("cstest1")
+	require.NoError(t, err)
+	require.NoError(t, netlink.LinkSetNsFd(peer, int(handle)))
+
+	prbe := newTestProbe(t)
+	defer prbe.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	require.NoError(t, prbe.follow("cstest", Interfaces{"cstest*"}, make(chan linkUpdate), done))
+
+	ns := prbe.namespaces["cstest"]
+	require.Len(t, ns.links, 1)
+	for _, a := range ns.links {
+		require.Equal(t, "cstest1", a.iface.Attrs().Name)
+	}
+
+	host, err := openNamespace("")
+	require.NoError(t, err)
+	defer host.Close()
+	require.NotZero(t, ns.cookie)
+	require.NotEqual(t, host.cookie, ns.cookie)
+}
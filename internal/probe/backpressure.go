@@ -0,0 +1,316 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+)
+
+// Backpressure configures the controller reacting to the event consumer falling behind.
+// When the pipe drops events or fills above HighFill, the sampling rate is doubled up to
+// MaxFactor times, then the probe switches to in-kernel aggregation. After Recover checks
+// below LowFill without drops, it steps back one level
+type Backpressure struct {
+	Interval  time.Duration // how often the pipe is checked, 0 disables the controller
+	HighFill  float64       // ringbuf fill fraction considered overloaded
+	LowFill   float64       // ringbuf fill fraction considered calm
+	MaxFactor uint32        // largest sampling rate multiplier before aggregating in the kernel
+	Recover   int           // calm checks needed before stepping back
+}
+
+var DefaultBackpressure = Backpressure{
+	Interval:  time.Second,
+	HighFill:  0.5,
+	LowFill:   0.1,
+	MaxFactor: 64,
+	Recover:   5,
+}
+
+// PipeStats are the counters of the events sent by the probe to user space
+type PipeStats struct {
+	Events       uint64
+	Drops        uint64
+	DropsPerCPU  []uint64
+	Fill         float64 // ringbuf fill fraction, the highest seen by the CPUs sending events
+	SampleFactor uint32  // multiplier of the sampling rate set by the controller
+	Aggregating  bool    // flows are counted in the kernel instead of sending events
+}
+
+// Attachment describes the hooks running the probe on an interface
+type Attachment struct {
+	Namespace string // "host" for the agent's one
+	Interface string
+	Index     int
+	Ingress   string // xdp-native, xdp-generic, tcx or tc
+	Egress    string // tcx or tc
+}
+
+// Monitor publishes the pipe stats and the attachments of the running probe
+type Monitor struct {
+	mu          sync.Mutex
+	stats       PipeStats
+	attachments []Attachment
+}
+
+func (m *Monitor) Stats() PipeStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.DropsPerCPU = append([]uint64(nil), m.stats.DropsPerCPU...)
+	return stats
+}
+
+func (m *Monitor) Attachments() []Attachment {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Attachment(nil), m.attachments...)
+}
+
+func (m *Monitor) setAttachments(attachments []Attachment) {
+	m.mu.Lock()
+	m.attachments = attachments
+	m.mu.Unlock()
+}
+
+func (m *Monitor) set(stats PipeStats) {
+	m.mu.Lock()
+	m.stats = stats
+	m.mu.Unlock()
+}
+
+// pipeStats mirrors struct pipe_stats of connstats.c
+type pipeStats struct {
+	Events    uint64
+	Drops     uint64
+	AvailData uint64
+}
+
+// pipeControl mirrors struct pipe_control of connstats.c
+type pipeControl struct {
+	SampleFactor uint32
+	Aggregate    uint8
+	_            [3]byte
+}
+
+// controller decides the pipe control from the drops and fill level of each check
+type controller struct {
+	cfg       Backpressure
+	factor    uint32
+	aggregate bool
+	calm      int
+	drops     uint64
+}
+
+func newController(cfg Backpressure) *controller {
+	return &controller{cfg: cfg, factor: 1}
+}
+
+// update takes the total drops and the fill level, and reports whether the control changed
+func (c *controller) update(drops uint64, fill float64) bool {
+	dropped := drops > c.drops
+	c.drops = drops
+
+	if dropped || fill >= c.cfg.HighFill {
+		c.calm = 0
+		switch {
+		case c.aggregate:
+			return false
+		case c.factor < c.cfg.MaxFactor:
+			c.factor = min(c.factor*2, c.cfg.MaxFactor)
+		default:
+			c.aggregate = true
+		}
+		return true
+	}
+
+	if fill > c.cfg.LowFill {
+		c.calm = 0
+		return false
+	}
+
+	if c.calm++; c.calm < c.cfg.Recover {
+		return false
+	}
+	c.calm = 0
+
+	switch {
+	case c.aggregate:
+		c.aggregate = false
+	case c.factor > 1:
+		c.factor /= 2
+	default:
+		return false
+	}
+	return true
+}
+
+// pipeUpdate carries the changes of the pipe control to the loop updating the flow table
+type pipeUpdate struct {
+	sampleFactor uint32
+	aggs         []packet.Aggregate
+}
+
+// ntohs converts a port read from a kernel struct to host order
+func ntohs(port uint16) uint16 {
+	buf := make([]byte, 2)
+	binary.NativeEndian.PutUint16(buf, port)
+	return binary.BigEndian.Uint16(buf)
+}
+
+// drainAggregates removes the flows counted in the kernel and returns their counters
+func (p *probe) drainAggregates() ([]packet.Aggregate, error) {
+	m := p.bpfObjects.Flowstracker
+
+	var key probeFlowId
+	var value probeFlowMetrics
+	var keys []probeFlowId
+
+	iter := m.Iterate()
+	for iter.Next(&key, &value) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	aggs := make([]packet.Aggregate, 0, len(keys))
+	for _, key := range keys {
+		err := m.LookupAndDelete(&key, &value)
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			if err = m.Lookup(&key, &value); err == nil {
+				err = m.Delete(&key)
+			}
+		}
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			continue
+		}
+		if err != nil {
+			return aggs, err
+		}
+
+		aggs = append(aggs, packet.Aggregate{
+			Packet: packet.Packet{
+				SrcIP:     netip.AddrFrom16(key.L_ip.In6U.U6Addr8),
+				DstIP:     netip.AddrFrom16(key.R_ip.In6U.U6Addr8),
+				SrcPort:   ntohs(key.L_port),
+				DstPort:   ntohs(key.R_port),
+				Protocol:  key.Protocol,
+				TimeStamp: value.TsCurrent,
+				Outbound:  true,
+				Ifindex:   key.Ifindex,
+				Netns:     key.Netns,
+			},
+			PacketsIn:  uint64(value.PacketsIn),
+			PacketsOut: uint64(value.PacketsOut),
+			BytesIn:    value.BytesIn,
+			BytesOut:   value.BytesOut,
+			TsStart:    value.TsStart,
+		})
+	}
+
+	return aggs, nil
+}
+
+// runBackpressure checks the pipe every interval, publishing its stats and adjusting the
+// pipe control. The new sampling factor and the flows aggregated in the kernel are sent
+// to updates
+func (p *probe) runBackpressure(ctx context.Context, cfg Backpressure, monitor *Monitor, updates chan<- pipeUpdate) {
+	statsMap := p.bpfObjects.PipeStats
+	controlMap := p.bpfObjects.PipeControl
+
+	size := float64(p.pipeSize())
+	ctrl := newController(cfg)
+	lastEvents := []uint64{}
+
+	// Without the controller the stats are still published
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultBackpressure.Interval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var perCPU []pipeStats
+		if err := statsMap.Lookup(uint32(0), &perCPU); err != nil {
+			log.Printf("Failed reading pipe stats: %v", err)
+			continue
+		}
+
+		stats := PipeStats{DropsPerCPU: make([]uint64, len(perCPU))}
+		for cpu, s := range perCPU {
+			stats.Events += s.Events
+			stats.Drops += s.Drops
+			stats.DropsPerCPU[cpu] = s.Drops
+
+			// The fill level of a CPU is only current if it sent events since the last check
+			if cpu < len(lastEvents) && s.Events == lastEvents[cpu] {
+				continue
+			}
+			if fill := float64(s.AvailData) / size; fill > stats.Fill {
+				stats.Fill = fill
+			}
+		}
+		lastEvents = lastEvents[:0]
+		for _, s := range perCPU {
+			lastEvents = append(lastEvents, s.Events)
+		}
+
+		update := pipeUpdate{}
+		wasAggregating := ctrl.aggregate
+
+		if cfg.Interval > 0 && ctrl.update(stats.Drops, stats.Fill) {
+			control := pipeControl{SampleFactor: ctrl.factor}
+			if ctrl.aggregate {
+				control.Aggregate = 1
+			}
+			if err := controlMap.Put(uint32(0), control); err != nil {
+				log.Printf("Failed updating pipe control: %v", err)
+			} else {
+				log.Printf("Pipe %.0f%% full, %d drops: sampling factor %d, aggregating %v", stats.Fill*100, stats.Drops, ctrl.factor, ctrl.aggregate)
+				update.sampleFactor = ctrl.factor
+			}
+		}
+		stats.SampleFactor = ctrl.factor
+		stats.Aggregating = ctrl.aggregate
+
+		if monitor != nil {
+			monitor.set(stats)
+		}
+
+		// Drain once more after leaving aggregation, for the flows counted meanwhile
+		if ctrl.aggregate || wasAggregating {
+			drained, err := p.drainAggregates()
+			if err != nil {
+				log.Printf("Failed draining aggregated flows: %v", err)
+			}
+			update.aggs = drained
+		}
+
+		if update.sampleFactor == 0 && len(update.aggs) == 0 {
+			continue
+		}
+
+		select {
+		case updates <- update:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package probe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerEscalatesAndRecovers(t *testing.T) {
+	ctrl := newController(Backpressure{HighFill: 0.5, LowFill: 0.1, MaxFactor: 4, Recover: 2})
+
+	// Drops double the sampling factor up to MaxFactor, then switch to aggregation
+	require.True(t, ctrl.update(10, 0))
+	require.Equal(t, uint32(2), ctrl.factor)
+	require.True(t, ctrl.update(10, 0.9))
+	require.Equal(t, uint32(4), ctrl.factor)
+	require.True(t, ctrl.update(20, 0))
+	require.True(t, ctrl.aggregate)
+	require.False(t, ctrl.update(30, 0))
+
+	// Between the watermarks nothing changes
+	require.False(t, ctrl.update(30, 0.3))
+	require.False(t, ctrl.update(30, 0.3))
+
+	// Each level is left after Recover calm checks
+	require.False(t, ctrl.update(30, 0))
+	require.True(t, ctrl.update(30, 0))
+	require.False(t, ctrl.aggregate)
+	require.Equal(t, uint32(4), ctrl.factor)
+
+	for _, factor := range []uint32{2, 1} {
+		require.False(t, ctrl.update(30, 0))
+		require.True(t, ctrl.update(30, 0))
+		require.Equal(t, factor, ctrl.factor)
+	}
+	require.False(t, ctrl.update(30, 0))
+	require.False(t, ctrl.update(30, 0))
+}
@@ -0,0 +1,113 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"net/netip"
+
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/gabspt/ConnectionStats/internal/capture"
+)
+
+// captureFilter mirrors struct capture_filter of connstats.c. Ports are kept in network order
+type captureFilter struct {
+	Enabled    uint8
+	Protocol   uint8
+	PortA      [2]byte
+	PortB      [2]byte
+	PrefixlenA uint8
+	PrefixlenB uint8
+	AddrA      [16]byte
+	AddrB      [16]byte
+	Snaplen    uint32
+}
+
+// captureHeaderLen is the offset of the packet bytes in struct capture_event
+const captureHeaderLen = 17
+
+// mappedPrefix converts a prefix to the IPv4-mapped form the probe matches addresses in
+func mappedPrefix(prefix netip.Prefix) ([16]byte, uint8) {
+	if !prefix.IsValid() {
+		return [16]byte{}, 0
+	}
+
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	return prefix.Addr().As16(), uint8(bits)
+}
+
+// SetFilter installs the capture filter in the probe, implementing capture.Backend
+func (p *probe) SetFilter(filter *capture.Filter, snapLen uint32) error {
+	value := captureFilter{}
+	if filter != nil {
+		value.Enabled = 1
+		value.Protocol = filter.Proto
+		binary.BigEndian.PutUint16(value.PortA[:], filter.APort)
+		binary.BigEndian.PutUint16(value.PortB[:], filter.BPort)
+		value.AddrA, value.PrefixlenA = mappedPrefix(filter.ANet)
+		value.AddrB, value.PrefixlenB = mappedPrefix(filter.BNet)
+		value.Snaplen = snapLen
+	}
+
+	return p.bpfObjects.CaptureFilter.Put(uint32(0), value)
+}
+
+// startCapture reads the packets copied by the probe and hands them to the capture manager
+func (p *probe) startCapture(manager *capture.Manager) (*ringbuf.Reader, error) {
+	// With the perf transport capture_pipe is a placeholder
+	if p.transport == TransportPerf {
+		return nil, capture.ErrUnavailable
+	}
+
+	reader, err := ringbuf.NewReader(p.bpfObjects.CapturePipe)
+	if err != nil {
+		log.Println("Failed creating capture reader")
+		return nil, err
+	}
+
+	go func() {
+		for {
+			record, err := reader.Read()
+			if err != nil {
+				if !errors.Is(err, ringbuf.ErrClosed) {
+					log.Printf("Failed reading captured packet: %v", err)
+				}
+				return
+			}
+
+			frame, ok := unmarshalFrame(record.RawSample)
+			if !ok {
+				continue
+			}
+			manager.Deliver(frame)
+		}
+	}()
+
+	manager.SetBackend(p)
+
+	return reader, nil
+}
+
+func unmarshalFrame(in []byte) (capture.Frame, bool) {
+	if len(in) < captureHeaderLen {
+		return capture.Frame{}, false
+	}
+
+	caplen := binary.LittleEndian.Uint32(in[12:16])
+	if int(caplen) > len(in)-captureHeaderLen {
+		return capture.Frame{}, false
+	}
+
+	data := make([]byte, caplen)
+	copy(data, in[captureHeaderLen:])
+
+	return capture.Frame{
+		Timestamp: binary.LittleEndian.Uint64(in[0:8]),
+		Length:    binary.LittleEndian.Uint32(in[8:12]),
+		Outbound:  in[16] == 1,
+		Data:      data,
+	}, true
+}
@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/capture"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+// upTestVeth brings the cstest0 veth up, cstest0 as 10.250.0.1/30 with a static neighbour
+// for 10.250.0.2 so the packets sent to it leave through cstest0
+func upTestVeth(t *testing.T, link netlink.Link) {
+	peer, err := netlink.LinkByName("cstest1")
+	require.NoError(t, err)
+	require.NoError(t, netlink.LinkSetUp(peer))
+
+	addr, err := netlink.ParseAddr("10.250.0.1/30")
+	require.NoError(t, err)
+	require.NoError(t, netlink.AddrAdd(link, addr))
+	require.NoError(t, netlink.LinkSetUp(link))
+
+	require.NoError(t, netlink.NeighAdd(&netlink.Neigh{
+		LinkIndex:    link.Attrs().Index,
+		Family:       unix.AF_INET,
+		State:        netlink.NUD_PERMANENT,
+		IP:           net.ParseIP("10.250.0.2"),
+		HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02},
+	}))
+}
+
+func TestCapture(t *testing.T) {
+	link := newTestVeth(t)
+	upTestVeth(t, link)
+
+	prbe := &probe{namespaces: make(map[string]*namespace), attachMode: AttachNetlink}
+	require.NoError(t, prbe.loadObjects())
+	defer prbe.Close()
+	ns, err := openNamespace("")
+	require.NoError(t, err)
+	prbe.namespaces[""] = ns
+	require.NoError(t, prbe.attach(ns, link))
+
+	manager := capture.NewManager()
+	reader, err := prbe.startCapture(manager)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	session, err := manager.Start(capture.Request{
+		Filter:  capture.Filter{Proto: unix.IPPROTO_UDP, BNet: netip.MustParsePrefix("10.250.0.2/32"), BPort: 9999},
+		SnapLen: 64,
+	})
+	require.NoError(t, err)
+	defer session.Stop()
+
+	conn, err := net.Dial("udp4", "10.250.0.2:9999")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(make([]byte, 200))
+	require.NoError(t, err)
+
+	select {
+	case frame := <-session.Frames:
+		require.True(t, frame.Outbound)
+		require.Equal(t, uint32(14+20+8+200), frame.Length)
+		require.Len(t, frame.Data, 64)
+	case <-time.After(2 * time.Second):
+		t.Fatal("No packet captured")
+	}
+}
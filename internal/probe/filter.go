@@ -0,0 +1,264 @@
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"sort"
+
+	"github.com/cilium/ebpf"
+	"github.com/gabspt/ConnectionStats/internal/filter"
+)
+
+// filterWords is the number of words of a set of rules
+const filterWords = filter.MaxRules / 64
+
+// filterRules mirrors struct filter_rules of connstats.c, bit i standing for the rule of id i
+type filterRules [filterWords]uint64
+
+func (r *filterRules) add(id uint32) {
+	r[id/64] |= 1 << (id % 64)
+}
+
+// filterSet mirrors struct filter_set
+type filterSet struct {
+	Rules      uint32
+	AllowRules uint32
+	Allow      filterRules
+	Deny       filterRules
+}
+
+// filterNetKey mirrors struct filter_net_key, the key of the filter_nets LPM trie
+type filterNetKey struct {
+	Prefixlen uint32
+	Set       uint8
+	Local     uint8
+	Addr      [16]byte
+	_         [2]byte
+}
+
+// filterPortKey mirrors struct filter_port_key. The port is kept in network order
+type filterPortKey struct {
+	Prefixlen uint32
+	Set       uint8
+	Local     uint8
+	Port      [2]byte
+}
+
+// filterKeyBits is the length of the set and local fields, leading the LPM keys
+const filterKeyBits = 16
+
+// filterSide selects the conditions of a rule on one end of the packets
+type filterSide struct {
+	local uint8
+	net   func(filter.Rule) netip.Prefix
+	ports func(filter.Rule) filter.PortRange
+}
+
+var filterSides = []filterSide{
+	{1, func(r filter.Rule) netip.Prefix { return r.LocalNet }, func(r filter.Rule) filter.PortRange { return r.LocalPorts }},
+	{0, func(r filter.Rule) netip.Prefix { return r.RemoteNet }, func(r filter.Rule) filter.PortRange { return r.RemotePorts }},
+}
+
+// netEntries returns the rules of each prefix of a side. The longest prefix of an address
+// holds every rule whose prefix contains it, and the rules without one
+func netEntries(rules []filter.Rule, set uint8, side filterSide) map[filterNetKey]filterRules {
+	prefixes := map[netip.Prefix]bool{{}: true}
+	for _, rule := range rules {
+		prefixes[side.net(rule)] = true
+	}
+
+	entries := make(map[filterNetKey]filterRules, len(prefixes))
+	for prefix := range prefixes {
+		addr, addrBits := mappedPrefix(prefix)
+		key := filterNetKey{Prefixlen: filterKeyBits + uint32(addrBits), Set: set, Local: side.local, Addr: addr}
+
+		var matched filterRules
+		for _, rule := range rules {
+			net := side.net(rule)
+			if !net.IsValid() {
+				matched.add(rule.ID)
+				continue
+			}
+			netAddr, netBits := mappedPrefix(net)
+			if netBits <= addrBits && netip.PrefixFrom(netip.AddrFrom16(netAddr), int(netBits)).Contains(netip.AddrFrom16(addr)) {
+				matched.add(rule.ID)
+			}
+		}
+		entries[key] = matched
+	}
+
+	return entries
+}
+
+// portEntries returns the rules of the ports of a side. The ports are split where the rules
+// matching them change, then each run of ports is split in aligned blocks, an entry each
+func portEntries(rules []filter.Rule, set uint8, side filterSide) map[filterPortKey]filterRules {
+	bounds := map[uint32]bool{0: true}
+	for _, rule := range rules {
+		if ports := side.ports(rule); ports.IsSet() {
+			bounds[uint32(ports.Min)] = true
+			bounds[uint32(ports.Max)+1] = true
+		}
+	}
+	starts := make([]uint32, 0, len(bounds))
+	for start := range bounds {
+		if start <= 0xffff {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	entries := make(map[filterPortKey]filterRules)
+	for i, start := range starts {
+		end := uint32(0x10000)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		var matched filterRules
+		for _, rule := range rules {
+			ports := side.ports(rule)
+			if !ports.IsSet() || uint32(ports.Min) <= start && start <= uint32(ports.Max) {
+				matched.add(rule.ID)
+			}
+		}
+
+		for port := start; port < end; {
+			size := uint32(1)
+			for port%(size*2) == 0 && port+size*2 <= end {
+				size *= 2
+			}
+			key := filterPortKey{
+				Prefixlen: filterKeyBits + 16 - uint32(bits.TrailingZeros32(size)),
+				Set:       set,
+				Local:     side.local,
+			}
+			binary.BigEndian.PutUint16(key.Port[:], uint16(port))
+			entries[key] = matched
+			port += size
+		}
+	}
+
+	return entries
+}
+
+// syncMap makes the entries of m belonging to set equal to entries
+func syncMap[K comparable, V any](m *ebpf.Map, entries map[K]V, inSet func(K) bool) error {
+	var stale []K
+	var key K
+	var value V
+
+	iter := m.Iterate()
+	for iter.Next(&key, &value) {
+		if _, ok := entries[key]; !ok && inSet(key) {
+			stale = append(stale, key)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range stale {
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("deleting from %v: %w", m, err)
+		}
+	}
+
+	for key, value := range entries {
+		if err := m.Put(key, value); err != nil {
+			return fmt.Errorf("updating %v: %w", m, err)
+		}
+	}
+
+	return nil
+}
+
+// SetRules installs the filter rules in the probe, implementing filter.Backend. The rules
+// are written to the set not in use, which the probe switches to once it is complete, so
+// the rules in use are left as they were on failure
+func (p *probe) SetRules(rules []filter.Rule) error {
+	objs := p.bpfObjects
+
+	var config uint32
+	if err := objs.FilterConfig.Lookup(uint32(0), &config); err != nil {
+		return err
+	}
+	set := uint8(config&1) ^ 1
+
+	value := filterSet{Rules: uint32(len(rules))}
+	nets := make(map[filterNetKey]filterRules)
+	ports := make(map[filterPortKey]filterRules)
+	protocols := make([]filterRules, 256)
+
+	for _, rule := range rules {
+		if rule.ID >= filter.MaxRules {
+			return fmt.Errorf("rule %d: %w", rule.ID, filter.ErrTooManyRules)
+		}
+		switch rule.Action {
+		case filter.Allow:
+			value.AllowRules++
+			value.Allow.add(rule.ID)
+		case filter.Deny:
+			value.Deny.add(rule.ID)
+		}
+
+		if protos := rule.Protocols(); protos != nil {
+			for _, proto := range protos {
+				protocols[proto].add(rule.ID)
+			}
+		} else {
+			for proto := range protocols {
+				protocols[proto].add(rule.ID)
+			}
+		}
+	}
+
+	for _, side := range filterSides {
+		for key, matched := range netEntries(rules, set, side) {
+			nets[key] = matched
+		}
+		for key, matched := range portEntries(rules, set, side) {
+			ports[key] = matched
+		}
+	}
+	if len(ports) > int(objs.FilterPorts.MaxEntries()/2) {
+		return fmt.Errorf("%d port blocks: %w", len(ports), filter.ErrTooManyRules)
+	}
+
+	if err := syncMap(objs.FilterNets, nets, func(key filterNetKey) bool { return key.Set == set }); err != nil {
+		return err
+	}
+	if err := syncMap(objs.FilterPorts, ports, func(key filterPortKey) bool { return key.Set == set }); err != nil {
+		return err
+	}
+	for proto, matched := range protocols {
+		if err := objs.FilterProtocols.Put(uint32(set)*256+uint32(proto), matched); err != nil {
+			return err
+		}
+	}
+	if err := objs.FilterSets.Put(uint32(set), value); err != nil {
+		return err
+	}
+
+	return objs.FilterConfig.Put(uint32(0), uint32(set))
+}
+
+// Hits sums the per CPU hit counters of the rules, implementing filter.Backend
+func (p *probe) Hits() ([filter.MaxRules]uint64, error) {
+	hits := [filter.MaxRules]uint64{}
+
+	var perCPU []uint64
+	for id := uint32(0); id < filter.MaxRules; id++ {
+		if err := p.bpfObjects.FilterHits.Lookup(id, &perCPU); err != nil {
+			return hits, err
+		}
+		for _, n := range perCPU {
+			hits[id] += n
+		}
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,149 @@
+package probe
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/filter"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustRule(t *testing.T, id uint32, s string) filter.Rule {
+	rule, err := filter.ParseRule(s)
+	require.NoError(t, err)
+	rule.ID = id
+	return rule
+}
+
+func TestPortEntries(t *testing.T) {
+	rules := []filter.Rule{
+		mustRule(t, 0, "deny local port 80-443/tcp"),
+		mustRule(t, 1, "allow local port 443-8080"),
+		mustRule(t, 2, "allow remote port 53"),
+		mustRule(t, 3, "deny local 10.0.0.0/8"),
+	}
+	entries := portEntries(rules, 1, filterSides[0])
+	require.Less(t, len(entries), 64)
+
+	// The blocks cover each port once, with the rules whose local range holds it
+	for port := 0; port <= 0xffff; port++ {
+		var found []filterRules
+		for key, matched := range entries {
+			require.Equal(t, uint8(1), key.Set)
+			require.Equal(t, uint8(1), key.Local)
+			bits := int(key.Prefixlen) - filterKeyBits
+			start := int(binary.BigEndian.Uint16(key.Port[:]))
+			if port>>(16-bits) == start>>(16-bits) {
+				found = append(found, matched)
+			}
+		}
+		require.Len(t, found, 1, port)
+
+		var want filterRules
+		want.add(2)
+		want.add(3)
+		if port >= 80 && port <= 443 {
+			want.add(0)
+		}
+		if port >= 443 && port <= 8080 {
+			want.add(1)
+		}
+		require.Equal(t, want, found[0], port)
+	}
+}
+
+func TestNetEntries(t *testing.T) {
+	rules := []filter.Rule{
+		mustRule(t, 0, "deny remote 10.0.0.0/8"),
+		mustRule(t, 1, "allow remote 10.1.0.0/16 remote port 53/udp"),
+		mustRule(t, 2, "allow local port 22"),
+	}
+	entries := netEntries(rules, 0, filterSides[1])
+	require.Len(t, entries, 3)
+
+	lookup := func(prefix string) filterRules {
+		addr, bits := mappedPrefix(netip.MustParsePrefix(prefix))
+		return entries[filterNetKey{Prefixlen: filterKeyBits + uint32(bits), Addr: addr}]
+	}
+	var want filterRules
+	want.add(2)
+	require.Equal(t, want, entries[filterNetKey{Prefixlen: filterKeyBits}])
+	want.add(0)
+	require.Equal(t, want, lookup("10.0.0.0/8"))
+	want.add(1)
+	require.Equal(t, want, lookup("10.1.0.0/16"))
+}
+
+func TestFilter(t *testing.T) {
+	link := newTestVeth(t)
+	upTestVeth(t, link)
+
+	prbe := &probe{namespaces: make(map[string]*namespace), attachMode: AttachNetlink}
+	require.NoError(t, prbe.loadObjects())
+	defer prbe.Close()
+	ns, err := openNamespace("")
+	require.NoError(t, err)
+	prbe.namespaces[""] = ns
+	require.NoError(t, prbe.attach(ns, link))
+
+	before, err := prbe.Hits()
+	require.NoError(t, err)
+
+	require.NoError(t, prbe.SetRules([]filter.Rule{
+		mustRule(t, 0, "deny remote 10.250.0.2 remote port 9000-9999/udp"),
+		mustRule(t, 1, "deny remote 10.250.0.0/30 remote port 9999/tcp"),
+		mustRule(t, 2, "allow local 10.250.0.0/24"),
+	}))
+
+	send := func(port int) {
+		conn, err := net.Dial("udp4", net.JoinHostPort("10.250.0.2", strconv.Itoa(port)))
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write(make([]byte, 200))
+		require.NoError(t, err)
+	}
+	// The hits are counted as the packets leave, after the sends return
+	waitHits := func(id int, want uint64) [filter.MaxRules]uint64 {
+		var hits [filter.MaxRules]uint64
+		require.Eventually(t, func() bool {
+			hits, err = prbe.Hits()
+			require.NoError(t, err)
+			return hits[id]-before[id] == want
+		}, 2*time.Second, 50*time.Millisecond)
+		return hits
+	}
+	send(9999)
+	send(9999)
+	send(10000)
+
+	hits := waitHits(2, 3)
+	require.Equal(t, uint64(2), hits[0]-before[0])
+	require.Equal(t, uint64(0), hits[1]-before[1])
+	require.Equal(t, uint64(3), hits[2]-before[2])
+
+	// The rules in use are replaced at once, the set written last is switched to
+	var config uint32
+	require.NoError(t, prbe.bpfObjects.FilterConfig.Lookup(uint32(0), &config))
+	require.NoError(t, prbe.SetRules([]filter.Rule{mustRule(t, 0, "deny proto udp")}))
+	var switched uint32
+	require.NoError(t, prbe.bpfObjects.FilterConfig.Lookup(uint32(0), &switched))
+	require.Equal(t, config^1, switched)
+
+	send(10000)
+	waitHits(0, 3)
+
+	var set filterSet
+	require.NoError(t, prbe.bpfObjects.FilterSets.Lookup(switched, &set))
+	require.Equal(t, uint32(1), set.Rules)
+	require.Equal(t, uint32(0), set.AllowRules)
+
+	var protocols filterRules
+	require.NoError(t, prbe.bpfObjects.FilterProtocols.Lookup(switched*256+unix.IPPROTO_TCP, &protocols))
+	require.Zero(t, protocols[0])
+}
@@ -0,0 +1,48 @@
+package probe
+
+import (
+	"errors"
+	"log"
+
+	"github.com/cilium/ebpf"
+	"github.com/vishvananda/netlink"
+)
+
+// l3EncapTypes are the link types, as named by netlink, whose packets start with the IP
+// header. WireGuard and tun devices are "none"
+var l3EncapTypes = map[string]bool{
+	"none":       true,
+	"ipip":       true,
+	"tunnel6":    true,
+	"sit":        true,
+	"gre":        true,
+	"ppp":        true,
+	"unknown519": true, // ARPHRD_RAWIP
+	"unknown823": true, // ARPHRD_IP6GRE
+}
+
+// hasL2Header reports whether the packets of the link start with an Ethernet header. The
+// loopback device has a zeroed one
+func hasL2Header(iface netlink.Link) bool {
+	return !l3EncapTypes[iface.Attrs().EncapType]
+}
+
+// setL3Device tells the programs the link has no L2 header. Indexes are only unique in a
+// namespace, so the link is keyed by the cookie of its namespace when the packets carry it
+func (p *probe) setL3Device(ns *namespace, iface netlink.Link, l3 bool) error {
+	devices := p.bpfObjects.L3Devices
+
+	key := probeL3Device{Ifindex: uint32(iface.Attrs().Index)}
+	if p.netnsCookies {
+		key.Netns = ns.cookie
+	}
+	if !l3 {
+		if err := devices.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	log.Printf("Interface %v has no L2 header, type %v", iface.Attrs().Name, iface.Attrs().EncapType)
+	return devices.Update(key, uint8(1), ebpf.UpdateAny)
+}
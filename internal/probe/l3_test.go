@@ -0,0 +1,40 @@
+package probe
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/vishvananda/netlink"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestL3Devices(t *testing.T) {
+	wg := &netlink.GenericLink{LinkAttrs: netlink.LinkAttrs{Name: "wg0", Index: 7, EncapType: "none"}, LinkType: "wireguard"}
+	eth := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0", Index: 2, EncapType: "ether"}}
+	lo := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "lo", Index: 1, EncapType: "loopback"}}
+
+	require.False(t, hasL2Header(wg))
+	require.True(t, hasL2Header(eth))
+	require.True(t, hasL2Header(lo))
+
+	devices, err := ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Hash, KeySize: 16, ValueSize: 1, MaxEntries: 8})
+	require.NoError(t, err)
+	defer devices.Close()
+	prbe := &probe{bpfObjects: &probeObjects{probeMaps: probeMaps{L3Devices: devices}}, netnsCookies: true}
+	host := &namespace{cookie: 1}
+	other := &namespace{cookie: 2}
+
+	require.NoError(t, prbe.setL3Device(host, wg, true))
+	require.NoError(t, prbe.setL3Device(host, eth, false))
+
+	var value uint8
+	require.NoError(t, devices.Lookup(probeL3Device{Netns: 1, Ifindex: 7}, &value))
+	require.ErrorIs(t, devices.Lookup(probeL3Device{Netns: 1, Ifindex: 2}, &value), ebpf.ErrKeyNotExist)
+
+	// The same index in another namespace is a different link
+	require.NoError(t, prbe.setL3Device(other, wg, true))
+	require.NoError(t, prbe.setL3Device(host, wg, false))
+	require.ErrorIs(t, devices.Lookup(probeL3Device{Netns: 1, Ifindex: 7}, &value), ebpf.ErrKeyNotExist)
+	require.NoError(t, devices.Lookup(probeL3Device{Netns: 2, Ifindex: 7}, &value))
+}
@@ -0,0 +1,161 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// namespace is a network namespace the probe attaches to interfaces in. The agent's own
+// namespace has an empty name
+type namespace struct {
+	name   string
+	handle netns.NsHandle
+	nl     *netlink.Handle
+	cookie uint64 // netns cookie recorded on the packets seen in the namespace, 0 when unknown
+	ifaces Interfaces
+	links  map[int]*attachment // by interface index
+}
+
+// openNamespace opens a namespace by the name given to it by "ip netns", or by the PID of a
+// process in it as pid:1234. An empty name opens the agent's namespace
+func openNamespace(name string) (*namespace, error) {
+	var handle netns.NsHandle
+	var err error
+
+	switch pid, ok := strings.CutPrefix(name, "pid:"); {
+	case name == "":
+		handle, err = netns.Get()
+	case ok:
+		n, perr := strconv.Atoi(pid)
+		if perr != nil {
+			return nil, fmt.Errorf("invalid namespace %q", name)
+		}
+		handle, err = netns.GetFromPid(n)
+	default:
+		handle, err = netns.GetFromName(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening network namespace %q: %w", name, err)
+	}
+
+	nl, err := netlink.NewHandleAt(handle, unix.NETLINK_ROUTE)
+	if err != nil {
+		handle.Close()
+		return nil, err
+	}
+
+	ns := &namespace{name: name, handle: handle, nl: nl, links: make(map[int]*attachment)}
+
+	if ns.cookie, err = ns.netnsCookie(); err != nil {
+		log.Printf("Failed getting the cookie of network namespace %v: %v", ns, err)
+	}
+
+	return ns, nil
+}
+
+func (ns *namespace) String() string {
+	if ns.name == "" {
+		return "host"
+	}
+	return ns.name
+}
+
+func (ns *namespace) Close() {
+	ns.nl.Delete()
+	ns.handle.Close()
+}
+
+// do runs f on a thread switched to the namespace, for the calls acting on the namespace of
+// the caller
+func (ns *namespace) do(f func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := netns.Get()
+	if err != nil {
+		return err
+	}
+	defer orig.Close()
+
+	if err := netns.Set(ns.handle); err != nil {
+		return err
+	}
+	ferr := f()
+	if err := netns.Set(orig); err != nil {
+		// The thread is left locked, so the runtime discards it
+		runtime.LockOSThread()
+		log.Printf("Failed returning to the agent's network namespace: %v", err)
+	}
+
+	return ferr
+}
+
+// netnsCookie returns the cookie the kernel identifies the namespace with, read from a socket
+// created in it
+func (ns *namespace) netnsCookie() (uint64, error) {
+	var fd int
+	err := ns.do(func() error {
+		var err error
+		fd, err = unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+
+	var cookie uint64
+	size := uint32(unsafe.Sizeof(cookie))
+	_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_SOCKET, unix.SO_NETNS_COOKIE,
+		uintptr(unsafe.Pointer(&cookie)), uintptr(unsafe.Pointer(&size)), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return cookie, nil
+}
+
+// HostNetns returns the netns cookie of the agent's namespace, the one recorded on the
+// packets of its interfaces
+func HostNetns() (uint64, error) {
+	ns, err := openNamespace("")
+	if err != nil {
+		return 0, err
+	}
+	defer ns.Close()
+
+	return ns.netnsCookie()
+}
+
+// setNetnsCookies rewrites record_netns when tc programs can read the netns cookie of the
+// packets, and reports whether they do
+func setNetnsCookies(spec *ebpf.CollectionSpec) bool {
+	if err := features.HaveProgramHelper(ebpf.SchedCLS, asm.FnGetNetnsCookie); err != nil {
+		if !errors.Is(err, ebpf.ErrNotSupported) {
+			log.Printf("Failed probing netns cookie support: %v", err)
+		}
+		return false
+	}
+
+	err := spec.RewriteConstants(map[string]interface{}{
+		"record_netns": uint8(1),
+	})
+	if err != nil {
+		log.Printf("Failed enabling netns cookies: %v", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package probe
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// transientMaps carry the events of a run, and are not pinned for the next one
+var transientMaps = map[string]bool{
+	"pipe": true, "perf_pipe": true, "pipes": true,
+	"capture_pipe": true, "capture_filter": true,
+}
+
+// mapsPinPath returns the directory the maps are pinned in, empty when not pinning
+func (p *probe) mapsPinPath() string {
+	if p.pinPath == "" {
+		return ""
+	}
+	return filepath.Join(p.pinPath, "maps")
+}
+
+// pinMaps marks the maps of the spec keeping state across runs to be pinned by name, so a
+// new run reuses those pinned by the previous one
+func (p *probe) pinMaps(spec *ebpf.CollectionSpec) error {
+	dir := p.mapsPinPath()
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	for name, m := range spec.Maps {
+		if transientMaps[name] || strings.HasPrefix(name, ".") {
+			continue
+		}
+		m.Pinning = ebpf.PinByName
+	}
+
+	return nil
+}
+
+// unpinMaps removes the pinned maps of the spec loaded with the programs, when one of them
+// no longer matches the probe
+func (p *probe) unpinMaps(spec *ebpf.CollectionSpec) error {
+	for name, m := range spec.Maps {
+		if m.Pinning != ebpf.PinByName || p.maps[name] != nil {
+			continue
+		}
+		err := os.Remove(filepath.Join(p.mapsPinPath(), name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
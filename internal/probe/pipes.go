@@ -0,0 +1,284 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// Transport selects the kind of map the events are sent through
+type Transport uint8
+
+const (
+	TransportAuto    Transport = iota // ringbuf when the kernel supports it, perf event array otherwise
+	TransportRingbuf                  // BPF_MAP_TYPE_RINGBUF, from Linux 5.8
+	TransportPerf                     // BPF_MAP_TYPE_PERF_EVENT_ARRAY
+)
+
+var transports = map[string]Transport{"auto": TransportAuto, "ringbuf": TransportRingbuf, "perf": TransportPerf}
+
+func ParseTransport(s string) (Transport, error) {
+	transport, ok := transports[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown transport %q, expected auto, ringbuf or perf", s)
+	}
+	return transport, nil
+}
+
+func (t Transport) String() string {
+	for name, transport := range transports {
+		if transport == t {
+			return name
+		}
+	}
+	return fmt.Sprintf("Transport(%d)", uint8(t))
+}
+
+// Values of pipe_transport in connstats.c
+const (
+	pipeRingbuf   uint8 = 0
+	pipePerfEvent uint8 = 1
+)
+
+// perfPages is the size of the perf buffer of each CPU, in pages
+const perfPages = 256
+
+// setTransport chooses the event transport, probing the kernel for ringbufs unless one was
+// asked for, and rewrites pipe_transport. With the perf transport, the ringbuf maps of the
+// spec are replaced by placeholders the kernel can create, as the code using them is skipped
+func (p *probe) setTransport(spec *ebpf.CollectionSpec) (Transport, error) {
+	transport := p.transport
+	if transport == TransportAuto {
+		transport = TransportRingbuf
+
+		err := features.HaveMapType(ebpf.RingBuf)
+		if errors.Is(err, ebpf.ErrNotSupported) {
+			log.Printf("Ringbufs not supported by the kernel, sending events through a perf event array")
+			transport = TransportPerf
+		} else if err != nil {
+			log.Printf("Failed probing ringbuf support, assuming it: %v", err)
+		}
+	}
+
+	if transport == TransportRingbuf {
+		return transport, nil
+	}
+
+	err := spec.RewriteConstants(map[string]interface{}{
+		"pipe_transport": pipePerfEvent,
+	})
+	if err != nil {
+		return transport, err
+	}
+
+	for _, m := range spec.Maps {
+		if m.Type == ebpf.RingBuf {
+			*m = ringbufPlaceholder(m.Name)
+		}
+		if m.InnerMap != nil && m.InnerMap.Type == ebpf.RingBuf {
+			inner := ringbufPlaceholder(m.InnerMap.Name)
+			m.InnerMap = &inner
+		}
+	}
+
+	if p.pipeLayout == PipePerCPU {
+		log.Printf("Per CPU pipes need ringbufs, the perf event array is read from every CPU")
+		p.pipeLayout = PipeShared
+	}
+
+	return transport, nil
+}
+
+// ringbufPlaceholder stands for a ringbuf map on kernels without them
+func ringbufPlaceholder(name string) ebpf.MapSpec {
+	return ebpf.MapSpec{Name: name, Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1}
+}
+
+// PipeLayout selects how the probe sends the events to user space
+type PipeLayout uint8
+
+// Values of pipe_layout in connstats.c
+const (
+	PipeShared PipeLayout = iota // every CPU writes to a single ringbuf
+	PipePerCPU                   // each CPU writes to its own ringbuf, read in parallel
+)
+
+var pipeLayouts = map[string]PipeLayout{"shared": PipeShared, "percpu": PipePerCPU}
+
+func ParsePipeLayout(s string) (PipeLayout, error) {
+	layout, ok := pipeLayouts[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown pipe layout %q, expected shared or percpu", s)
+	}
+	return layout, nil
+}
+
+// possibleCPUs returns the number of CPUs the kernel may bring online, which sizes the per
+// CPU maps
+func possibleCPUs() (int, error) {
+	buf, err := os.ReadFile("/sys/devices/system/cpu/possible")
+	if err != nil {
+		return 0, err
+	}
+	return parseCPURange(strings.TrimSpace(string(buf)))
+}
+
+// parseCPURange parses a CPU list such as "0-7" or "0", returning the highest CPU plus one
+func parseCPURange(s string) (int, error) {
+	last := s
+	if i := strings.LastIndexAny(s, ",-"); i >= 0 {
+		last = s[i+1:]
+	}
+	n, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU list %q", s)
+	}
+	return n + 1, nil
+}
+
+// setPipeLayout creates a ringbuf per CPU in the pipes map when the layout asks for it, and
+// rewrites pipe_layout. It returns the layout in effect
+func (p *probe) setPipeLayout(spec *ebpf.CollectionSpec) (PipeLayout, error) {
+	if p.pipeLayout != PipePerCPU {
+		return PipeShared, nil
+	}
+
+	pipesSpec := spec.Maps["pipes"]
+
+	cpus, err := possibleCPUs()
+	if err != nil {
+		return PipeShared, err
+	}
+	if cpus > int(pipesSpec.MaxEntries) {
+		return PipeShared, fmt.Errorf("%d CPUs, the pipes map holds %d", cpus, pipesSpec.MaxEntries)
+	}
+
+	pipes, err := ebpf.NewMap(pipesSpec)
+	if err != nil {
+		return PipeShared, err
+	}
+
+	for cpu := 0; cpu < cpus; cpu++ {
+		ring, err := ebpf.NewMap(pipesSpec.InnerMap)
+		if err == nil {
+			err = pipes.Put(uint32(cpu), ring)
+		}
+		if err != nil {
+			pipes.Close()
+			p.closeRings()
+			return PipeShared, err
+		}
+		p.rings = append(p.rings, ring)
+	}
+
+	err = spec.RewriteConstants(map[string]interface{}{
+		"pipe_layout": uint8(PipePerCPU),
+	})
+	if err != nil {
+		pipes.Close()
+		p.closeRings()
+		return PipeShared, err
+	}
+
+	p.maps["pipes"] = pipes
+	log.Printf("Using a pipe per CPU for %d CPUs", cpus)
+
+	return PipePerCPU, nil
+}
+
+func (p *probe) closeRings() {
+	for _, ring := range p.rings {
+		if err := ring.Close(); err != nil {
+			log.Printf("Failed closing pipe: %v", err)
+		}
+	}
+	p.rings = nil
+}
+
+// pipeSize returns the size of the buffers the events are written to
+func (p *probe) pipeSize() uint32 {
+	if p.transport == TransportPerf {
+		return uint32(os.Getpagesize() * perfPages)
+	}
+	if len(p.rings) > 0 {
+		return p.rings[0].MaxEntries()
+	}
+	return p.bpfObjects.Pipe.MaxEntries()
+}
+
+// eventReader reads the events of a transport
+type eventReader interface {
+	// read returns the next event, os.ErrDeadlineExceeded once the deadline passed, or
+	// os.ErrClosed after Close. The event is only valid until the next call
+	read() ([]byte, error)
+	SetDeadline(t time.Time)
+	Close() error
+}
+
+type ringbufReader struct {
+	*ringbuf.Reader
+	record ringbuf.Record
+}
+
+func (r *ringbufReader) read() ([]byte, error) {
+	if err := r.ReadInto(&r.record); err != nil {
+		return nil, err
+	}
+	return r.record.RawSample, nil
+}
+
+type perfReader struct {
+	*perf.Reader
+	record perf.Record
+}
+
+func (r *perfReader) read() ([]byte, error) {
+	for {
+		if err := r.ReadInto(&r.record); err != nil {
+			return nil, err
+		}
+		// The lost samples were already counted as drops by the probe
+		if r.record.LostSamples == 0 {
+			return r.record.RawSample, nil
+		}
+	}
+}
+
+// newReaders returns a reader for each buffer the events are written to
+func (p *probe) newReaders() ([]eventReader, error) {
+	if p.transport == TransportPerf {
+		reader, err := perf.NewReader(p.bpfObjects.PerfPipe, os.Getpagesize()*perfPages)
+		if err != nil {
+			return nil, err
+		}
+		return []eventReader{&perfReader{Reader: reader}}, nil
+	}
+
+	pipes := p.rings
+	if len(pipes) == 0 {
+		pipes = []*ebpf.Map{p.bpfObjects.Pipe}
+	}
+
+	readers := make([]eventReader, 0, len(pipes))
+	for _, pipe := range pipes {
+		reader, err := ringbuf.NewReader(pipe)
+		if err != nil {
+			for _, r := range readers {
+				r.Close()
+			}
+			return nil, err
+		}
+		readers = append(readers, &ringbufReader{Reader: reader})
+	}
+
+	return readers, nil
+}
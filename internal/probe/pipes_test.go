@@ -0,0 +1,54 @@
+package probe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPURange(t *testing.T) {
+	for list, want := range map[string]int{"0": 1, "0-7": 8, "0-3,8-11": 12} {
+		n, err := parseCPURange(list)
+		require.NoError(t, err)
+		require.Equal(t, want, n, list)
+	}
+
+	_, err := parseCPURange("")
+	require.Error(t, err)
+}
+
+func TestPerCPUPipes(t *testing.T) {
+	prbe := probe{pipeLayout: PipePerCPU}
+	err := prbe.loadObjects()
+	require.NoError(t, err)
+	defer prbe.bpfObjects.Close()
+	defer prbe.closeMaps()
+
+	readers, err := prbe.newReaders()
+	require.NoError(t, err)
+	defer func() {
+		for _, r := range readers {
+			r.Close()
+		}
+	}()
+
+	require.Equal(t, PipePerCPU, prbe.pipeLayout)
+	cpus, err := possibleCPUs()
+	require.NoError(t, err)
+	require.Len(t, readers, cpus)
+}
+
+func TestPerfTransport(t *testing.T) {
+	prbe := probe{transport: TransportPerf, pipeLayout: PipePerCPU}
+	err := prbe.loadObjects()
+	require.NoError(t, err)
+	defer prbe.bpfObjects.Close()
+	defer prbe.closeMaps()
+
+	require.Equal(t, PipeShared, prbe.pipeLayout)
+
+	readers, err := prbe.newReaders()
+	require.NoError(t, err)
+	require.Len(t, readers, 1)
+	readers[0].Close()
+}
@@ -2,15 +2,19 @@ package probe
 
 import (
 	"context"
+	"errors"
 	"log"
+	"os"
+	"sync"
+	"time"
 
-	//"sync"
-
-	//"github.com/cilium/ebpf/perf"
-	"github.com/cilium/ebpf/ringbuf"
+	"github.com/cilium/ebpf"
 	"github.com/gabspt/ConnectionStats/clsact"
+	"github.com/gabspt/ConnectionStats/internal/capture"
+	"github.com/gabspt/ConnectionStats/internal/filter"
 	"github.com/gabspt/ConnectionStats/internal/flowtable"
 	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 )
@@ -21,12 +25,42 @@ import (
 
 const tenMegaBytes = 1024 * 1024 * 10 // 10MB
 
+// Config holds the optional features of the probe
+type Config struct {
+	Capture *capture.Manager // runs the on-demand packet captures
+	Filter  *filter.Manager  // rules selecting the monitored traffic
+
+	Attach  AttachMode  // how the programs are attached to the interfaces
+	Ingress IngressMode // hook of the ingress program
+	PinPath string      // bpffs directory the links and maps are pinned in, none when empty
+
+	MaxFlows   int        // entries of the kernel flow map, its default size when 0
+	PipeLayout PipeLayout // how the events are sent to user space
+	Transport  Transport  // kind of map the events are sent through
+
+	SampleRate uint32     // send 1 in SampleRate packets to user space, 0 or 1 send all
+	SampleMode SampleMode // how the sampled packets are chosen
+
+	Backpressure Backpressure // reaction to the event consumer falling behind
+	Monitor      *Monitor     // receives the pipe stats
+}
+
 type probe struct {
-	iface      netlink.Link
-	handle     *netlink.Handle
-	qdisc      *clsact.ClsAct
-	bpfObjects *probeObjects
-	filters    []*netlink.BpfFilter
+	namespaces   map[string]*namespace // by name, the agent's one is ""
+	bpfObjects   *probeObjects
+	maps         map[string]*ebpf.Map
+	sampleRate   uint32
+	sampleMode   SampleMode
+	maxFlows     int
+	pipeLayout   PipeLayout
+	transport    Transport
+	netnsCookies bool        // packets carry the cookie of their network namespace
+	rings        []*ebpf.Map // ringbufs of the per CPU pipes
+	attachMode   AttachMode
+	pinPath      string
+	ingress      IngressMode
+	xdp          *ebpf.Program // runs the ingress program on XDP, nil when not used
+	monitor      *Monitor
 }
 
 func setRlimit() error {
@@ -41,47 +75,148 @@ func setRlimit() error {
 func (p *probe) loadObjects() error {
 	log.Printf("Loading probe object to kernel")
 
+	spec, err := loadProbe()
+	if err != nil {
+		return err
+	}
+
+	if p.sampleRate, err = p.setSampling(spec); err != nil {
+		log.Printf("Failed setting the sampling rate: %v", err)
+		return err
+	}
+	// XDP programs cannot read the netns cookie, and both directions must hash alike
+	if p.ingress == IngressTC {
+		p.netnsCookies = setNetnsCookies(spec)
+	}
+
+	if flows, ok := spec.Maps["flowstracker"]; ok && p.maxFlows > 0 {
+		flows.MaxEntries = uint32(p.maxFlows)
+	}
+
+	p.maps = make(map[string]*ebpf.Map)
+
+	if err := p.pinMaps(spec); err != nil {
+		log.Printf("Failed creating the map pin path: %v", err)
+		return err
+	}
+
+	if p.transport, err = p.setTransport(spec); err != nil {
+		log.Printf("Failed setting the event transport: %v", err)
+		return err
+	}
+
+	if p.pipeLayout, err = p.setPipeLayout(spec); err != nil {
+		log.Printf("Failed creating the per CPU pipes: %v", err)
+		return err
+	}
+
 	objs := probeObjects{}
 
-	if err := loadProbeObjects(&objs, nil); err != nil {
+	opts := &ebpf.CollectionOptions{
+		Maps:            ebpf.MapOptions{PinPath: p.mapsPinPath()},
+		MapReplacements: p.maps,
+	}
+	err = spec.LoadAndAssign(&objs, opts)
+	if errors.Is(err, ebpf.ErrMapIncompatible) {
+		log.Printf("Pinned maps do not match the probe, replacing them: %v", err)
+		if err = p.unpinMaps(spec); err == nil {
+			err = spec.LoadAndAssign(&objs, opts)
+		}
+	}
+	if err != nil {
+		p.closeMaps()
 		return err
 	}
 
 	p.bpfObjects = &objs
+	if p.ingress != IngressTC {
+		p.xdp = objs.ConnstatsXdp
+	}
 
 	return nil
 }
 
-func (p *probe) createQdisc() error {
-	log.Printf("Creating qdisc")
+func (p *probe) closeMaps() {
+	for name, m := range p.maps {
+		if err := m.Close(); err != nil {
+			log.Printf("Failed closing map %v: %v", name, err)
+		}
+	}
+	p.maps = nil
+	p.closeRings()
+}
+
+func (p *probe) createQdisc(a *attachment) error {
+	log.Printf("Creating qdisc on %v", a.iface.Attrs().Name)
 
-	p.qdisc = clsact.NewClsAct(&netlink.QdiscAttrs{
-		LinkIndex: p.iface.Attrs().Index,
+	a.qdisc = clsact.NewClsAct(&netlink.QdiscAttrs{
+		LinkIndex: a.iface.Attrs().Index,
 		Handle:    netlink.MakeHandle(0xffff, 0),
 		Parent:    netlink.HANDLE_CLSACT,
 	})
 
-	if err := p.handle.QdiscAdd(p.qdisc); err != nil {
-		if err := p.handle.QdiscReplace(p.qdisc); err != nil {
+	// A clsact qdisc already there belongs to someone else, and is left in place
+	err := a.ns.nl.QdiscAdd(a.qdisc)
+	switch {
+	case errors.Is(err, unix.EEXIST):
+		log.Printf("Reusing the clsact qdisc of %v", a.iface.Attrs().Name)
+	case err != nil:
+		return err
+	default:
+		a.ownQdisc = true
+	}
+
+	return nil
+}
+
+// deleteQdisc removes the filters of the probe, then the qdisc if the probe created it and no
+// filter was added to it meanwhile
+func (p *probe) deleteQdisc(a *attachment) error {
+	for _, filter := range a.filters {
+		if err := a.ns.nl.FilterDel(filter); err != nil && !errors.Is(err, unix.ENOENT) {
+			log.Println("Failed deleting qdisc filter")
 			return err
 		}
 	}
+	a.filters = nil
 
-	return nil
+	if !a.ownQdisc {
+		return nil
+	}
+	for _, parent := range []uint32{netlink.HANDLE_MIN_INGRESS, netlink.HANDLE_MIN_EGRESS} {
+		filters, err := a.ns.nl.FilterList(a.iface, parent)
+		if err != nil {
+			return err
+		}
+		if len(filters) > 0 {
+			log.Printf("Leaving the qdisc of %v to the filters added to it", a.iface.Attrs().Name)
+			return nil
+		}
+	}
+
+	log.Printf("Removing qdisc from %v", a.iface.Attrs().Name)
+	return a.ns.nl.QdiscDel(a.qdisc)
 }
 
-func (p *probe) createFilters() error {
-	log.Printf("Creating qdisc filters")
+// filterPriority is the priority of the IPv4 filters of the probe, the IPv6 ones following it.
+// Fixed, so a filter left by a previous run is replaced rather than added again
+const filterPriority = 0x5c00
+
+func (p *probe) createFilters(a *attachment) error {
+	log.Printf("Creating qdisc filters on %v", a.iface.Attrs().Name)
 
 	addFilterin := func(attrs netlink.FilterAttrs) {
-		p.filters = append(p.filters, &netlink.BpfFilter{
+		if a.xdp { // XDP runs the ingress program
+			return
+		}
+		a.filters = append(a.filters, &netlink.BpfFilter{
 			FilterAttrs:  attrs,
 			Fd:           p.bpfObjects.probePrograms.Connstatsin.FD(),
 			DirectAction: true,
 		})
 	}
 	addFilterout := func(attrs netlink.FilterAttrs) {
-		p.filters = append(p.filters, &netlink.BpfFilter{
+		a.filters = append(a.filters, &netlink.BpfFilter{
 			FilterAttrs:  attrs,
 			Fd:           p.bpfObjects.probePrograms.Connstatsout.FD(),
 			DirectAction: true,
@@ -89,36 +224,40 @@ func (p *probe) createFilters() error {
 	}
 
 	addFilterin(netlink.FilterAttrs{
-		LinkIndex: p.iface.Attrs().Index,
+		LinkIndex: a.iface.Attrs().Index,
 		Handle:    netlink.MakeHandle(0xffff, 0),
 		Parent:    netlink.HANDLE_MIN_INGRESS,
+		Priority:  filterPriority,
 		Protocol:  unix.ETH_P_IP,
 	})
 
 	addFilterout(netlink.FilterAttrs{
-		LinkIndex: p.iface.Attrs().Index,
+		LinkIndex: a.iface.Attrs().Index,
 		Handle:    netlink.MakeHandle(0xffff, 0),
 		Parent:    netlink.HANDLE_MIN_EGRESS,
+		Priority:  filterPriority,
 		Protocol:  unix.ETH_P_IP,
 	})
 
 	addFilterin(netlink.FilterAttrs{
-		LinkIndex: p.iface.Attrs().Index,
+		LinkIndex: a.iface.Attrs().Index,
 		Handle:    netlink.MakeHandle(0xffff, 0),
 		Parent:    netlink.HANDLE_MIN_INGRESS,
+		Priority:  filterPriority + 1,
 		Protocol:  unix.ETH_P_IPV6,
 	})
 
 	addFilterout(netlink.FilterAttrs{
-		LinkIndex: p.iface.Attrs().Index,
+		LinkIndex: a.iface.Attrs().Index,
 		Handle:    netlink.MakeHandle(0xffff, 0),
 		Parent:    netlink.HANDLE_MIN_EGRESS,
+		Priority:  filterPriority + 1,
 		Protocol:  unix.ETH_P_IPV6,
 	})
 
-	for _, filter := range p.filters {
-		if err := p.handle.FilterAdd(filter); err != nil {
-			if err := p.handle.FilterReplace(filter); err != nil {
+	for _, filter := range a.filters {
+		if err := a.ns.nl.FilterAdd(filter); err != nil {
+			if err := a.ns.nl.FilterReplace(filter); err != nil {
 				return err
 			}
 		}
@@ -127,7 +266,7 @@ func (p *probe) createFilters() error {
 	return nil
 }
 
-func newProbe(iface netlink.Link) (*probe, error) {
+func newProbe(cfg Config) (*probe, error) {
 	log.Println("Creating a new probe")
 
 	if err := setRlimit(); err != nil {
@@ -135,16 +274,17 @@ func newProbe(iface netlink.Link) (*probe, error) {
 		return nil, err
 	}
 
-	handle, err := netlink.NewHandle(unix.NETLINK_ROUTE)
-
-	if err != nil {
-		log.Printf("Failed getting netlink handle: %v", err)
-		return nil, err
-	}
-
 	prbe := probe{
-		iface:  iface,
-		handle: handle,
+		namespaces: make(map[string]*namespace),
+		sampleRate: cfg.SampleRate,
+		sampleMode: cfg.SampleMode,
+		maxFlows:   cfg.MaxFlows,
+		pipeLayout: cfg.PipeLayout,
+		transport:  cfg.Transport,
+		attachMode: cfg.Attach,
+		pinPath:    cfg.PinPath,
+		ingress:    cfg.Ingress,
+		monitor:    cfg.Monitor,
 	}
 
 	if err := prbe.loadObjects(); err != nil {
@@ -152,101 +292,213 @@ func newProbe(iface netlink.Link) (*probe, error) {
 		return nil, err
 	}
 
-	if err := prbe.createQdisc(); err != nil {
-		log.Printf("Failed creating qdisc: %v", err)
-		return nil, err
-	}
-
-	if err := prbe.createFilters(); err != nil {
-		log.Printf("Failed creating qdisc filters: %v", err)
-		return nil, err
-	}
-
 	return &prbe, nil
 }
 
 func (p *probe) Close() error {
-	log.Println("Removing qdisc")
-	if err := p.handle.QdiscDel(p.qdisc); err != nil {
-		log.Println("Failed deleting qdisc")
-		return err
+	for name, ns := range p.namespaces {
+		for index, a := range ns.links {
+			if a.links != nil && p.pinPath != "" {
+				// Pinned links run the programs until the next run takes them over
+				p.closeLinks(a.links, false)
+				delete(ns.links, index)
+				continue
+			}
+			if err := p.detach(ns, index, false); err != nil {
+				return err
+			}
+		}
+		ns.Close()
+		delete(p.namespaces, name)
 	}
 
-	// log.Println("Removing qdisc filters")
-
-	// for _, filter := range p.filters {
-	// 	if err := p.handle.FilterDel(filter); err != nil {
-	// 		log.Println("Failed deleting qdisc filters")
-	// 		return err
-	// 	}
-	// }
-
-	log.Println("Deleting handle")
-	p.handle.Delete()
-
 	log.Println("Closing eBPF object")
 	if err := p.bpfObjects.Close(); err != nil {
 		log.Println("Failed closing eBPF object")
 		return err
 	}
+	p.closeMaps()
 
 	return nil
 }
 
-func Run(ctx context.Context, iface netlink.Link, ft *flowtable.FlowTable) error {
+// FlowSource feeds the packets of the monitored interfaces to the workers updating the flow table
+type FlowSource interface {
+	// Run hands the packets seen to producers of workers until ctx is done, and stops adding
+	// them before returning
+	Run(ctx context.Context, ft *flowtable.FlowTable, workers *pipeline.Pipeline) error
+}
+
+// Run tracks the flows of the packets of src in the flow table until ctx is done
+func Run(ctx context.Context, src FlowSource, ft *flowtable.FlowTable, cfg pipeline.Config) error {
 	log.Println("Starting up the probe")
 
-	probe, err := newProbe(iface)
+	workers := pipeline.New(ft, cfg)
+
+	// The prune loop is stopped and joined before returning, as Ticker.Stop leaves it blocked
+	pruneDone := make(chan struct{})
+	pruneStopped := make(chan struct{})
+	go func() {
+		defer close(pruneStopped)
+		for {
+			select {
+			case <-pruneDone:
+				return
+			case <-ft.Ticker.C:
+				ft.Prune()
+			}
+		}
+	}()
+
+	err := src.Run(ctx, ft, workers)
+	workers.Close()
+	ft.Ticker.Stop()
+	close(pruneDone)
+	<-pruneStopped
+
+	return err
+}
+
+// ebpfSource attaches the probe to the interfaces matching ifaces, following them as they
+// appear and vanish, and reads the packets seen from its pipes
+type ebpfSource struct {
+	ifaces Interfaces
+	cfg    Config
+}
+
+// NewEBPFSource returns the source attaching the eBPF programs to the interfaces
+func NewEBPFSource(ifaces Interfaces, cfg Config) FlowSource {
+	return &ebpfSource{ifaces: ifaces, cfg: cfg}
+}
+
+func (src *ebpfSource) Run(ctx context.Context, ft *flowtable.FlowTable, workers *pipeline.Pipeline) error {
+	ifaces, cfg := src.ifaces, src.cfg
+
+	if err := checkIngress(ifaces, cfg); err != nil {
+		return err
+	}
+
+	probe, err := newProbe(cfg)
 
 	if err != nil {
 		return err
 	}
 
-	pipe := probe.bpfObjects.probeMaps.Pipe
+	linkUpdates := make(chan linkUpdate)
+	linkDone := make(chan struct{})
+	defer close(linkDone)
 
-	reader, err := ringbuf.NewReader(pipe)
+	for _, name := range ifaces.Namespaces() {
+		if err := probe.follow(name, ifaces.In(name), linkUpdates, linkDone); err != nil {
+			probe.Close()
+			return err
+		}
+	}
+	if len(probe.namespaces) > 1 && !probe.netnsCookies {
+		log.Printf("Packets not tagged with their network namespace, flows of namespaces with overlapping addresses are merged")
+	}
+
+	ft.SetSampleRate(probe.sampleRate)
+
+	readers, err := probe.newReaders()
 
 	if err != nil {
-		log.Println("Failed creating perf reader")
+		log.Printf("Failed creating the pipe readers: %v", err)
+		probe.Close()
 		return err
 	}
 
-	c := make(chan []byte)
+	if cfg.Filter != nil {
+		if err := cfg.Filter.SetBackend(probe); err != nil {
+			log.Printf("Filtering disabled: %v", err)
+		}
+	}
 
-	go func() {
-		for {
-			event, err := reader.Read()
-			if err != nil {
-				log.Printf("Failed reading perf event: %v", err)
-				return
-			}
-			c <- event.RawSample //the event(network packet) is in c
+	if cfg.Capture != nil {
+		captureReader, err := probe.startCapture(cfg.Capture)
+		if err != nil {
+			log.Printf("Packet capture disabled: %v", err)
+		} else {
+			defer captureReader.Close()
 		}
-	}()
+	}
 
-	//flowtable := flowtable.NewFlowTable()
-	//connection := flowtable.NewConnection()
+	updates := make(chan pipeUpdate)
+	go probe.runBackpressure(ctx, cfg.Backpressure, cfg.Monitor, updates)
 
-	go func() {
-		for range ft.Ticker.C {
-			ft.Prune()
-		}
-	}()
+	var readersDone sync.WaitGroup
+
+	for _, reader := range readers {
+		readersDone.Add(1)
+		go func(reader eventReader, producer *pipeline.Producer) {
+			defer readersDone.Done()
+			readEvents(reader, producer)
+		}(reader, workers.NewProducer())
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			// reader.Close()
-			ft.Ticker.Stop()
+			for _, reader := range readers {
+				reader.Close()
+			}
+			readersDone.Wait()
+			if cfg.Capture != nil {
+				cfg.Capture.SetBackend(nil)
+			}
+			if cfg.Filter != nil {
+				cfg.Filter.SetBackend(nil)
+			}
 			return probe.Close()
 
-		case pkt := <-c:
-			packetAttrs, ok := packet.UnmarshalBinary(pkt)
-			if !ok {
-				log.Printf("Could not unmarshall packet: %+v", pkt)
-				continue
+		case update := <-linkUpdates:
+			probe.linkChanged(update.ns, update.LinkUpdate)
+
+		case update := <-updates:
+			if update.sampleFactor > 0 {
+				ft.SetSampleRate(probe.sampleRate * update.sampleFactor)
 			}
-			packet.CalcStats(packetAttrs, ft)
+			for _, agg := range update.aggs {
+				packet.AddAggregate(agg, ft)
+			}
+
+		}
+	}
+}
+
+// readEvents hands the packets read from the pipe to the pipeline until the reader is
+// closed. The record buffer is reused, and the partial batches are flushed once the pipe
+// is drained
+func readEvents(reader eventReader, workers *pipeline.Producer) {
+	pending := false
+
+	for {
+		sample, err := reader.read()
+
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			workers.Flush()
+			pending = false
+			reader.SetDeadline(time.Time{})
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("Failed reading perf event: %v", err)
+			}
+			return
+		}
+
+		packetAttrs, ok := packet.UnmarshalBinary(sample)
+		if !ok {
+			log.Printf("Could not unmarshall packet: %+v", sample)
+			continue
+		}
+		workers.Add(packetAttrs)
+
+		// Poll without blocking until the ringbuf is empty, then flush
+		if !pending {
+			pending = true
+			reader.SetDeadline(time.Now())
 		}
 	}
 }
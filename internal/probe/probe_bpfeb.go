@@ -1,5 +1,5 @@
 // Code generated by bpf2go; DO NOT EDIT.
-//go:build arm64be || armbe || mips || mips64 || mips64p32 || ppc64 || s390 || s390x || sparc || sparc64
+//go:build mips || mips64 || ppc64 || s390x
 
 package probe
 
@@ -12,6 +12,44 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type probeCaptureFilter struct {
+	Enabled    uint8
+	Protocol   uint8
+	PortA      uint16
+	PortB      uint16
+	PrefixlenA uint8
+	PrefixlenB uint8
+	AddrA      struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	AddrB      struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	Snaplen    uint32
+}
+
+type probeFilterConfig struct{ Set uint32 }
+
+type probeFilterNetKey struct {
+	Prefixlen uint32
+	Set       uint8
+	Local     uint8
+	Addr      [16]uint8
+	_         [2]byte
+}
+
+type probeFilterPortKey struct {
+	Prefixlen uint32
+	Set       uint8
+	Local     uint8
+	Port      uint16
+}
+
+type probeFilterRules struct{ Bits [16]uint64 }
+
+type probeFilterSet struct {
+	Rules      uint32
+	AllowRules uint32
+	Allow      probeFilterRules
+	Deny       probeFilterRules
+}
+
 type probeFlowId struct {
 	L_ip     struct{ In6U struct{ U6Addr8 [16]uint8 } }
 	R_ip     struct{ In6U struct{ U6Addr8 [16]uint8 } }
@@ -19,6 +57,9 @@ type probeFlowId struct {
 	R_port   uint16
 	Protocol uint8
 	_        [3]byte
+	Ifindex  uint32
+	_        [4]byte
+	Netns    uint64
 }
 
 type probeFlowMetrics struct {
@@ -30,6 +71,24 @@ type probeFlowMetrics struct {
 	TsCurrent  uint64
 }
 
+type probeL3Device struct {
+	Netns   uint64
+	Ifindex uint32
+	Pad     uint32
+}
+
+type probePipeControl struct {
+	SampleFactor uint32
+	Aggregate    uint8
+	_            [3]byte
+}
+
+type probePipeStats struct {
+	Events    uint64
+	Drops     uint64
+	AvailData uint64
+}
+
 // loadProbe returns the embedded CollectionSpec for probe.
 func loadProbe() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_ProbeBytes)
@@ -71,6 +130,7 @@ type probeSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type probeProgramSpecs struct {
+	ConnstatsXdp *ebpf.ProgramSpec `ebpf:"connstats_xdp"`
 	Connstatsin  *ebpf.ProgramSpec `ebpf:"connstatsin"`
 	Connstatsout *ebpf.ProgramSpec `ebpf:"connstatsout"`
 }
@@ -79,8 +139,22 @@ type probeProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type probeMapSpecs struct {
-	Flowstracker *ebpf.MapSpec `ebpf:"flowstracker"`
-	Pipe         *ebpf.MapSpec `ebpf:"pipe"`
+	CaptureFilter   *ebpf.MapSpec `ebpf:"capture_filter"`
+	CapturePipe     *ebpf.MapSpec `ebpf:"capture_pipe"`
+	FilterConfig    *ebpf.MapSpec `ebpf:"filter_config"`
+	FilterHits      *ebpf.MapSpec `ebpf:"filter_hits"`
+	FilterNets      *ebpf.MapSpec `ebpf:"filter_nets"`
+	FilterPorts     *ebpf.MapSpec `ebpf:"filter_ports"`
+	FilterProtocols *ebpf.MapSpec `ebpf:"filter_protocols"`
+	FilterSets      *ebpf.MapSpec `ebpf:"filter_sets"`
+	Flowstracker    *ebpf.MapSpec `ebpf:"flowstracker"`
+	L3Devices       *ebpf.MapSpec `ebpf:"l3_devices"`
+	PerfPipe        *ebpf.MapSpec `ebpf:"perf_pipe"`
+	Pipe            *ebpf.MapSpec `ebpf:"pipe"`
+	PipeControl     *ebpf.MapSpec `ebpf:"pipe_control"`
+	PipeStats       *ebpf.MapSpec `ebpf:"pipe_stats"`
+	Pipes           *ebpf.MapSpec `ebpf:"pipes"`
+	SampleCounter   *ebpf.MapSpec `ebpf:"sample_counter"`
 }
 
 // probeObjects contains all objects after they have been loaded into the kernel.
@@ -102,14 +176,42 @@ func (o *probeObjects) Close() error {
 //
 // It can be passed to loadProbeObjects or ebpf.CollectionSpec.LoadAndAssign.
 type probeMaps struct {
-	Flowstracker *ebpf.Map `ebpf:"flowstracker"`
-	Pipe         *ebpf.Map `ebpf:"pipe"`
+	CaptureFilter   *ebpf.Map `ebpf:"capture_filter"`
+	CapturePipe     *ebpf.Map `ebpf:"capture_pipe"`
+	FilterConfig    *ebpf.Map `ebpf:"filter_config"`
+	FilterHits      *ebpf.Map `ebpf:"filter_hits"`
+	FilterNets      *ebpf.Map `ebpf:"filter_nets"`
+	FilterPorts     *ebpf.Map `ebpf:"filter_ports"`
+	FilterProtocols *ebpf.Map `ebpf:"filter_protocols"`
+	FilterSets      *ebpf.Map `ebpf:"filter_sets"`
+	Flowstracker    *ebpf.Map `ebpf:"flowstracker"`
+	L3Devices       *ebpf.Map `ebpf:"l3_devices"`
+	PerfPipe        *ebpf.Map `ebpf:"perf_pipe"`
+	Pipe            *ebpf.Map `ebpf:"pipe"`
+	PipeControl     *ebpf.Map `ebpf:"pipe_control"`
+	PipeStats       *ebpf.Map `ebpf:"pipe_stats"`
+	Pipes           *ebpf.Map `ebpf:"pipes"`
+	SampleCounter   *ebpf.Map `ebpf:"sample_counter"`
 }
 
 func (m *probeMaps) Close() error {
 	return _ProbeClose(
+		m.CaptureFilter,
+		m.CapturePipe,
+		m.FilterConfig,
+		m.FilterHits,
+		m.FilterNets,
+		m.FilterPorts,
+		m.FilterProtocols,
+		m.FilterSets,
 		m.Flowstracker,
+		m.L3Devices,
+		m.PerfPipe,
 		m.Pipe,
+		m.PipeControl,
+		m.PipeStats,
+		m.Pipes,
+		m.SampleCounter,
 	)
 }
 
@@ -117,12 +219,14 @@ func (m *probeMaps) Close() error {
 //
 // It can be passed to loadProbeObjects or ebpf.CollectionSpec.LoadAndAssign.
 type probePrograms struct {
+	ConnstatsXdp *ebpf.Program `ebpf:"connstats_xdp"`
 	Connstatsin  *ebpf.Program `ebpf:"connstatsin"`
 	Connstatsout *ebpf.Program `ebpf:"connstatsout"`
 }
 
 func (p *probePrograms) Close() error {
 	return _ProbeClose(
+		p.ConnstatsXdp,
 		p.Connstatsin,
 		p.Connstatsout,
 	)
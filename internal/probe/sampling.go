@@ -0,0 +1,46 @@
+package probe
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cilium/ebpf"
+)
+
+// SampleMode selects the packets sent to user space when sampling
+type SampleMode uint8
+
+// Values of sample_mode in connstats.c
+const (
+	SampleCount  SampleMode = iota // every Nth packet of each CPU
+	SampleRandom                   // each packet with probability 1/N
+	SampleFlow                     // all the packets of 1 in N flows, chosen by hash
+)
+
+var sampleModes = map[string]SampleMode{"count": SampleCount, "random": SampleRandom, "flow": SampleFlow}
+
+func ParseSampleMode(s string) (SampleMode, error) {
+	mode, ok := sampleModes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown sampling mode %q, expected count, random or flow", s)
+	}
+	return mode, nil
+}
+
+// setSampling rewrites the sampling constants of the spec and returns the rate in effect
+func (p *probe) setSampling(spec *ebpf.CollectionSpec) (uint32, error) {
+	if p.sampleRate <= 1 {
+		return 1, nil
+	}
+
+	err := spec.RewriteConstants(map[string]interface{}{
+		"sample_rate": p.sampleRate,
+		"sample_mode": uint8(p.sampleMode),
+	})
+	if err != nil {
+		return 1, err
+	}
+
+	log.Printf("Sampling 1 in %d packets", p.sampleRate)
+	return p.sampleRate, nil
+}
@@ -0,0 +1,149 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+// AttachMode selects how the programs are attached to the interfaces
+type AttachMode uint8
+
+const (
+	AttachAuto    AttachMode = iota // tcx when the kernel supports it, netlink otherwise
+	AttachTCX                       // tcx bpf_links, from Linux 6.6
+	AttachNetlink                   // a clsact qdisc with bpf filters
+)
+
+var attachModes = map[string]AttachMode{"auto": AttachAuto, "tcx": AttachTCX, "netlink": AttachNetlink}
+
+func ParseAttachMode(s string) (AttachMode, error) {
+	mode, ok := attachModes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown attach mode %q, expected auto, tcx or netlink", s)
+	}
+	return mode, nil
+}
+
+// linkHook is a hook of an interface a program is linked to
+type linkHook struct {
+	name   string
+	prog   *ebpf.Program
+	attach ebpf.AttachType
+	flags  uint32 // of XDP links
+}
+
+// tcxHooks returns the tcx hooks of the attachment, the egress one only when XDP runs the
+// ingress program
+func (p *probe) tcxHooks(a *attachment) []linkHook {
+	hooks := []linkHook{{"egress", p.bpfObjects.Connstatsout, ebpf.AttachTCXEgress, 0}}
+	if !a.xdp {
+		hooks = append([]linkHook{{"ingress", p.bpfObjects.Connstatsin, ebpf.AttachTCXIngress, 0}}, hooks...)
+	}
+	return hooks
+}
+
+// attachLinks links the programs to the hooks of the interface. A link pinned by a previous
+// run is taken over and updated with the program, instead of linking it twice. On failure
+// the links already made by the call are released
+func (p *probe) attachLinks(a *attachment, hooks []linkHook) error {
+	made := len(a.links)
+	for _, hook := range hooks {
+		l, err := p.linkTo(a, hook)
+		if err != nil {
+			p.closeLinks(a.links[made:], true)
+			a.links = a.links[:made]
+			return err
+		}
+		a.links = append(a.links, l)
+	}
+	return nil
+}
+
+func (p *probe) linkTo(a *attachment, hook linkHook) (link.Link, error) {
+	pin := p.linkPin(a, hook)
+
+	if pin != "" {
+		if l, err := link.LoadPinnedLink(pin, nil); err == nil {
+			if err := l.Update(hook.prog); err == nil {
+				log.Printf("Took over the pinned %v link of %v", hook.name, a.iface.Attrs().Name)
+				return l, nil
+			}
+			// The interface of a stale link is gone
+			l.Unpin()
+			l.Close()
+		}
+	}
+
+	var l link.Link
+	err := a.ns.do(func() error {
+		var err error
+		if hook.attach == ebpf.AttachXDP {
+			l, err = link.AttachXDP(link.XDPOptions{
+				Program:   hook.prog,
+				Interface: a.iface.Attrs().Index,
+				Flags:     link.XDPAttachFlags(hook.flags),
+			})
+			return err
+		}
+		l, err = link.AttachTCX(link.TCXOptions{
+			Interface: a.iface.Attrs().Index,
+			Program:   hook.prog,
+			Attach:    hook.attach,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("linking the %v program: %w", hook.name, err)
+	}
+
+	if pin != "" {
+		if err := os.MkdirAll(filepath.Dir(pin), 0o700); err != nil {
+			l.Close()
+			return nil, err
+		}
+		if err := l.Pin(pin); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// linkPin returns where the link of the hook is pinned, empty when not pinning
+func (p *probe) linkPin(a *attachment, hook linkHook) string {
+	if p.pinPath == "" {
+		return ""
+	}
+	// bpffs names cannot have dots, found in VLAN interfaces
+	name := strings.ReplaceAll(a.iface.Attrs().Name, ".", "_")
+	return filepath.Join(p.pinPath, "links", strings.ReplaceAll(a.ns.String(), ".", "_"), name+"_"+hook.name)
+}
+
+// closeLinks releases links of an attachment. Pinned links stay attached for the next run,
+// unless unpin is set
+func (p *probe) closeLinks(links []link.Link, unpin bool) {
+	for _, l := range links {
+		if unpin && p.pinPath != "" {
+			if err := l.Unpin(); err != nil {
+				log.Printf("Failed unpinning link: %v", err)
+			}
+		}
+		if err := l.Close(); err != nil {
+			log.Printf("Failed closing link: %v", err)
+		}
+	}
+}
+
+// tcxUnsupported reports whether linking failed because the kernel has no tcx
+func tcxUnsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, ebpf.ErrNotSupported)
+}
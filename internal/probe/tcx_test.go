@@ -0,0 +1,128 @@
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAttachMode(t *testing.T) {
+	for s, mode := range map[string]AttachMode{"auto": AttachAuto, "tcx": AttachTCX, "netlink": AttachNetlink} {
+		parsed, err := ParseAttachMode(s)
+		require.NoError(t, err)
+		require.Equal(t, mode, parsed)
+	}
+	_, err := ParseAttachMode("xdp")
+	require.Error(t, err)
+}
+
+// newTestVeth creates the cstest0 veth, removed at the end of the test
+func newTestVeth(t *testing.T) netlink.Link {
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "cstest0"}, PeerName: "cstest1"}
+	require.NoError(t, netlink.LinkAdd(veth))
+	t.Cleanup(func() { netlink.LinkDel(veth) })
+
+	link, err := netlink.LinkByName("cstest0")
+	require.NoError(t, err)
+	return link
+}
+
+// mountBPFFS mounts a bpffs in a temporary directory for the test
+func mountBPFFS(t *testing.T) string {
+	dir := t.TempDir()
+	if err := unix.Mount("bpf", dir, "bpf", 0, ""); err != nil {
+		t.Skipf("Mounting bpffs: %v", err)
+	}
+	t.Cleanup(func() { unix.Unmount(dir, 0) })
+	return dir
+}
+
+func TestAttachTCX(t *testing.T) {
+	pinPath := mountBPFFS(t)
+	link := newTestVeth(t)
+	index := link.Attrs().Index
+
+	run := func() *probe {
+		prbe := &probe{namespaces: make(map[string]*namespace), attachMode: AttachTCX, pinPath: pinPath}
+		require.NoError(t, prbe.loadObjects())
+		ns, err := openNamespace("")
+		require.NoError(t, err)
+		prbe.namespaces[""] = ns
+		ns.ifaces = Interfaces{"cstest0"}
+
+		if err := prbe.attach(ns, link); err != nil {
+			prbe.Close()
+			t.Skipf("Linking with tcx: %v", err)
+		}
+		return prbe
+	}
+
+	prbe := run()
+	a := prbe.namespaces[""].links[index]
+	require.Len(t, a.links, 2)
+	require.Nil(t, a.qdisc)
+
+	pins, err := os.ReadDir(filepath.Join(pinPath, "links", "host"))
+	require.NoError(t, err)
+	require.Len(t, pins, 2)
+	_, err = os.Stat(filepath.Join(pinPath, "maps", "flowstracker"))
+	require.NoError(t, err)
+
+	// The pinned links outlive the probe, and are taken over by the next run
+	require.NoError(t, prbe.Close())
+	_, err = os.Stat(filepath.Join(pinPath, "links", "host", "cstest0_ingress"))
+	require.NoError(t, err)
+
+	prbe = run()
+	require.Len(t, prbe.namespaces[""].links[index].links, 2)
+
+	// Detaching unpins them
+	require.NoError(t, prbe.detach(prbe.namespaces[""], index, false))
+	pins, err = os.ReadDir(filepath.Join(pinPath, "links", "host"))
+	require.NoError(t, err)
+	require.Empty(t, pins)
+	require.NoError(t, prbe.Close())
+}
+
+func TestDetachLeavesOthersQdisc(t *testing.T) {
+	link := newTestVeth(t)
+
+	prbe := &probe{namespaces: make(map[string]*namespace), attachMode: AttachNetlink}
+	require.NoError(t, prbe.loadObjects())
+	defer prbe.Close()
+	ns, err := openNamespace("")
+	require.NoError(t, err)
+	prbe.namespaces[""] = ns
+
+	// A clsact qdisc created by the probe is removed with its filters
+	require.NoError(t, prbe.attach(ns, link))
+	require.True(t, ns.links[link.Attrs().Index].ownQdisc)
+	require.NoError(t, prbe.detach(ns, link.Attrs().Index, false))
+	require.False(t, hasClsact(t, link))
+
+	// One created by someone else is left in place, without the filters of the probe
+	require.NoError(t, prbe.attach(ns, link))
+	ns.links[link.Attrs().Index].ownQdisc = false
+	require.NoError(t, prbe.detach(ns, link.Attrs().Index, false))
+	require.True(t, hasClsact(t, link))
+
+	filters, err := netlink.FilterList(link, netlink.HANDLE_MIN_INGRESS)
+	require.NoError(t, err)
+	require.Empty(t, filters)
+}
+
+func hasClsact(t *testing.T, link netlink.Link) bool {
+	qdiscs, err := netlink.QdiscList(link)
+	require.NoError(t, err)
+	for _, q := range qdiscs {
+		if q.Type() == "clsact" {
+			return true
+		}
+	}
+	return false
+}
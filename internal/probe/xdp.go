@@ -0,0 +1,98 @@
+package probe
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// IngressMode selects the hook running the ingress program. Egress always runs on tc
+type IngressMode uint8
+
+const (
+	IngressTC         IngressMode = iota // the tc classifier, like egress
+	IngressXDP                           // XDP in the driver, generic XDP when the driver has no support
+	IngressXDPNative                     // XDP in the driver only
+	IngressXDPGeneric                    // XDP in the stack, after the skb is allocated
+)
+
+var ingressModes = map[string]IngressMode{
+	"tc": IngressTC, "xdp": IngressXDP, "xdp-native": IngressXDPNative, "xdp-generic": IngressXDPGeneric,
+}
+
+func ParseIngressMode(s string) (IngressMode, error) {
+	mode, ok := ingressModes[s]
+	if !ok {
+		return 0, fmt.Errorf("unknown ingress mode %q, expected tc, xdp, xdp-native or xdp-generic", s)
+	}
+	return mode, nil
+}
+
+func (m IngressMode) String() string {
+	for name, mode := range ingressModes {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("IngressMode(%d)", m)
+}
+
+// checkIngress rejects the features the XDP ingress program lacks. It cannot read the
+// netns cookie, so it only watches the agent's namespace, and it copies no packets to the
+// captures, which would miss the inbound ones
+func checkIngress(ifaces Interfaces, cfg Config) error {
+	if cfg.Ingress == IngressTC {
+		return nil
+	}
+	if cfg.Capture != nil {
+		return fmt.Errorf("packet capture needs the tc ingress program, not %v", cfg.Ingress)
+	}
+	for _, ns := range ifaces.Namespaces() {
+		if ns != "" {
+			return fmt.Errorf("interfaces of network namespace %v need the tc ingress program, not %v", ns, cfg.Ingress)
+		}
+	}
+	return nil
+}
+
+// xdpHooks returns the XDP hooks to try in turn for the ingress mode
+func (p *probe) xdpHooks() []linkHook {
+	native := linkHook{"xdp", p.xdp, ebpf.AttachXDP, unix.XDP_FLAGS_DRV_MODE}
+	generic := linkHook{"xdp", p.xdp, ebpf.AttachXDP, unix.XDP_FLAGS_SKB_MODE}
+
+	switch p.ingress {
+	case IngressXDPNative:
+		return []linkHook{native}
+	case IngressXDPGeneric:
+		return []linkHook{generic}
+	}
+	return []linkHook{native, generic}
+}
+
+// attachXDP links the XDP program to the interface, in the first mode its driver supports.
+// The packets of L3 devices are left to the tc ingress program, as XDP expects Ethernet
+func (p *probe) attachXDP(a *attachment) error {
+	if p.ingress == IngressTC || p.xdp == nil {
+		return nil
+	}
+	if !hasL2Header(a.iface) {
+		log.Printf("Interface %v has no L2 header, its ingress stays on tc", a.iface.Attrs().Name)
+		return nil
+	}
+
+	var err error
+	for _, hook := range p.xdpHooks() {
+		if err = p.attachLinks(a, []linkHook{hook}); err == nil {
+			a.xdp = true
+			a.ingress = "xdp-native"
+			if hook.flags == unix.XDP_FLAGS_SKB_MODE {
+				a.ingress = "xdp-generic"
+			}
+			return nil
+		}
+		log.Printf("Failed linking XDP to %v, flags %#x: %v", a.iface.Attrs().Name, hook.flags, err)
+	}
+	return err
+}
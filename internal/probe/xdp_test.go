@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/gabspt/ConnectionStats/internal/capture"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIngressMode(t *testing.T) {
+	for _, s := range []string{"tc", "xdp", "xdp-native", "xdp-generic"} {
+		mode, err := ParseIngressMode(s)
+		require.NoError(t, err)
+		require.Equal(t, s, mode.String())
+	}
+	_, err := ParseIngressMode("tcx")
+	require.Error(t, err)
+}
+
+func TestCheckIngress(t *testing.T) {
+	ifaces, err := ParseInterfaces("eth0,netns/ns1/veth0")
+	require.NoError(t, err)
+	captures := capture.NewManager()
+
+	require.NoError(t, checkIngress(ifaces, Config{Ingress: IngressTC, Capture: captures}))
+	require.NoError(t, checkIngress(ifaces.In(""), Config{Ingress: IngressXDP}))
+	require.Error(t, checkIngress(ifaces.In(""), Config{Ingress: IngressXDP, Capture: captures}))
+	require.Error(t, checkIngress(ifaces, Config{Ingress: IngressXDPGeneric}))
+}
+
+func TestAttachXDP(t *testing.T) {
+	link := newTestVeth(t)
+
+	monitor := &Monitor{}
+	prbe := &probe{namespaces: make(map[string]*namespace), ingress: IngressXDP, monitor: monitor}
+	require.NoError(t, prbe.loadObjects())
+	defer prbe.Close()
+
+	// The object may predate the XDP program, which only has to pass the packets here
+	if prbe.xdp == nil {
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Type:    ebpf.XDP,
+			License: "MIT",
+			Instructions: asm.Instructions{
+				asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+				asm.Return(),
+			},
+		})
+		require.NoError(t, err)
+		prbe.xdp = prog
+		prbe.ingress = IngressXDP
+	}
+
+	ns, err := openNamespace("")
+	require.NoError(t, err)
+	prbe.namespaces[""] = ns
+
+	if err := prbe.attach(ns, link); err != nil {
+		t.Skipf("Attaching with XDP: %v", err)
+	}
+
+	a := ns.links[link.Attrs().Index]
+	require.True(t, a.xdp)
+	require.Equal(t, "xdp-native", a.ingress)
+
+	attachments := monitor.Attachments()
+	require.Len(t, attachments, 1)
+	require.Equal(t, "cstest0", attachments[0].Interface)
+	require.Equal(t, a.ingress, attachments[0].Ingress)
+	require.Equal(t, a.egress, attachments[0].Egress)
+
+	require.NoError(t, prbe.detach(ns, link.Attrs().Index, false))
+	require.Empty(t, monitor.Attachments())
+}
@@ -0,0 +1,118 @@
+package record
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+)
+
+// Record is the flat representation of a flow written by the exporters.
+// Its field order and names are the on-disk schema: only append new fields at the end.
+type Record struct {
+	Hash        uint64  `parquet:"hash" json:"hash"`
+	Proto       string  `parquet:"proto,dict" json:"proto"`
+	AIp         string  `parquet:"a_ip" json:"a_ip"`
+	BIp         string  `parquet:"b_ip" json:"b_ip"`
+	APort       uint32  `parquet:"a_port" json:"a_port"`
+	BPort       uint32  `parquet:"b_port" json:"b_port"`
+	PacketsIn   uint64  `parquet:"packets_in" json:"packets_in"`
+	PacketsOut  uint64  `parquet:"packets_out" json:"packets_out"`
+	BytesIn     uint64  `parquet:"bytes_in" json:"bytes_in"`
+	BytesOut    uint64  `parquet:"bytes_out" json:"bytes_out"`
+	TsIni       int64   `parquet:"ts_ini,timestamp(nanosecond)" json:"ts_ini"`
+	TsFin       int64   `parquet:"ts_fin,timestamp(nanosecond)" json:"ts_fin"`
+	Duration    float64 `parquet:"duration" json:"duration"`
+	InPps       float64 `parquet:"in_pps" json:"in_pps"`
+	OutPps      float64 `parquet:"out_pps" json:"out_pps"`
+	InBpp       float64 `parquet:"in_bpp" json:"in_bpp"`
+	OutBpp      float64 `parquet:"out_bpp" json:"out_bpp"`
+	InBoutB     float64 `parquet:"in_b_out_b" json:"in_b_out_b"`
+	InPoutP     float64 `parquet:"in_p_out_p" json:"in_p_out_p"`
+	Final       bool    `parquet:"final" json:"final"`
+	EndReason   string  `parquet:"end_reason,dict" json:"end_reason"`
+	CommunityID string  `parquet:"community_id" json:"community_id"`
+	SampleRate  uint32  `parquet:"sample_rate" json:"sample_rate"`
+	Estimated   bool    `parquet:"estimated" json:"estimated"`
+	Ifindex     uint32  `parquet:"ifindex" json:"ifindex"`
+	Netns       uint64  `parquet:"netns" json:"netns"`
+	MidStream   bool    `parquet:"mid_stream" json:"mid_stream"`
+}
+
+// New builds a Record from a flow event. Final is only set for FlowEnd events, the
+// others are interim records of flows that are still active
+func New(ev flowtable.Event) Record {
+	conn := ev.Conn
+
+	rec := Record{
+		Hash:        conn.Hash,
+		Proto:       conn.Proto,
+		AIp:         conn.AIp.Unmap().String(),
+		BIp:         conn.BIp.Unmap().String(),
+		APort:       uint32(conn.APort),
+		BPort:       uint32(conn.BPort),
+		PacketsIn:   conn.Packets_in,
+		PacketsOut:  conn.Packets_out,
+		BytesIn:     conn.Bytes_in,
+		BytesOut:    conn.Bytes_out,
+		TsIni:       timer.ToWallClock(conn.Ts_ini).UnixNano(),
+		TsFin:       timer.ToWallClock(conn.Ts_fin).UnixNano(),
+		Final:       ev.Type == flowtable.FlowEnd,
+		EndReason:   ev.Reason,
+		CommunityID: conn.CommunityID,
+		SampleRate:  conn.SampleRate,
+		Estimated:   conn.Estimated,
+		Ifindex:     conn.Ifindex,
+		Netns:       conn.Netns,
+		MidStream:   conn.MidStream,
+	}
+
+	if conn.Ts_fin > conn.Ts_ini {
+		rec.Duration = float64(conn.Ts_fin-conn.Ts_ini) / 1000000000
+		rec.InPps = float64(conn.Packets_in) / rec.Duration
+		rec.OutPps = float64(conn.Packets_out) / rec.Duration
+	}
+	if conn.Packets_in != 0 {
+		rec.InBpp = float64(conn.Bytes_in) / float64(conn.Packets_in)
+	}
+	if conn.Packets_out != 0 {
+		rec.OutBpp = float64(conn.Bytes_out) / float64(conn.Packets_out)
+	}
+	if conn.Bytes_out != 0 {
+		rec.InBoutB = float64(conn.Bytes_in) / float64(conn.Bytes_out)
+	}
+	if conn.Packets_out != 0 {
+		rec.InPoutP = float64(conn.Packets_in) / float64(conn.Packets_out)
+	}
+
+	return rec
+}
+
+// Header returns the column names of a Record, in schema order, as used by the CSV output
+func Header() []string {
+	t := reflect.TypeOf(Record{})
+	header := make([]string, t.NumField())
+	for i := range header {
+		header[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return header
+}
+
+// Strings returns the values of rec in the same order as Header
+func (rec Record) Strings() []string {
+	v := reflect.ValueOf(rec)
+	values := make([]string, v.NumField())
+	for i := range values {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Float64:
+			values[i] = strconv.FormatFloat(field.Float(), 'f', -1, 64)
+		default:
+			values[i] = fmt.Sprint(field.Interface())
+		}
+	}
+	return values
+}
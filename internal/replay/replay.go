@@ -0,0 +1,205 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/packet"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// pcapng files start with a Section Header Block
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// pruneInterval matches the FlowTable ticker, in capture time
+const pruneInterval = uint64(time.Second * 10)
+
+type Options struct {
+	Speed     float64        // replay speed factor, 1 replays in real time and 0 as fast as possible
+	LocalNets []netip.Prefix // packets sent from these prefixes are outbound, private addresses when empty
+	Clock     *timer.Manual  // moved along the capture timestamps, the clock given to timer.SetClock
+}
+
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	LinkType() layers.LinkType
+}
+
+func newReader(r io.Reader) (packetReader, error) {
+	buf := bufio.NewReader(r)
+
+	magic, err := buf.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, pcapngMagic) {
+		return pcapgo.NewNgReader(buf, pcapgo.DefaultNgReaderOptions)
+	}
+	return pcapgo.NewReader(buf)
+}
+
+// Source feeds the packets of a pcap or pcapng file to the workers, driving the flow tracking
+// with the capture timestamps instead of the system clock
+type Source struct {
+	path string
+	opts Options
+}
+
+// NewSource returns the source replaying the capture at path
+func NewSource(path string, opts Options) *Source {
+	return &Source{path: path, opts: opts}
+}
+
+// Run replays the capture until its end or until ctx is done
+func (src *Source) Run(ctx context.Context, ft *flowtable.FlowTable, workers *pipeline.Pipeline) error {
+	path, opts := src.path, src.opts
+	if opts.Clock == nil {
+		return errors.New("replaying needs a manual clock")
+	}
+	clock := opts.Clock
+
+	log.Printf("Replaying %v", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := newReader(file)
+	if err != nil {
+		log.Println("Failed reading capture header")
+		return err
+	}
+
+	// Pruning follows the capture time, see below
+	ft.Ticker.Stop()
+
+	producer := workers.NewProducer()
+
+	isLocal := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		if len(opts.LocalNets) == 0 {
+			return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast()
+		}
+		for _, prefix := range opts.LocalNets {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var first, lastPrune uint64
+	started := time.Now()
+	count := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		data, ci, err := reader.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			log.Printf("Failed reading packet: %v", err)
+			return err
+		}
+
+		ts := uint64(ci.Timestamp.UnixNano())
+		if first == 0 {
+			first, lastPrune = ts, ts
+		}
+
+		if opts.Speed > 0 {
+			due := started.Add(time.Duration(float64(ts-first) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		if ts > clock.Now() {
+			clock.Set(ts)
+		}
+
+		pkt, ok := Decode(data, reader.LinkType(), ci)
+		if ok {
+			pkt.Outbound = isLocal(pkt.SrcIP)
+			producer.Add(pkt)
+			count++
+		}
+
+		if clock.Now()-lastPrune >= pruneInterval {
+			producer.Flush()
+			ft.Prune()
+			lastPrune = clock.Now()
+		}
+	}
+
+	log.Printf("Replay finished: %v TCP/UDP packets in %v", count, time.Since(started))
+
+	return nil
+}
+
+// Decode converts a captured frame to a Packet. Like the probe, only TCP and UDP over IP are kept,
+// addresses are IPv4-mapped IPv6 and Len is the length of the frame on the wire
+func Decode(data []byte, linkType layers.LinkType, ci gopacket.CaptureInfo) (packet.Packet, bool) {
+	var pkt packet.Packet
+
+	frame := gopacket.NewPacket(data, linkType, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+
+	switch ip := frame.NetworkLayer().(type) {
+	case *layers.IPv4:
+		src, _ := netip.AddrFromSlice(ip.SrcIP.To4())
+		dst, _ := netip.AddrFromSlice(ip.DstIP.To4())
+		pkt.SrcIP = netip.AddrFrom16(src.As16())
+		pkt.DstIP = netip.AddrFrom16(dst.As16())
+		pkt.Protocol = uint8(ip.Protocol)
+	case *layers.IPv6:
+		pkt.SrcIP, _ = netip.AddrFromSlice(ip.SrcIP.To16())
+		pkt.DstIP, _ = netip.AddrFromSlice(ip.DstIP.To16())
+		pkt.Protocol = uint8(ip.NextHeader)
+	default:
+		return pkt, false
+	}
+
+	switch l4 := frame.TransportLayer().(type) {
+	case *layers.TCP:
+		pkt.SrcPort = uint16(l4.SrcPort)
+		pkt.DstPort = uint16(l4.DstPort)
+		pkt.Syn = l4.SYN
+		pkt.Ack = l4.ACK
+		pkt.Fin = l4.FIN
+	case *layers.UDP:
+		pkt.SrcPort = uint16(l4.SrcPort)
+		pkt.DstPort = uint16(l4.DstPort)
+	default:
+		return pkt, false
+	}
+
+	pkt.TimeStamp = uint64(ci.Timestamp.UnixNano())
+	pkt.Len = uint32(ci.Length)
+
+	return pkt, true
+}
@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/pipeline"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/stretchr/testify/require"
+)
+
+type packetWriter interface {
+	WritePacket(ci gopacket.CaptureInfo, data []byte) error
+}
+
+func tcpFrame(t *testing.T, syn, ack bool) []byte {
+	buf := gopacket.NewSerializeBuffer()
+
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{5, 4, 3, 2, 1, 0},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		SrcIP:    net.IP{1, 1, 1, 1},
+		DstIP:    net.IP{2, 2, 2, 2},
+		Protocol: layers.IPProtocolTCP,
+	}
+	tcp := &layers.TCP{
+		SrcPort: 123,
+		DstPort: 456,
+		SYN:     syn,
+		ACK:     ack,
+	}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip))
+
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, eth, ip, tcp))
+
+	return buf.Bytes()
+}
+
+func writeCapture(t *testing.T, w packetWriter) {
+	start := time.Unix(1700000000, 0)
+	frames := [][]byte{tcpFrame(t, true, false), tcpFrame(t, true, true), tcpFrame(t, false, true)}
+
+	for i, frame := range frames {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     start.Add(time.Duration(i) * time.Millisecond),
+			CaptureLength: len(frame),
+			Length:        len(frame),
+		}
+		require.NoError(t, w.WritePacket(ci, frame))
+	}
+}
+
+func replay(t *testing.T, path string, opts Options) *flowtable.FlowTable {
+	opts.Clock = &timer.Manual{}
+	timer.SetClock(opts.Clock)
+	defer timer.SetClock(timer.Monotonic{})
+
+	ft := flowtable.NewFlowTable()
+	workers := pipeline.New(ft, pipeline.Config{})
+	require.NoError(t, NewSource(path, opts).Run(context.Background(), ft, workers))
+	workers.Close()
+
+	conns := ft.GetConnList()
+	require.Len(t, conns, 1)
+
+	return ft
+}
+
+func TestReplayPcap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	w := pcapgo.NewWriter(f)
+	require.NoError(t, w.WriteFileHeader(65535, layers.LinkTypeEthernet))
+	writeCapture(t, w)
+	require.NoError(t, f.Close())
+
+	conn := replay(t, path, Options{}).GetConnList()[0]
+	require.Equal(t, netip.MustParseAddr("::ffff:1.1.1.1"), conn.AIp)
+	require.Equal(t, uint16(456), conn.BPort)
+	require.Equal(t, uint64(3), conn.Packets_in)
+	require.Equal(t, uint64(time.Millisecond*2), conn.Ts_fin-conn.Ts_ini)
+}
+
+func TestReplayPcapng(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	w, err := pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
+	require.NoError(t, err)
+	writeCapture(t, w)
+	require.NoError(t, w.Flush())
+	require.NoError(t, f.Close())
+
+	opts := Options{LocalNets: []netip.Prefix{netip.MustParsePrefix("1.1.1.0/24")}}
+
+	conn := replay(t, path, opts).GetConnList()[0]
+	require.True(t, conn.Outbound)
+	require.Equal(t, uint64(3), conn.Packets_out)
+}
@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"log"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+)
+
+// Log prints the start and the end of every flow to the standard logger
+type Log struct{}
+
+func (Log) Name() string {
+	return "log"
+}
+
+func (Log) Write(ev flowtable.Event) error {
+	conn := ev.Conn
+
+	switch ev.Type {
+	case flowtable.FlowStart:
+		log.Printf("New connection (%v) (%v) Flow | A: %v:%v B: %v:%v",
+			conn.Proto,
+			conn.Hash,
+			conn.AIp.Unmap(),
+			conn.APort,
+			conn.BIp.Unmap(),
+			conn.BPort,
+		)
+	case flowtable.FlowEnd:
+		log.Printf("Connection ended, %v (%v) (%v) Flow | A: %v:%v B: %v:%v | in: %v pkts %v B | out: %v pkts %v B",
+			ev.Reason,
+			conn.Proto,
+			conn.Hash,
+			conn.AIp.Unmap(),
+			conn.APort,
+			conn.BIp.Unmap(),
+			conn.BPort,
+			conn.Packets_in,
+			conn.Bytes_in,
+			conn.Packets_out,
+			conn.Bytes_out,
+		)
+	}
+
+	return nil
+}
+
+func (Log) Close() error {
+	return nil
+}
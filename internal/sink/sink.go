@@ -0,0 +1,165 @@
+package sink
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+)
+
+// Sink receives the flow lifecycle events emitted by the FlowTable. Write is only called
+// from the sink's own goroutine, so implementations don't need to be safe for concurrent use
+type Sink interface {
+	Name() string
+	Write(ev flowtable.Event) error
+	Close() error
+}
+
+type Config struct {
+	QueueSize  int           // events buffered for the sink before new ones are dropped
+	MaxRetries int           // times a failed Write is retried before the event is discarded
+	RetryDelay time.Duration // delay before the first retry, doubled on every attempt
+}
+
+// DefaultConfig is used for the sinks added without a Config
+var DefaultConfig = Config{
+	QueueSize:  4096,
+	MaxRetries: 3,
+	RetryDelay: time.Millisecond * 100,
+}
+
+// Stats are the delivery counters of a sink
+type Stats struct {
+	Name    string
+	Queued  int
+	Sent    uint64
+	Dropped uint64 // queue was full
+	Failed  uint64 // Write kept failing after all the retries
+}
+
+type output struct {
+	sink    Sink
+	cfg     Config
+	queue   chan flowtable.Event
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+// Registry fans the flow events out to several sinks. Every sink has its own bounded
+// queue and goroutine, so a slow sink never blocks the packet path or the other sinks
+type Registry struct {
+	outputs []*output
+	wg      sync.WaitGroup
+	mu      sync.RWMutex // held by Emit while queueing, and by Close to close the queues
+	closed  bool
+}
+
+// NewRegistry Constructs a new Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add registers s and starts delivering events to it. Sinks must be added before the probe starts
+func (r *Registry) Add(s Sink, cfg ...Config) {
+	out := &output{sink: s, cfg: DefaultConfig}
+	if len(cfg) > 0 {
+		out.cfg = cfg[0]
+	}
+	out.queue = make(chan flowtable.Event, out.cfg.QueueSize)
+
+	r.outputs = append(r.outputs, out)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for ev := range out.queue {
+			out.write(ev)
+		}
+	}()
+}
+
+func (out *output) write(ev flowtable.Event) {
+	delay := out.cfg.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := out.sink.Write(ev)
+		if err == nil {
+			out.sent.Add(1)
+			return
+		}
+		if attempt >= out.cfg.MaxRetries {
+			log.Printf("Sink %v failed writing event: %v", out.sink.Name(), err)
+			out.failed.Add(1)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Emit queues ev on every sink without blocking. It is meant to be registered with FlowTable.OnEvent.
+// Events emitted after Close are dropped
+func (r *Registry) Emit(ev flowtable.Event) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return
+	}
+	for _, out := range r.outputs {
+		select {
+		case out.queue <- ev:
+		default:
+			out.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns the delivery counters of every sink
+func (r *Registry) Stats() []Stats {
+	stats := make([]Stats, 0, len(r.outputs))
+	for _, out := range r.outputs {
+		stats = append(stats, Stats{
+			Name:    out.sink.Name(),
+			Queued:  len(out.queue),
+			Sent:    out.sent.Load(),
+			Dropped: out.dropped.Load(),
+			Failed:  out.failed.Load(),
+		})
+	}
+	return stats
+}
+
+// Close delivers the queued events and closes every sink
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	for _, out := range r.outputs {
+		close(out.queue)
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+
+	var firstErr error
+	for _, out := range r.outputs {
+		if err := out.sink.Close(); err != nil {
+			log.Printf("Failed closing sink %v: %v", out.sink.Name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	for _, stats := range r.Stats() {
+		log.Printf("Sink %v: sent %v, dropped %v, failed %v", stats.Name, stats.Sent, stats.Dropped, stats.Failed)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	block   chan struct{}
+	fails   int
+	events  []flowtable.Event
+	written int
+}
+
+func (s *fakeSink) Name() string {
+	return "fake"
+}
+
+func (s *fakeSink) Write(ev flowtable.Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.written++
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("write failed")
+	}
+	s.events = append(s.events, ev)
+
+	return nil
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+func TestRegistryFanOut(t *testing.T) {
+	a := &fakeSink{}
+	b := &fakeSink{fails: 2}
+
+	registry := NewRegistry()
+	registry.Add(a)
+	registry.Add(b, Config{QueueSize: 8, MaxRetries: 2, RetryDelay: time.Millisecond})
+
+	registry.Emit(flowtable.Event{Type: flowtable.FlowStart})
+	registry.Emit(flowtable.Event{Type: flowtable.FlowEnd, Reason: flowtable.EndIdle})
+	require.NoError(t, registry.Close())
+
+	require.Len(t, a.events, 2)
+	require.Len(t, b.events, 2)
+	require.Equal(t, 4, b.written)
+	require.Equal(t, flowtable.EndIdle, b.events[1].Reason)
+}
+
+func TestRegistryDropsWhenFull(t *testing.T) {
+	s := &fakeSink{block: make(chan struct{})}
+
+	registry := NewRegistry()
+	registry.Add(s, Config{QueueSize: 1})
+
+	// The first event is held by the blocked Write, the second fills the queue
+	registry.Emit(flowtable.Event{})
+	require.Eventually(t, func() bool { return registry.Stats()[0].Queued == 0 }, time.Second, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		registry.Emit(flowtable.Event{})
+	}
+	close(s.block)
+	require.NoError(t, registry.Close())
+
+	stats := registry.Stats()[0]
+	require.Equal(t, uint64(2), stats.Sent)
+	require.Equal(t, uint64(2), stats.Dropped)
+	require.Equal(t, uint64(0), stats.Failed)
+}
+
+func TestRegistryEmitAfterClose(t *testing.T) {
+	s := &fakeSink{}
+	registry := NewRegistry()
+	registry.Add(s)
+
+	// An event emitted while closing is either delivered or dropped, never sent on a closed queue
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			registry.Emit(flowtable.Event{})
+		}
+	}()
+	require.NoError(t, registry.Close())
+	<-done
+
+	registry.Emit(flowtable.Event{})
+	require.NoError(t, registry.Close())
+	require.Equal(t, uint64(len(s.events)), registry.Stats()[0].Sent)
+}
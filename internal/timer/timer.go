@@ -13,6 +13,64 @@ static unsigned long long get_nsecs(void) {
 */
 import "C"
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 func GetNanosecSinceBoot() uint64 {
 	return uint64(C.get_nsecs())
 }
+
+// Clock is the time source of the flow tracking. Now must use the same time base as the packet timestamps
+type Clock interface {
+	Now() uint64
+	WallClock(ns uint64) time.Time
+}
+
+// Monotonic is the clock of live captures, whose timestamps come from bpf_ktime_get_ns
+type Monotonic struct{}
+
+func (Monotonic) Now() uint64 {
+	return GetNanosecSinceBoot()
+}
+
+func (Monotonic) WallClock(ns uint64) time.Time {
+	now := GetNanosecSinceBoot()
+	return time.Now().Add(-time.Duration(int64(now) - int64(ns)))
+}
+
+// Manual is a clock driven by its owner, used when replaying captures. Its timestamps are Unix nanoseconds
+type Manual struct {
+	now atomic.Uint64
+}
+
+func (c *Manual) Now() uint64 {
+	return c.now.Load()
+}
+
+// Set moves the clock to ns
+func (c *Manual) Set(ns uint64) {
+	c.now.Store(ns)
+}
+
+func (c *Manual) WallClock(ns uint64) time.Time {
+	return time.Unix(0, int64(ns))
+}
+
+var clock Clock = Monotonic{}
+
+// SetClock replaces the clock used by Now and ToWallClock. It must be called before the probe starts
+func SetClock(c Clock) {
+	clock = c
+}
+
+// Now returns the current time of the clock in use
+func Now() uint64 {
+	return clock.Now()
+}
+
+// ToWallClock converts a packet timestamp to wall clock time
+func ToWallClock(ns uint64) time.Time {
+	return clock.WallClock(ns)
+}
@@ -0,0 +1,268 @@
+package zeek
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+	"github.com/gabspt/ConnectionStats/internal/timer"
+)
+
+const (
+	unset = "-"
+	empty = "(empty)"
+)
+
+var fields = []string{
+	"ts", "uid", "id.orig_h", "id.orig_p", "id.resp_h", "id.resp_p", "proto", "service",
+	"duration", "orig_bytes", "resp_bytes", "conn_state", "local_orig", "local_resp",
+	"missed_bytes", "history", "orig_pkts", "orig_ip_bytes", "resp_pkts", "resp_ip_bytes",
+	"tunnel_parents", "community_id",
+}
+
+var types = []string{
+	"time", "string", "addr", "port", "addr", "port", "enum", "string",
+	"interval", "count", "count", "string", "bool", "bool",
+	"count", "string", "count", "count", "count", "count",
+	"set[string]", "string",
+}
+
+// Conn is an entry of the Zeek conn.log. Connection counters are taken from the IP layer,
+// so orig_bytes and resp_bytes (payload bytes) are left unset
+type Conn struct {
+	Ts          time.Time
+	Uid         string
+	OrigH       string
+	OrigP       uint16
+	RespH       string
+	RespP       uint16
+	Proto       string
+	Duration    float64
+	ConnState   string
+	LocalOrig   bool
+	LocalResp   bool
+	History     string
+	OrigPkts    uint64
+	OrigIPBytes uint64
+	RespPkts    uint64
+	RespIPBytes uint64
+	CommunityID string
+}
+
+// NewConn builds the conn.log entry of a connection. A, the sender of the first packet, is the
+// originator, and locals tells which ends are local: both for intra-host traffic, neither for
+// forwarded traffic. When the addresses are unknown the direction tells the local end
+func NewConn(conn flowtable.Connection, locals flowtable.Locals) Conn {
+	localOrig, localResp := conn.Outbound, !conn.Outbound
+	if locals != nil {
+		aLocal, ok := locals.IsLocal(conn.Netns, conn.AIp)
+		if ok {
+			localOrig = aLocal
+			localResp, _ = locals.IsLocal(conn.Netns, conn.BIp)
+		}
+	}
+
+	c := Conn{
+		Ts:          timer.ToWallClock(conn.Ts_ini),
+		Uid:         Uid(conn),
+		OrigH:       conn.AIp.Unmap().String(),
+		OrigP:       conn.APort,
+		RespH:       conn.BIp.Unmap().String(),
+		RespP:       conn.BPort,
+		Proto:       strings.ToLower(conn.Proto),
+		ConnState:   ConnState(conn),
+		LocalOrig:   localOrig,
+		LocalResp:   localResp,
+		History:     conn.History,
+		CommunityID: conn.CommunityID,
+	}
+
+	if conn.Ts_fin > conn.Ts_ini {
+		c.Duration = float64(conn.Ts_fin-conn.Ts_ini) / 1000000000
+	}
+
+	if conn.Outbound {
+		c.OrigPkts, c.OrigIPBytes = conn.Packets_out, conn.Bytes_out
+		c.RespPkts, c.RespIPBytes = conn.Packets_in, conn.Bytes_in
+	} else {
+		c.OrigPkts, c.OrigIPBytes = conn.Packets_in, conn.Bytes_in
+		c.RespPkts, c.RespIPBytes = conn.Packets_out, conn.Bytes_out
+	}
+
+	return c
+}
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Uid returns a Zeek style connection identifier. It is derived from the flow hash and its
+// start time, so the same connection keeps its uid across records
+func Uid(conn flowtable.Connection) string {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, conn.Hash)
+	binary.BigEndian.PutUint64(buf[8:], conn.Ts_ini)
+
+	hash := fnv.New64a()
+	hash.Write(buf)
+	n := hash.Sum64()
+
+	uid := []byte{'C'}
+	for n > 0 {
+		uid = append(uid, base62[n%62])
+		n /= 62
+	}
+	return string(uid)
+}
+
+// ConnState maps the connection history to the Zeek conn_state. Resets are not tracked,
+// so the REJ, RSTO* and RSTR* states are never reported
+func ConnState(conn flowtable.Connection) string {
+	if conn.Proto != "TCP" {
+		if conn.Packets_in > 0 && conn.Packets_out > 0 {
+			return "SF"
+		}
+		return "S0"
+	}
+
+	has := func(letter byte) bool {
+		return strings.IndexByte(conn.History, letter) >= 0
+	}
+
+	origSyn, respSynAck := has('S'), has('h')
+	origFin, respFin := has('F'), has('f')
+
+	switch {
+	case !origSyn:
+		return "OTH"
+	case !respSynAck && origFin:
+		return "SH"
+	case !respSynAck && respFin:
+		return "SHR"
+	case !respSynAck:
+		return "S0"
+	case origFin && respFin:
+		return "SF"
+	case origFin:
+		return "S2"
+	case respFin:
+		return "S3"
+	default:
+		return "S1"
+	}
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "T"
+	}
+	return "F"
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 6, 64)
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return unset
+	}
+	return s
+}
+
+// Header returns the TSV preamble written at the top of every conn.log file
+func Header(open time.Time) string {
+	var b strings.Builder
+
+	b.WriteString("#separator \\x09\n")
+	b.WriteString("#set_separator\t,\n")
+	fmt.Fprintf(&b, "#empty_field\t%s\n", empty)
+	fmt.Fprintf(&b, "#unset_field\t%s\n", unset)
+	b.WriteString("#path\tconn\n")
+	fmt.Fprintf(&b, "#open\t%s\n", open.Format("2006-01-02-15-04-05"))
+	fmt.Fprintf(&b, "#fields\t%s\n", strings.Join(fields, "\t"))
+	fmt.Fprintf(&b, "#types\t%s\n", strings.Join(types, "\t"))
+
+	return b.String()
+}
+
+// Footer returns the line closing a conn.log file
+func Footer(close time.Time) string {
+	return fmt.Sprintf("#close\t%s\n", close.Format("2006-01-02-15-04-05"))
+}
+
+// TSV returns c as a tab separated conn.log line
+func (c Conn) TSV() string {
+	values := []string{
+		formatTime(c.Ts),
+		c.Uid,
+		c.OrigH,
+		strconv.Itoa(int(c.OrigP)),
+		c.RespH,
+		strconv.Itoa(int(c.RespP)),
+		c.Proto,
+		unset,
+		strconv.FormatFloat(c.Duration, 'f', 6, 64),
+		unset,
+		unset,
+		c.ConnState,
+		formatBool(c.LocalOrig),
+		formatBool(c.LocalResp),
+		"0",
+		orUnset(c.History),
+		strconv.FormatUint(c.OrigPkts, 10),
+		strconv.FormatUint(c.OrigIPBytes, 10),
+		strconv.FormatUint(c.RespPkts, 10),
+		strconv.FormatUint(c.RespIPBytes, 10),
+		unset,
+		orUnset(c.CommunityID),
+	}
+	return strings.Join(values, "\t") + "\n"
+}
+
+// MarshalJSON encodes c like Zeek's JSON logs, leaving out the unset fields
+func (c Conn) MarshalJSON() ([]byte, error) {
+	type jsonConn struct {
+		Ts          float64 `json:"ts"`
+		Uid         string  `json:"uid"`
+		OrigH       string  `json:"id.orig_h"`
+		OrigP       uint16  `json:"id.orig_p"`
+		RespH       string  `json:"id.resp_h"`
+		RespP       uint16  `json:"id.resp_p"`
+		Proto       string  `json:"proto"`
+		Duration    float64 `json:"duration"`
+		ConnState   string  `json:"conn_state"`
+		LocalOrig   bool    `json:"local_orig"`
+		LocalResp   bool    `json:"local_resp"`
+		MissedBytes uint64  `json:"missed_bytes"`
+		History     string  `json:"history,omitempty"`
+		OrigPkts    uint64  `json:"orig_pkts"`
+		OrigIPBytes uint64  `json:"orig_ip_bytes"`
+		RespPkts    uint64  `json:"resp_pkts"`
+		RespIPBytes uint64  `json:"resp_ip_bytes"`
+		CommunityID string  `json:"community_id,omitempty"`
+	}
+
+	return json.Marshal(jsonConn{
+		Ts:          float64(c.Ts.UnixNano()) / 1e9,
+		Uid:         c.Uid,
+		OrigH:       c.OrigH,
+		OrigP:       c.OrigP,
+		RespH:       c.RespH,
+		RespP:       c.RespP,
+		Proto:       c.Proto,
+		Duration:    c.Duration,
+		ConnState:   c.ConnState,
+		LocalOrig:   c.LocalOrig,
+		LocalResp:   c.LocalResp,
+		History:     c.History,
+		OrigPkts:    c.OrigPkts,
+		OrigIPBytes: c.OrigIPBytes,
+		RespPkts:    c.RespPkts,
+		RespIPBytes: c.RespIPBytes,
+		CommunityID: c.CommunityID,
+	})
+}
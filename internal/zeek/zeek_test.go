@@ -0,0 +1,82 @@
+package zeek
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/gabspt/ConnectionStats/internal/flowtable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnState(t *testing.T) {
+	tests := []struct {
+		proto   string
+		history string
+		state   string
+	}{
+		{"TCP", "S", "S0"},
+		{"TCP", "ShA", "S1"},
+		{"TCP", "ShAFf", "SF"},
+		{"TCP", "ShAF", "S2"},
+		{"TCP", "ShAf", "S3"},
+		{"TCP", "SF", "SH"},
+		{"TCP", "Sf", "SHR"},
+		{"TCP", "Aa", "OTH"},
+	}
+
+	for _, test := range tests {
+		conn := flowtable.Connection{Proto: test.proto, History: test.history}
+		require.Equal(t, test.state, ConnState(conn), test.history)
+	}
+}
+
+func TestNewConnOrientation(t *testing.T) {
+	conn := flowtable.Connection{
+		Hash:        1,
+		Proto:       "UDP",
+		Packets_in:  1,
+		Packets_out: 3,
+		Bytes_in:    100,
+		Bytes_out:   300,
+		Outbound:    true,
+	}
+
+	c := NewConn(conn, nil)
+	require.Equal(t, "SF", c.ConnState)
+	require.Equal(t, uint64(3), c.OrigPkts)
+	require.Equal(t, uint64(100), c.RespIPBytes)
+	require.True(t, c.LocalOrig)
+	require.False(t, c.LocalResp)
+	require.Equal(t, Uid(conn), c.Uid)
+
+	// The addresses of the interface tell the local end before the direction
+	conn.AIp = netip.MustParseAddr("192.0.2.1")
+	conn.BIp = netip.MustParseAddr("10.0.0.1")
+	c = NewConn(conn, localAddrs{netip.MustParseAddr("10.0.0.1")})
+	require.False(t, c.LocalOrig)
+	require.True(t, c.LocalResp)
+	require.Equal(t, uint64(3), c.OrigPkts)
+
+	// Both ends are local on intra-host traffic
+	c = NewConn(conn, localAddrs{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("192.0.2.1")})
+	require.True(t, c.LocalOrig)
+	require.True(t, c.LocalResp)
+
+	// Neither end is local on forwarded traffic
+	c = NewConn(conn, localAddrs{netip.MustParseAddr("10.0.0.2")})
+	require.False(t, c.LocalOrig)
+	require.False(t, c.LocalResp)
+}
+
+// localAddrs are local in every namespace
+type localAddrs []netip.Addr
+
+func (l localAddrs) IsLocal(netns uint64, addr netip.Addr) (bool, bool) {
+	for _, local := range l {
+		if local == addr {
+			return true, true
+		}
+	}
+	return false, true
+}
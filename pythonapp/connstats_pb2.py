@@ -13,7 +13,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x63onnstats.proto\x12\x11\x63onnstatsprotobuf\"\xd7\x01\n\x0e\x43onnectionStat\x12\x0c\n\x04hash\x18\x01 \x01(\x04\x12\r\n\x05proto\x18\x02 \x01(\t\x12\x0c\n\x04\x61_ip\x18\x03 \x01(\t\x12\x0c\n\x04\x62_ip\x18\x04 \x01(\t\x12\x0e\n\x06\x61_port\x18\x05 \x01(\r\x12\x0e\n\x06\x62_port\x18\x06 \x01(\r\x12\x12\n\npackets_in\x18\x07 \x01(\x04\x12\x13\n\x0bpackets_out\x18\x08 \x01(\x04\x12\x0e\n\x06ts_ini\x18\t \x01(\x04\x12\x0e\n\x06ts_fin\x18\n \x01(\x04\x12\x10\n\x08\x62ytes_in\x18\x0b \x01(\x04\x12\x11\n\tbytes_out\x18\x0c \x01(\x04\"\x0e\n\x0cStatsRequest\"A\n\nStatsReply\x12\x33\n\x08\x63onnstat\x18\x01 \x03(\x0b\x32!.connstatsprotobuf.ConnectionStat2`\n\x0cStatsService\x12P\n\x0c\x43ollectStats\x12\x1f.connstatsprotobuf.StatsRequest\x1a\x1d.connstatsprotobuf.StatsReply\"\x00\x42#Z!ConnectionStats/connstatsprotobufb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x0f\x63onnstats.proto\x12\x11\x63onnstatsprotobuf\"\x86\x06\n\x0e\x43onnectionStat\x12\x0c\n\x04hash\x18\x01 \x01(\x04\x12\r\n\x05proto\x18\x02 \x01(\t\x12\x0c\n\x04\x61_ip\x18\x03 \x01(\t\x12\x0c\n\x04\x62_ip\x18\x04 \x01(\t\x12\x0e\n\x06\x61_port\x18\x05 \x01(\r\x12\x0e\n\x06\x62_port\x18\x06 \x01(\r\x12\x12\n\npackets_in\x18\x07 \x01(\x04\x12\x13\n\x0bpackets_out\x18\x08 \x01(\x04\x12\x0e\n\x06ts_ini\x18\t \x01(\x04\x12\x0e\n\x06ts_fin\x18\n \x01(\x04\x12\x10\n\x08\x62ytes_in\x18\x0b \x01(\x04\x12\x11\n\tbytes_out\x18\x0c \x01(\x04\x12\x14\n\x0c\x63ommunity_id\x18\r \x01(\t\x12\x13\n\x0bsample_rate\x18\x0e \x01(\r\x12\x11\n\testimated\x18\x0f \x01(\x08\x12\x0f\n\x07ifindex\x18\x10 \x01(\r\x12\r\n\x05netns\x18\x11 \x01(\x04\x12\x12\n\nmid_stream\x18\x12 \x01(\x08\x12\x10\n\x08local_ip\x18\x13 \x01(\t\x12\x12\n\nlocal_port\x18\x14 \x01(\r\x12\x11\n\tremote_ip\x18\x15 \x01(\t\x12\x13\n\x0bremote_port\x18\x16 \x01(\r\x12\x17\n\x0flocal_initiator\x18\x17 \x01(\x08\x12\x13\n\x0bserver_port\x18\x18 \x01(\r\x12\x19\n\x11packets_to_server\x18\x19 \x01(\x04\x12\x17\n\x0f\x62ytes_to_server\x18\x1a \x01(\x04\x12\x19\n\x11packets_to_client\x18\x1b \x01(\x04\x12\x17\n\x0f\x62ytes_to_client\x18\x1c \x01(\x04\x12\x0b\n\x03nat\x18\x1d \x01(\x08\x12\x16\n\x0epre_nat_src_ip\x18\x1e \x01(\t\x12\x18\n\x10pre_nat_src_port\x18\x1f \x01(\r\x12\x16\n\x0epre_nat_dst_ip\x18  \x01(\t\x12\x18\n\x10pre_nat_dst_port\x18! \x01(\r\x12\x17\n\x0fpost_nat_src_ip\x18\" \x01(\t\x12\x19\n\x11post_nat_src_port\x18# \x01(\r\x12\x17\n\x0fpost_nat_dst_ip\x18$ \x01(\t\x12\x19\n\x11post_nat_dst_port\x18% \x01(\r\"\x0e\n\x0cStatsRequest\"A\n\nStatsReply\x12\x33\n\x08\x63onnstat\x18\x01 \x03(\x0b\x32!.connstatsprotobuf.ConnectionStat\"\xa5\x01\n\x0e\x43\x61ptureRequest\x12\r\n\x05proto\x18\x01 \x01(\r\x12\r\n\x05\x61_net\x18\x02 \x01(\t\x12\x0e\n\x06\x61_port\x18\x03 \x01(\r\x12\r\n\x05\x62_net\x18\x04 \x01(\t\x12\x0e\n\x06\x62_port\x18\x05 \x01(\r\x12\x14\n\x0c\x64uration_sec\x18\x06 \x01(\r\x12\x11\n\tmax_bytes\x18\x07 \x01(\x04\x12\x0f\n\x07snaplen\x18\x08 \x01(\r\x12\x0c\n\x04path\x18\t \x01(\t\"\x1c\n\x0c\x43\x61ptureReply\x12\x0c\n\x04path\x18\x01 \x01(\t\"L\n\x0e\x43\x61pturedPacket\x12\n\n\x02ts\x18\x01 \x01(\x04\x12\x0e\n\x06length\x18\x02 \x01(\r\x12\x10\n\x08outbound\x18\x03 \x01(\x08\x12\x0c\n\x04\x64\x61ta\x18\x04 \x01(\x0c\",\n\rFilterRequest\x12\x0b\n\x03\x61\x64\x64\x18\x01 \x03(\t\x12\x0e\n\x06remove\x18\x02 \x03(\r\"\x13\n\x11\x46ilterListRequest\"4\n\nFilterRule\x12\n\n\x02id\x18\x01 \x01(\r\x12\x0c\n\x04rule\x18\x02 \x01(\t\x12\x0c\n\x04hits\x18\x03 \x01(\x04\";\n\x0b\x46ilterReply\x12,\n\x05rules\x18\x01 \x03(\x0b\x32\x1d.connstatsprotobuf.FilterRule\"\x12\n\x10PipeStatsRequest\"\x80\x01\n\x0ePipeStatsReply\x12\x0e\n\x06\x65vents\x18\x01 \x01(\x04\x12\r\n\x05\x64rops\x18\x02 \x01(\x04\x12\x15\n\rdrops_per_cpu\x18\x03 \x03(\x04\x12\x0c\n\x04\x66ill\x18\x04 \x01(\x01\x12\x15\n\rsample_factor\x18\x05 \x01(\r\x12\x13\n\x0b\x61ggregating\x18\x06 \x01(\x08\"\x13\n\x11TableStatsRequest\"s\n\x0fTableStatsReply\x12\r\n\x05\x66lows\x18\x01 \x01(\x04\x12\x11\n\tmax_flows\x18\x02 \x01(\x04\x12\x14\n\x0cmemory_bytes\x18\x03 \x01(\x04\x12\x15\n\rmemory_budget\x18\x04 \x01(\x04\x12\x11\n\tevictions\x18\x05 \x01(\x04\"\x14\n\x12\x41ttachmentsRequest\"d\n\nAttachment\x12\x11\n\tnamespace\x18\x01 \x01(\t\x12\x11\n\tinterface\x18\x02 \x01(\t\x12\x0f\n\x07ifindex\x18\x03 \x01(\r\x12\x0f\n\x07ingress\x18\x04 \x01(\t\x12\x0e\n\x06\x65gress\x18\x05 \x01(\t\"F\n\x10\x41ttachmentsReply\x12\x32\n\x0b\x61ttachments\x18\x01 \x03(\x0b\x32\x1d.connstatsprotobuf.Attachment2\xcb\x05\n\x0cStatsService\x12P\n\x0c\x43ollectStats\x12\x1f.connstatsprotobuf.StatsRequest\x1a\x1d.connstatsprotobuf.StatsReply\"\x00\x12T\n\x0cStartCapture\x12!.connstatsprotobuf.CaptureRequest\x1a\x1f.connstatsprotobuf.CaptureReply\"\x00\x12Y\n\rStreamCapture\x12!.connstatsprotobuf.CaptureRequest\x1a!.connstatsprotobuf.CapturedPacket\"\x00\x30\x01\x12S\n\rUpdateFilters\x12 .connstatsprotobuf.FilterRequest\x1a\x1e.connstatsprotobuf.FilterReply\"\x00\x12U\n\x0bListFilters\x12$.connstatsprotobuf.FilterListRequest\x1a\x1e.connstatsprotobuf.FilterReply\"\x00\x12U\n\tPipeStats\x12#.connstatsprotobuf.PipeStatsRequest\x1a!.connstatsprotobuf.PipeStatsReply\"\x00\x12X\n\nTableStats\x12$.connstatsprotobuf.TableStatsRequest\x1a\".connstatsprotobuf.TableStatsReply\"\x00\x12[\n\x0b\x41ttachments\x12%.connstatsprotobuf.AttachmentsRequest\x1a#.connstatsprotobuf.AttachmentsReply\"\x00\x42#Z!ConnectionStats/connstatsprotobufb\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -23,11 +23,39 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   DESCRIPTOR._options = None
   DESCRIPTOR._serialized_options = b'Z!ConnectionStats/connstatsprotobuf'
   _globals['_CONNECTIONSTAT']._serialized_start=39
-  _globals['_CONNECTIONSTAT']._serialized_end=254
-  _globals['_STATSREQUEST']._serialized_start=256
-  _globals['_STATSREQUEST']._serialized_end=270
-  _globals['_STATSREPLY']._serialized_start=272
-  _globals['_STATSREPLY']._serialized_end=337
-  _globals['_STATSSERVICE']._serialized_start=339
-  _globals['_STATSSERVICE']._serialized_end=435
+  _globals['_CONNECTIONSTAT']._serialized_end=813
+  _globals['_STATSREQUEST']._serialized_start=815
+  _globals['_STATSREQUEST']._serialized_end=829
+  _globals['_STATSREPLY']._serialized_start=831
+  _globals['_STATSREPLY']._serialized_end=896
+  _globals['_CAPTUREREQUEST']._serialized_start=899
+  _globals['_CAPTUREREQUEST']._serialized_end=1064
+  _globals['_CAPTUREREPLY']._serialized_start=1066
+  _globals['_CAPTUREREPLY']._serialized_end=1094
+  _globals['_CAPTUREDPACKET']._serialized_start=1096
+  _globals['_CAPTUREDPACKET']._serialized_end=1172
+  _globals['_FILTERREQUEST']._serialized_start=1174
+  _globals['_FILTERREQUEST']._serialized_end=1218
+  _globals['_FILTERLISTREQUEST']._serialized_start=1220
+  _globals['_FILTERLISTREQUEST']._serialized_end=1239
+  _globals['_FILTERRULE']._serialized_start=1241
+  _globals['_FILTERRULE']._serialized_end=1293
+  _globals['_FILTERREPLY']._serialized_start=1295
+  _globals['_FILTERREPLY']._serialized_end=1354
+  _globals['_PIPESTATSREQUEST']._serialized_start=1356
+  _globals['_PIPESTATSREQUEST']._serialized_end=1374
+  _globals['_PIPESTATSREPLY']._serialized_start=1377
+  _globals['_PIPESTATSREPLY']._serialized_end=1505
+  _globals['_TABLESTATSREQUEST']._serialized_start=1507
+  _globals['_TABLESTATSREQUEST']._serialized_end=1526
+  _globals['_TABLESTATSREPLY']._serialized_start=1528
+  _globals['_TABLESTATSREPLY']._serialized_end=1643
+  _globals['_ATTACHMENTSREQUEST']._serialized_start=1645
+  _globals['_ATTACHMENTSREQUEST']._serialized_end=1665
+  _globals['_ATTACHMENT']._serialized_start=1667
+  _globals['_ATTACHMENT']._serialized_end=1767
+  _globals['_ATTACHMENTSREPLY']._serialized_start=1769
+  _globals['_ATTACHMENTSREPLY']._serialized_end=1839
+  _globals['_STATSSERVICE']._serialized_start=1842
+  _globals['_STATSSERVICE']._serialized_end=2557
 # @@protoc_insertion_point(module_scope)
@@ -6,7 +6,7 @@ from typing import ClassVar as _ClassVar, Iterable as _Iterable, Mapping as _Map
 DESCRIPTOR: _descriptor.FileDescriptor
 
 class ConnectionStat(_message.Message):
-    __slots__ = ["hash", "proto", "a_ip", "b_ip", "a_port", "b_port", "packets_in", "packets_out", "ts_ini", "ts_fin", "bytes_in", "bytes_out"]
+    __slots__ = ["hash", "proto", "a_ip", "b_ip", "a_port", "b_port", "packets_in", "packets_out", "ts_ini", "ts_fin", "bytes_in", "bytes_out", "community_id", "sample_rate", "estimated", "ifindex", "netns", "mid_stream", "local_ip", "local_port", "remote_ip", "remote_port", "local_initiator", "server_port", "packets_to_server", "bytes_to_server", "packets_to_client", "bytes_to_client", "nat", "pre_nat_src_ip", "pre_nat_src_port", "pre_nat_dst_ip", "pre_nat_dst_port", "post_nat_src_ip", "post_nat_src_port", "post_nat_dst_ip", "post_nat_dst_port"]
     HASH_FIELD_NUMBER: _ClassVar[int]
     PROTO_FIELD_NUMBER: _ClassVar[int]
     A_IP_FIELD_NUMBER: _ClassVar[int]
@@ -19,6 +19,31 @@ class ConnectionStat(_message.Message):
     TS_FIN_FIELD_NUMBER: _ClassVar[int]
     BYTES_IN_FIELD_NUMBER: _ClassVar[int]
     BYTES_OUT_FIELD_NUMBER: _ClassVar[int]
+    COMMUNITY_ID_FIELD_NUMBER: _ClassVar[int]
+    SAMPLE_RATE_FIELD_NUMBER: _ClassVar[int]
+    ESTIMATED_FIELD_NUMBER: _ClassVar[int]
+    IFINDEX_FIELD_NUMBER: _ClassVar[int]
+    NETNS_FIELD_NUMBER: _ClassVar[int]
+    MID_STREAM_FIELD_NUMBER: _ClassVar[int]
+    LOCAL_IP_FIELD_NUMBER: _ClassVar[int]
+    LOCAL_PORT_FIELD_NUMBER: _ClassVar[int]
+    REMOTE_IP_FIELD_NUMBER: _ClassVar[int]
+    REMOTE_PORT_FIELD_NUMBER: _ClassVar[int]
+    LOCAL_INITIATOR_FIELD_NUMBER: _ClassVar[int]
+    SERVER_PORT_FIELD_NUMBER: _ClassVar[int]
+    PACKETS_TO_SERVER_FIELD_NUMBER: _ClassVar[int]
+    BYTES_TO_SERVER_FIELD_NUMBER: _ClassVar[int]
+    PACKETS_TO_CLIENT_FIELD_NUMBER: _ClassVar[int]
+    BYTES_TO_CLIENT_FIELD_NUMBER: _ClassVar[int]
+    NAT_FIELD_NUMBER: _ClassVar[int]
+    PRE_NAT_SRC_IP_FIELD_NUMBER: _ClassVar[int]
+    PRE_NAT_SRC_PORT_FIELD_NUMBER: _ClassVar[int]
+    PRE_NAT_DST_IP_FIELD_NUMBER: _ClassVar[int]
+    PRE_NAT_DST_PORT_FIELD_NUMBER: _ClassVar[int]
+    POST_NAT_SRC_IP_FIELD_NUMBER: _ClassVar[int]
+    POST_NAT_SRC_PORT_FIELD_NUMBER: _ClassVar[int]
+    POST_NAT_DST_IP_FIELD_NUMBER: _ClassVar[int]
+    POST_NAT_DST_PORT_FIELD_NUMBER: _ClassVar[int]
     hash: int
     proto: str
     a_ip: str
@@ -31,7 +56,32 @@ class ConnectionStat(_message.Message):
     ts_fin: int
     bytes_in: int
     bytes_out: int
-    def __init__(self, hash: _Optional[int] = ..., proto: _Optional[str] = ..., a_ip: _Optional[str] = ..., b_ip: _Optional[str] = ..., a_port: _Optional[int] = ..., b_port: _Optional[int] = ..., packets_in: _Optional[int] = ..., packets_out: _Optional[int] = ..., ts_ini: _Optional[int] = ..., ts_fin: _Optional[int] = ..., bytes_in: _Optional[int] = ..., bytes_out: _Optional[int] = ...) -> None: ...
+    community_id: str
+    sample_rate: int
+    estimated: bool
+    ifindex: int
+    netns: int
+    mid_stream: bool
+    local_ip: str
+    local_port: int
+    remote_ip: str
+    remote_port: int
+    local_initiator: bool
+    server_port: int
+    packets_to_server: int
+    bytes_to_server: int
+    packets_to_client: int
+    bytes_to_client: int
+    nat: bool
+    pre_nat_src_ip: str
+    pre_nat_src_port: int
+    pre_nat_dst_ip: str
+    pre_nat_dst_port: int
+    post_nat_src_ip: str
+    post_nat_src_port: int
+    post_nat_dst_ip: str
+    post_nat_dst_port: int
+    def __init__(self, hash: _Optional[int] = ..., proto: _Optional[str] = ..., a_ip: _Optional[str] = ..., b_ip: _Optional[str] = ..., a_port: _Optional[int] = ..., b_port: _Optional[int] = ..., packets_in: _Optional[int] = ..., packets_out: _Optional[int] = ..., ts_ini: _Optional[int] = ..., ts_fin: _Optional[int] = ..., bytes_in: _Optional[int] = ..., bytes_out: _Optional[int] = ..., community_id: _Optional[str] = ..., sample_rate: _Optional[int] = ..., estimated: _Optional[bool] = ..., ifindex: _Optional[int] = ..., netns: _Optional[int] = ..., mid_stream: _Optional[bool] = ..., local_ip: _Optional[str] = ..., local_port: _Optional[int] = ..., remote_ip: _Optional[str] = ..., remote_port: _Optional[int] = ..., local_initiator: _Optional[bool] = ..., server_port: _Optional[int] = ..., packets_to_server: _Optional[int] = ..., bytes_to_server: _Optional[int] = ..., packets_to_client: _Optional[int] = ..., bytes_to_client: _Optional[int] = ..., nat: _Optional[bool] = ..., pre_nat_src_ip: _Optional[str] = ..., pre_nat_src_port: _Optional[int] = ..., pre_nat_dst_ip: _Optional[str] = ..., pre_nat_dst_port: _Optional[int] = ..., post_nat_src_ip: _Optional[str] = ..., post_nat_src_port: _Optional[int] = ..., post_nat_dst_ip: _Optional[str] = ..., post_nat_dst_port: _Optional[int] = ...) -> None: ...
 
 class StatsRequest(_message.Message):
     __slots__ = []
@@ -42,3 +92,133 @@ class StatsReply(_message.Message):
     CONNSTAT_FIELD_NUMBER: _ClassVar[int]
     connstat: _containers.RepeatedCompositeFieldContainer[ConnectionStat]
     def __init__(self, connstat: _Optional[_Iterable[_Union[ConnectionStat, _Mapping]]] = ...) -> None: ...
+
+class CaptureRequest(_message.Message):
+    __slots__ = ["proto", "a_net", "a_port", "b_net", "b_port", "duration_sec", "max_bytes", "snaplen", "path"]
+    PROTO_FIELD_NUMBER: _ClassVar[int]
+    A_NET_FIELD_NUMBER: _ClassVar[int]
+    A_PORT_FIELD_NUMBER: _ClassVar[int]
+    B_NET_FIELD_NUMBER: _ClassVar[int]
+    B_PORT_FIELD_NUMBER: _ClassVar[int]
+    DURATION_SEC_FIELD_NUMBER: _ClassVar[int]
+    MAX_BYTES_FIELD_NUMBER: _ClassVar[int]
+    SNAPLEN_FIELD_NUMBER: _ClassVar[int]
+    PATH_FIELD_NUMBER: _ClassVar[int]
+    proto: int
+    a_net: str
+    a_port: int
+    b_net: str
+    b_port: int
+    duration_sec: int
+    max_bytes: int
+    snaplen: int
+    path: str
+    def __init__(self, proto: _Optional[int] = ..., a_net: _Optional[str] = ..., a_port: _Optional[int] = ..., b_net: _Optional[str] = ..., b_port: _Optional[int] = ..., duration_sec: _Optional[int] = ..., max_bytes: _Optional[int] = ..., snaplen: _Optional[int] = ..., path: _Optional[str] = ...) -> None: ...
+
+class CaptureReply(_message.Message):
+    __slots__ = ["path"]
+    PATH_FIELD_NUMBER: _ClassVar[int]
+    path: str
+    def __init__(self, path: _Optional[str] = ...) -> None: ...
+
+class CapturedPacket(_message.Message):
+    __slots__ = ["ts", "length", "outbound", "data"]
+    TS_FIELD_NUMBER: _ClassVar[int]
+    LENGTH_FIELD_NUMBER: _ClassVar[int]
+    OUTBOUND_FIELD_NUMBER: _ClassVar[int]
+    DATA_FIELD_NUMBER: _ClassVar[int]
+    ts: int
+    length: int
+    outbound: bool
+    data: bytes
+    def __init__(self, ts: _Optional[int] = ..., length: _Optional[int] = ..., outbound: _Optional[bool] = ..., data: _Optional[bytes] = ...) -> None: ...
+
+class FilterRequest(_message.Message):
+    __slots__ = ["add", "remove"]
+    ADD_FIELD_NUMBER: _ClassVar[int]
+    REMOVE_FIELD_NUMBER: _ClassVar[int]
+    add: _containers.RepeatedScalarFieldContainer[str]
+    remove: _containers.RepeatedScalarFieldContainer[int]
+    def __init__(self, add: _Optional[_Iterable[str]] = ..., remove: _Optional[_Iterable[int]] = ...) -> None: ...
+
+class FilterListRequest(_message.Message):
+    __slots__ = []
+    def __init__(self) -> None: ...
+
+class FilterRule(_message.Message):
+    __slots__ = ["id", "rule", "hits"]
+    ID_FIELD_NUMBER: _ClassVar[int]
+    RULE_FIELD_NUMBER: _ClassVar[int]
+    HITS_FIELD_NUMBER: _ClassVar[int]
+    id: int
+    rule: str
+    hits: int
+    def __init__(self, id: _Optional[int] = ..., rule: _Optional[str] = ..., hits: _Optional[int] = ...) -> None: ...
+
+class FilterReply(_message.Message):
+    __slots__ = ["rules"]
+    RULES_FIELD_NUMBER: _ClassVar[int]
+    rules: _containers.RepeatedCompositeFieldContainer[FilterRule]
+    def __init__(self, rules: _Optional[_Iterable[_Union[FilterRule, _Mapping]]] = ...) -> None: ...
+
+class PipeStatsRequest(_message.Message):
+    __slots__ = []
+    def __init__(self) -> None: ...
+
+class PipeStatsReply(_message.Message):
+    __slots__ = ["events", "drops", "drops_per_cpu", "fill", "sample_factor", "aggregating"]
+    EVENTS_FIELD_NUMBER: _ClassVar[int]
+    DROPS_FIELD_NUMBER: _ClassVar[int]
+    DROPS_PER_CPU_FIELD_NUMBER: _ClassVar[int]
+    FILL_FIELD_NUMBER: _ClassVar[int]
+    SAMPLE_FACTOR_FIELD_NUMBER: _ClassVar[int]
+    AGGREGATING_FIELD_NUMBER: _ClassVar[int]
+    events: int
+    drops: int
+    drops_per_cpu: _containers.RepeatedScalarFieldContainer[int]
+    fill: float
+    sample_factor: int
+    aggregating: bool
+    def __init__(self, events: _Optional[int] = ..., drops: _Optional[int] = ..., drops_per_cpu: _Optional[_Iterable[int]] = ..., fill: _Optional[float] = ..., sample_factor: _Optional[int] = ..., aggregating: _Optional[bool] = ...) -> None: ...
+
+class TableStatsRequest(_message.Message):
+    __slots__ = []
+    def __init__(self) -> None: ...
+
+class TableStatsReply(_message.Message):
+    __slots__ = ["flows", "max_flows", "memory_bytes", "memory_budget", "evictions"]
+    FLOWS_FIELD_NUMBER: _ClassVar[int]
+    MAX_FLOWS_FIELD_NUMBER: _ClassVar[int]
+    MEMORY_BYTES_FIELD_NUMBER: _ClassVar[int]
+    MEMORY_BUDGET_FIELD_NUMBER: _ClassVar[int]
+    EVICTIONS_FIELD_NUMBER: _ClassVar[int]
+    flows: int
+    max_flows: int
+    memory_bytes: int
+    memory_budget: int
+    evictions: int
+    def __init__(self, flows: _Optional[int] = ..., max_flows: _Optional[int] = ..., memory_bytes: _Optional[int] = ..., memory_budget: _Optional[int] = ..., evictions: _Optional[int] = ...) -> None: ...
+
+class AttachmentsRequest(_message.Message):
+    __slots__ = []
+    def __init__(self) -> None: ...
+
+class Attachment(_message.Message):
+    __slots__ = ["namespace", "interface", "ifindex", "ingress", "egress"]
+    NAMESPACE_FIELD_NUMBER: _ClassVar[int]
+    INTERFACE_FIELD_NUMBER: _ClassVar[int]
+    IFINDEX_FIELD_NUMBER: _ClassVar[int]
+    INGRESS_FIELD_NUMBER: _ClassVar[int]
+    EGRESS_FIELD_NUMBER: _ClassVar[int]
+    namespace: str
+    interface: str
+    ifindex: int
+    ingress: str
+    egress: str
+    def __init__(self, namespace: _Optional[str] = ..., interface: _Optional[str] = ..., ifindex: _Optional[int] = ..., ingress: _Optional[str] = ..., egress: _Optional[str] = ...) -> None: ...
+
+class AttachmentsReply(_message.Message):
+    __slots__ = ["attachments"]
+    ATTACHMENTS_FIELD_NUMBER: _ClassVar[int]
+    attachments: _containers.RepeatedCompositeFieldContainer[Attachment]
+    def __init__(self, attachments: _Optional[_Iterable[_Union[Attachment, _Mapping]]] = ...) -> None: ...
@@ -20,6 +20,41 @@ class StatsServiceStub(object):
                 request_serializer=connstats__pb2.StatsRequest.SerializeToString,
                 response_deserializer=connstats__pb2.StatsReply.FromString,
                 )
+        self.StartCapture = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/StartCapture',
+                request_serializer=connstats__pb2.CaptureRequest.SerializeToString,
+                response_deserializer=connstats__pb2.CaptureReply.FromString,
+                )
+        self.StreamCapture = channel.unary_stream(
+                '/connstatsprotobuf.StatsService/StreamCapture',
+                request_serializer=connstats__pb2.CaptureRequest.SerializeToString,
+                response_deserializer=connstats__pb2.CapturedPacket.FromString,
+                )
+        self.UpdateFilters = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/UpdateFilters',
+                request_serializer=connstats__pb2.FilterRequest.SerializeToString,
+                response_deserializer=connstats__pb2.FilterReply.FromString,
+                )
+        self.ListFilters = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/ListFilters',
+                request_serializer=connstats__pb2.FilterListRequest.SerializeToString,
+                response_deserializer=connstats__pb2.FilterReply.FromString,
+                )
+        self.PipeStats = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/PipeStats',
+                request_serializer=connstats__pb2.PipeStatsRequest.SerializeToString,
+                response_deserializer=connstats__pb2.PipeStatsReply.FromString,
+                )
+        self.TableStats = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/TableStats',
+                request_serializer=connstats__pb2.TableStatsRequest.SerializeToString,
+                response_deserializer=connstats__pb2.TableStatsReply.FromString,
+                )
+        self.Attachments = channel.unary_unary(
+                '/connstatsprotobuf.StatsService/Attachments',
+                request_serializer=connstats__pb2.AttachmentsRequest.SerializeToString,
+                response_deserializer=connstats__pb2.AttachmentsReply.FromString,
+                )
 
 
 class StatsServiceServicer(object):
@@ -33,6 +68,55 @@ class StatsServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def StartCapture(self, request, context):
+        """Captures the matching packets to a pcapng file on the agent host
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def StreamCapture(self, request, context):
+        """Captures the matching packets and streams them back
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def UpdateFilters(self, request, context):
+        """Adds and removes filter rules, returns the resulting rules
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ListFilters(self, request, context):
+        """Lists the filter rules with their hit counters
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PipeStats(self, request, context):
+        """Reports the events sent and dropped by the probe and the backpressure state
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def TableStats(self, request, context):
+        """Reports the occupancy of the flow table
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Attachments(self, request, context):
+        """Lists the interfaces the probe runs on, and the hook of each direction
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_StatsServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -41,6 +125,41 @@ def add_StatsServiceServicer_to_server(servicer, server):
                     request_deserializer=connstats__pb2.StatsRequest.FromString,
                     response_serializer=connstats__pb2.StatsReply.SerializeToString,
             ),
+            'StartCapture': grpc.unary_unary_rpc_method_handler(
+                    servicer.StartCapture,
+                    request_deserializer=connstats__pb2.CaptureRequest.FromString,
+                    response_serializer=connstats__pb2.CaptureReply.SerializeToString,
+            ),
+            'StreamCapture': grpc.unary_stream_rpc_method_handler(
+                    servicer.StreamCapture,
+                    request_deserializer=connstats__pb2.CaptureRequest.FromString,
+                    response_serializer=connstats__pb2.CapturedPacket.SerializeToString,
+            ),
+            'UpdateFilters': grpc.unary_unary_rpc_method_handler(
+                    servicer.UpdateFilters,
+                    request_deserializer=connstats__pb2.FilterRequest.FromString,
+                    response_serializer=connstats__pb2.FilterReply.SerializeToString,
+            ),
+            'ListFilters': grpc.unary_unary_rpc_method_handler(
+                    servicer.ListFilters,
+                    request_deserializer=connstats__pb2.FilterListRequest.FromString,
+                    response_serializer=connstats__pb2.FilterReply.SerializeToString,
+            ),
+            'PipeStats': grpc.unary_unary_rpc_method_handler(
+                    servicer.PipeStats,
+                    request_deserializer=connstats__pb2.PipeStatsRequest.FromString,
+                    response_serializer=connstats__pb2.PipeStatsReply.SerializeToString,
+            ),
+            'TableStats': grpc.unary_unary_rpc_method_handler(
+                    servicer.TableStats,
+                    request_deserializer=connstats__pb2.TableStatsRequest.FromString,
+                    response_serializer=connstats__pb2.TableStatsReply.SerializeToString,
+            ),
+            'Attachments': grpc.unary_unary_rpc_method_handler(
+                    servicer.Attachments,
+                    request_deserializer=connstats__pb2.AttachmentsRequest.FromString,
+                    response_serializer=connstats__pb2.AttachmentsReply.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'connstatsprotobuf.StatsService', rpc_method_handlers)
@@ -68,3 +187,122 @@ class StatsService(object):
             connstats__pb2.StatsReply.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def StartCapture(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/StartCapture',
+            connstats__pb2.CaptureRequest.SerializeToString,
+            connstats__pb2.CaptureReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def StreamCapture(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/connstatsprotobuf.StatsService/StreamCapture',
+            connstats__pb2.CaptureRequest.SerializeToString,
+            connstats__pb2.CapturedPacket.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def UpdateFilters(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/UpdateFilters',
+            connstats__pb2.FilterRequest.SerializeToString,
+            connstats__pb2.FilterReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ListFilters(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/ListFilters',
+            connstats__pb2.FilterListRequest.SerializeToString,
+            connstats__pb2.FilterReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PipeStats(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/PipeStats',
+            connstats__pb2.PipeStatsRequest.SerializeToString,
+            connstats__pb2.PipeStatsReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def TableStats(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/TableStats',
+            connstats__pb2.TableStatsRequest.SerializeToString,
+            connstats__pb2.TableStatsReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Attachments(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/connstatsprotobuf.StatsService/Attachments',
+            connstats__pb2.AttachmentsRequest.SerializeToString,
+            connstats__pb2.AttachmentsReply.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)